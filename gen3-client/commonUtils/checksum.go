@@ -0,0 +1,96 @@
+package commonUtils
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+)
+
+// hashConstructors maps the Indexd names of the supported hash algorithms to their implementations
+var hashConstructors = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha256": sha256.New,
+}
+
+// HashWriter is an io.Writer that computes the digests of all data written to it
+type HashWriter struct {
+	hashers map[string]hash.Hash
+	size    int64
+}
+
+// NewHashWriter returns a HashWriter for the given hash algorithms, unsupported algorithms are ignored
+func NewHashWriter(algorithms ...string) *HashWriter {
+	hw := &HashWriter{hashers: make(map[string]hash.Hash)}
+	for _, algorithm := range algorithms {
+		if newHash, ok := hashConstructors[algorithm]; ok {
+			hw.hashers[algorithm] = newHash()
+		}
+	}
+	return hw
+}
+
+func (hw *HashWriter) Write(p []byte) (int, error) {
+	for _, hasher := range hw.hashers {
+		hasher.Write(p) // nolint: errcheck
+	}
+	hw.size += int64(len(p))
+	return len(p), nil
+}
+
+// Size returns the number of bytes written so far
+func (hw *HashWriter) Size() int64 {
+	return hw.size
+}
+
+// Sums returns the hex encoded digests of the data written so far, keyed by hash algorithm
+func (hw *HashWriter) Sums() map[string]string {
+	sums := make(map[string]string)
+	for algorithm, hasher := range hw.hashers {
+		sums[algorithm] = hex.EncodeToString(hasher.Sum(nil))
+	}
+	return sums
+}
+
+// VerifiableHashAlgorithms returns the algorithms of the given hashes that can be computed locally
+func VerifiableHashAlgorithms(hashes map[string]string) []string {
+	algorithms := make([]string, 0)
+	for algorithm, value := range hashes {
+		if _, ok := hashConstructors[algorithm]; ok && value != "" {
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+	sort.Strings(algorithms)
+	return algorithms
+}
+
+// VerifyHashes checks that every expected hash that has been computed matches its computed value
+func VerifyHashes(expected map[string]string, computed map[string]string) error {
+	mismatches := make([]string, 0)
+	for _, algorithm := range VerifiableHashAlgorithms(expected) {
+		actual, ok := computed[algorithm]
+		if !ok {
+			continue
+		}
+		if !strings.EqualFold(actual, expected[algorithm]) {
+			mismatches = append(mismatches, fmt.Sprintf("%s expected %s, got %s", algorithm, expected[algorithm], actual))
+		}
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("checksum mismatch: %s", strings.Join(mismatches, "; "))
+	}
+	return nil
+}
+
+// ContentMD5 converts a hex encoded md5 digest into the base64 encoded form used by the Content-MD5 header
+func ContentMD5(md5Hex string) (string, error) {
+	digest, err := hex.DecodeString(md5Hex)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(digest), nil
+}
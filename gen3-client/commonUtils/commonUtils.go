@@ -2,6 +2,7 @@ package commonUtils
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -35,6 +36,9 @@ const ShepherdVersionEndpoint = "/mds/version"
 // IndexdIndexEndpoint is the endpoint postfix for INDEXD index
 const IndexdIndexEndpoint = "/index/index"
 
+// IndexdBulkDocumentsEndpoint is the endpoint postfix for retrieving multiple INDEXD records at once
+const IndexdBulkDocumentsEndpoint = "/index/bulk/documents"
+
 // FenceUserEndpoint is the endpoint postfix for FENCE user
 const FenceUserEndpoint = "/user/user"
 
@@ -65,6 +69,18 @@ const PathSeparator = string(os.PathSeparator)
 // DefaultTimeout is used to set timeout value for http client
 const DefaultTimeout = 120 * time.Second
 
+// DefaultConnectTimeout is used to set timeout value for establishing a connection, including its TLS handshake
+const DefaultConnectTimeout = 30 * time.Second
+
+// DefaultResponseHeaderTimeout is used to set how long the response headers of a request may take to arrive once it has been sent
+const DefaultResponseHeaderTimeout = 120 * time.Second
+
+// DefaultIdleConnTimeout is used to set how long an idle keep-alive connection is kept open
+const DefaultIdleConnTimeout = 90 * time.Second
+
+// DefaultMaxIdleConnsPerHost is used to set the number of idle keep-alive connections kept open to each host
+const DefaultMaxIdleConnsPerHost = 32
+
 // FileUploadRequestObject defines a object for file upload
 type FileUploadRequestObject struct {
 	FilePath     string
@@ -74,6 +90,8 @@ type FileUploadRequestObject struct {
 	PresignedURL string
 	Request      *http.Request
 	Bar          *pb.ProgressBar
+	FileSize     int64
+	Hashes       map[string]string
 	Bucket 	 	 string `json:"bucket,omitempty"`
 }
 
@@ -88,6 +106,13 @@ type FileDownloadResponseObject struct {
 	Skip         bool
 	Response     *http.Response
 	Writer       io.Writer
+	Hashes       map[string]string
+	HashWriter   *HashWriter
+	Segments     int
+	TotalSize    int64
+	FileSize     int64
+	// SegmentStates is the progress of the segments of an unfinished segmented download to resume
+	SegmentStates []SegmentStateObject
 }
 
 // FileMetadata defines the metadata accepted by the new object management API, Shepherd
@@ -104,17 +129,67 @@ type RetryObject struct {
 	Filename     string
 	FileMetadata FileMetadata
 	GUID         string
+	Error        string
 	RetryCount   int
 	Multipart    bool
 	Bucket 		 string
 }
 
+// DownloadRetryObject defines a object for retry download
+type DownloadRetryObject struct {
+	GUID         string
+	DownloadPath string
+	Filename     string
+	FileSize     int64
+	Error        string
+	RetryCount   int
+}
+
+// MultipartStateObject defines a object for recording the progress of a multipart upload, so it can be resumed
+type MultipartStateObject struct {
+	FilePath  string
+	Filename  string
+	GUID      string
+	UploadID  string
+	Key       string
+	Bucket    string
+	FileSize  int64
+	ModTime   time.Time
+	ChunkSize int64
+	ETags     map[int]string
+}
+
+// PartialDownloadStateObject defines a object for recording the progress of a segmented download, so it can be resumed
+type PartialDownloadStateObject struct {
+	FilePath  string
+	GUID      string
+	TotalSize int64
+	Segments  []SegmentStateObject
+}
+
+// SegmentStateObject defines a object for recording the progress of a byte range of a segmented download:
+// the bytes from Start to Start+Written-1 have been written into the partial file
+type SegmentStateObject struct {
+	Start   int64
+	End     int64
+	Written int64
+}
+
+// DeleteResultObject defines a object for recording the result of deleting a record
+type DeleteResultObject struct {
+	GUID     string `json:"guid"`
+	Filename string `json:"file_name,omitempty"`
+	Deleted  bool   `json:"deleted"`
+	Message  string `json:"message"`
+}
+
 // ParseRootPath parses dirname that has "~" in the beginning
 func ParseRootPath(filePath string) string {
 	if filePath != "" && filePath[0] == '~' {
 		homeDir, err := homedir.Dir()
 		if err != nil {
-			log.Fatalln(err)
+			log.Println("Error occurred when getting home directory: " + err.Error())
+			return filePath
 		}
 		return homeDir + filePath[1:]
 	}
@@ -142,10 +217,10 @@ func ParseFilePaths(filePath string, metadataEnabled bool) ([]string, error) {
 	filePaths = cleanupHiddenFiles(filePaths)
 
 	for _, filePath := range filePaths {
-		func() {
+		err = func() error {
 			file, err := os.Open(filePath)
 			if err != nil {
-				log.Fatal("File error for " + filePath)
+				return errors.New("File error for " + filePath + ": " + err.Error())
 			}
 			defer file.Close()
 
@@ -173,24 +248,31 @@ func ParseFilePaths(filePath string, metadataEnabled bool) ([]string, error) {
 				})
 			}
 			if err != nil {
-				log.Fatal("File walk error for " + filePath + " : " + err.Error())
+				return errors.New("File walk error for " + filePath + " : " + err.Error())
 			}
+			return nil
 		}()
+		if err != nil {
+			return nil, err
+		}
 	}
 	log.Println("Finish parsing all file paths for \"" + fullFilePath + "\"")
 	return filePaths, err
 }
 
-// AskForConfirmation asks user for confirmation before proceed, will wait if user entered garbage
+// AskForConfirmation asks user for confirmation before proceed, will wait if user entered garbage.
+// A failure to read the user's input is treated as a refusal
 func AskForConfirmation(s string) bool {
 	reader := bufio.NewReader(os.Stdin)
 
 	for {
-		fmt.Printf("%s [y/n]: ", s)
+		// the prompt isn't part of the output of the command
+		fmt.Fprintf(os.Stderr, "%s [y/n]: ", s)
 
 		response, err := reader.ReadString('\n')
 		if err != nil {
-			log.Fatal("Error occurred during parsing user's confirmation: " + err.Error())
+			log.Println("Error occurred during parsing user's confirmation: " + err.Error())
+			return false
 		}
 
 		response = strings.ToLower(strings.TrimSpace(response))
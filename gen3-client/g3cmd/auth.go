@@ -2,6 +2,7 @@ package g3cmd
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"sort"
 
@@ -9,6 +10,14 @@ import (
 	"github.com/uc-cdis/gen3-client/gen3-client/logs"
 )
 
+// AuthResult is the JSON output of the auth command
+type AuthResult struct {
+	Command        string                 `json:"command"`
+	Profile        string                 `json:"profile"`
+	Host           string                 `json:"host"`
+	ResourceAccess map[string]interface{} `json:"resource_access"`
+}
+
 func init() {
 
 	var authCmd = &cobra.Command{
@@ -16,16 +25,26 @@ func init() {
 		Short:   "Return resource access privileges from profile",
 		Long:    `Gets resource access privileges for specified profile.`,
 		Example: `./gen3-client auth --profile=<profile-name>`,
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			// don't initialize transmission logs for non-uploading related commands
 			logs.SetToBoth()
-			gen3Interface := NewGen3Interface()
-			profileConfig = conf.ParseConfig(profile)
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
 
-			host, resourceAccess, err := gen3Interface.CheckPrivileges(&profileConfig)
+			host, resourceAccess, err := client.Privileges(cmd.Context())
 
 			if err != nil {
-				log.Fatalf("Fatal authentication error: %s\n", err)
+				return fmt.Errorf("Fatal authentication error: %w", err)
+			} else if logs.IsJSONOutput() {
+				if resourceAccess == nil {
+					resourceAccess = map[string]interface{}{}
+				}
+				err = logs.PrintJSON(AuthResult{Command: cmd.Name(), Profile: profile, Host: host, ResourceAccess: resourceAccess})
+				if err != nil {
+					log.Println("Error occurred when printing results: " + err.Error())
+				}
 			} else {
 				if len(resourceAccess) == 0 {
 					log.Printf("\nYou don't currently have access to any resources at %s\n", host)
@@ -65,10 +84,10 @@ func init() {
 			if err != nil {
 				log.Println(err.Error())
 			}
+			return nil
 		},
 	}
 
 	authCmd.Flags().StringVar(&profile, "profile", "", "Specify the profile to check your access privileges")
-	authCmd.MarkFlagRequired("profile") // nolint: errcheck
 	RootCmd.AddCommand(authCmd)
 }
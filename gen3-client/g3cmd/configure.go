@@ -1,9 +1,11 @@
 package g3cmd
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-version"
 	"github.com/spf13/cobra"
@@ -13,24 +15,48 @@ import (
 )
 
 var conf jwt.Configure
-var req jwt.Request
+
+// settingPath returns the absolute path of a file given in a setting, which is left empty to unset it
+func settingPath(filePath string) string {
+	filePath = strings.TrimSpace(filePath)
+	if filePath == "" {
+		return ""
+	}
+	absolutePath, err := commonUtils.GetAbsolutePath(filePath)
+	if err != nil {
+		return filePath
+	}
+	return absolutePath
+}
 
 func init() {
 	var credFile string
 	var apiEndpoint string
 	var useShepherd string
 	var minShepherdVersion string
+	var secretStore string
+	var proxy string
+	var caCertFile string
+	var clientCertFile string
+	var clientKeyFile string
+	var connectTimeout time.Duration
+	var responseHeaderTimeout time.Duration
+	var idleConnTimeout time.Duration
+	var maxIdleConnsPerHost int
 	var configureCmd = &cobra.Command{
 		Use:   "configure",
 		Short: "Add or modify a configuration profile to your config file",
 		Long: `Configuration file located at ~/.gen3/gen3_client_config.ini
 	If a field is left empty, the existing value (if it exists) will remain unchanged`,
 		Example: `./gen3-client configure --profile=<profile-name> --cred=<path-to-credential/cred.json> --apiendpoint=https://data.mycommons.org`,
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			// don't initialize transmission logs for non-uploading related commands
 			logs.SetToBoth()
 
-			profileConfig := conf.ReadCredentials(credFile)
+			profileConfig, err := conf.ReadCredentials(credFile)
+			if err != nil {
+				return err
+			}
 			profileConfig.Profile = profile
 			apiEndpoint = strings.TrimSpace(apiEndpoint)
 			if apiEndpoint[len(apiEndpoint)-1:] == "/" {
@@ -38,20 +64,63 @@ func init() {
 			}
 			parsedURL, err := conf.ValidateUrl(apiEndpoint)
 			if err != nil {
-				log.Fatalln("Error occurred when validating apiendpoint URL: " + err.Error())
+				return &jwt.ConfigError{Err: errors.New("Error occurred when validating apiendpoint URL: " + err.Error())}
 			}
 
+			profileConfig.APIEndpoint = apiEndpoint
+			err = jwt.CheckAPIKeyExpiry(&profileConfig)
+			if err != nil {
+				return err
+			}
+
+			// the network settings that aren't given keep their current values
+			if existingConfig, err := conf.ReadProfile(profile); err == nil {
+				profileConfig.HTTP = existingConfig.HTTP
+			}
+			flags := cmd.Flags()
+			if flags.Changed("proxy") {
+				profileConfig.HTTP.Proxy = strings.TrimSpace(proxy)
+			}
+			if flags.Changed("ca-cert") {
+				profileConfig.HTTP.CACertFile = settingPath(caCertFile)
+			}
+			if flags.Changed("client-cert") {
+				profileConfig.HTTP.ClientCertFile = settingPath(clientCertFile)
+			}
+			if flags.Changed("client-key") {
+				profileConfig.HTTP.ClientKeyFile = settingPath(clientKeyFile)
+			}
+			if flags.Changed("connect-timeout") {
+				profileConfig.HTTP.ConnectTimeout = connectTimeout
+			}
+			if flags.Changed("response-header-timeout") {
+				profileConfig.HTTP.ResponseHeaderTimeout = responseHeaderTimeout
+			}
+			if flags.Changed("idle-conn-timeout") {
+				profileConfig.HTTP.IdleConnTimeout = idleConnTimeout
+			}
+			if flags.Changed("max-idle-conns-per-host") {
+				profileConfig.HTTP.MaxIdleConnsPerHost = maxIdleConnsPerHost
+			}
+			transport, err := profileConfig.HTTP.Transport()
+			if err != nil {
+				return &jwt.ConfigError{Err: errors.New("Error occurred when validating network settings: " + err.Error())}
+			}
+			req := jwt.Request{Transport: transport}
+
 			prefixEndPoint := parsedURL.Scheme + "://" + parsedURL.Host
-			err = req.RequestNewAccessToken(prefixEndPoint+commonUtils.FenceAccessTokenEndpoint, &profileConfig)
+			err = req.RequestNewAccessToken(cmd.Context(), prefixEndPoint+commonUtils.FenceAccessTokenEndpoint, &profileConfig)
 			if err != nil {
 				receivedErrorString := err.Error()
-				errorMessageString := receivedErrorString
+				var authError *jwt.AuthError
 				if strings.Contains(receivedErrorString, "401") {
-					errorMessageString = `Invalid credentials for apiendpoint '` + prefixEndPoint + `': check if your credentials are expired or incorrect`
+					return &jwt.AuthError{Err: errors.New("Error occurred when validating profile config: Invalid credentials for apiendpoint '" + prefixEndPoint + "': check if your credentials are expired or incorrect")}
 				} else if strings.Contains(receivedErrorString, "404") || strings.Contains(receivedErrorString, "405") || strings.Contains(receivedErrorString, "no such host") {
-					errorMessageString = `The provided apiendpoint '` + prefixEndPoint + `' is possibly not a valid Gen3 data commons`
+					return &jwt.ConfigError{Err: errors.New("Error occurred when validating profile config: The provided apiendpoint '" + prefixEndPoint + "' is possibly not a valid Gen3 data commons")}
+				} else if errors.As(err, &authError) {
+					return &jwt.AuthError{Err: errors.New("Error occurred when validating profile config: " + receivedErrorString)}
 				}
-				log.Fatalln("Error occurred when validating profile config: " + errorMessageString)
+				return errors.New("Error occurred when validating profile config: " + receivedErrorString)
 			}
 			profileConfig.APIEndpoint = apiEndpoint
 
@@ -61,28 +130,45 @@ func init() {
 			if minShepherdVersion != "" {
 				_, err = version.NewVersion(minShepherdVersion)
 				if err != nil {
-					log.Fatalln("Error occurred when validating minShepherdVersion: " + err.Error())
+					return &jwt.ConfigError{Err: errors.New("Error occurred when validating minShepherdVersion: " + err.Error())}
 				}
 			}
 			profileConfig.MinShepherdVersion = minShepherdVersion
+			secretStore = strings.TrimSpace(secretStore)
+			if _, err = conf.GetSecretStore(secretStore); err != nil {
+				return &jwt.ConfigError{Err: errors.New("Error occurred when validating secret store: " + err.Error())}
+			}
+			profileConfig.SecretStore = secretStore
 
 			// Store user info in ~/.gen3/gen3_client_config.ini
-			conf.UpdateConfigFile(profileConfig)
+			err = conf.UpdateConfigFile(profileConfig)
+			if err != nil {
+				return err
+			}
 			log.Println(`Profile '` + profile + `' has been configured successfully.`)
 			err = logs.CloseMessageLog()
 			if err != nil {
 				log.Println(err.Error())
 			}
+			return nil
 		},
 	}
 
 	configureCmd.Flags().StringVar(&profile, "profile", "", "Specify profile to use")
-	configureCmd.MarkFlagRequired("profile") //nolint:errcheck
-	configureCmd.Flags().StringVar(&credFile, "cred", "", "Specify the credential file that you want to use")
+	configureCmd.Flags().StringVar(&credFile, "cred", "", "Specify the credential file that you want to use, or \"-\" to read it from stdin")
 	configureCmd.MarkFlagRequired("cred") //nolint:errcheck
 	configureCmd.Flags().StringVar(&apiEndpoint, "apiendpoint", "", "Specify the API endpoint of the data commons")
 	configureCmd.MarkFlagRequired("apiendpoint") //nolint:errcheck
 	configureCmd.Flags().StringVar(&useShepherd, "use-shepherd", "", fmt.Sprintf("Enables or disables support for the Shepherd API. If enabled, gen3client will use the Shepherd API if available. (Default: %v)", commonUtils.DefaultUseShepherd))
 	configureCmd.Flags().StringVar(&minShepherdVersion, "min-shepherd-version", "", fmt.Sprintf("Specify the minimum version of Shepherd that the gen3client will use if Shepherd is enabled. (Default: %v)", commonUtils.DefaultMinShepherdVersion))
+	configureCmd.Flags().StringVar(&secretStore, "secret-store", "", fmt.Sprintf("Specify where to keep the API key and access token: %q, %q or %q (Default: %v)", jwt.SecretStorePlaintext, jwt.SecretStoreEncryptedFile, jwt.SecretStoreKeyring, jwt.SecretStorePlaintext))
+	configureCmd.Flags().StringVar(&proxy, "proxy", "", "Specify the URL of the proxy to send all requests through, or \"\" to use the HTTPS_PROXY and HTTP_PROXY environment variables")
+	configureCmd.Flags().StringVar(&caCertFile, "ca-cert", "", "Specify a PEM bundle of certificate authorities to trust in addition to the system ones, or \"\" to only trust the system ones")
+	configureCmd.Flags().StringVar(&clientCertFile, "client-cert", "", "Specify the PEM client certificate to present for mutual TLS, along with --client-key")
+	configureCmd.Flags().StringVar(&clientKeyFile, "client-key", "", "Specify the PEM private key of the client certificate")
+	configureCmd.Flags().DurationVar(&connectTimeout, "connect-timeout", 0, fmt.Sprintf("Specify how long establishing a connection may take, or 0 for the default (Default: %v)", commonUtils.DefaultConnectTimeout))
+	configureCmd.Flags().DurationVar(&responseHeaderTimeout, "response-header-timeout", 0, fmt.Sprintf("Specify how long the response to a request, including the transfers to the object storage, may take to start once the request has been sent, or 0 for the default (Default: %v)", commonUtils.DefaultResponseHeaderTimeout))
+	configureCmd.Flags().DurationVar(&idleConnTimeout, "idle-conn-timeout", 0, fmt.Sprintf("Specify how long an idle keep-alive connection is kept open, or 0 for the default (Default: %v)", commonUtils.DefaultIdleConnTimeout))
+	configureCmd.Flags().IntVar(&maxIdleConnsPerHost, "max-idle-conns-per-host", 0, fmt.Sprintf("Specify the number of idle keep-alive connections kept open to each host, or 0 for the default (Default: %v)", commonUtils.DefaultMaxIdleConnsPerHost))
 	RootCmd.AddCommand(configureCmd)
 }
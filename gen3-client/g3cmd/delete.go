@@ -1,34 +1,159 @@
 package g3cmd
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"log"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
 )
 
-//Not support yet, place holder only
-
-var deleteCmd = &cobra.Command{ // nolint:deadcode,unused,varcheck
-	Use:   "delete",
-	Short: "Send DELETE HTTP Request for given URI",
-	Long: `Deletes a given URI from the database.
-If no profile is specified, "default" profile is used for authentication.`,
-	Example: `./gen3-client delete --uri=v0/submission/bpa/test/entities/example_id
-	  ./gen3-client delete --profile=user1 --uri=v0/submission/bpa/test/entities/1af1d0ab-efec-4049-98f0-ae0f4bb1bc64`,
-	Run: func(cmd *cobra.Command, args []string) {
-		log.Fatalf("Not supported!")
-		// request := new(jwt.Request)
-		// configure := new(jwt.Configure)
-		// function := new(jwt.Functions)
-
-		// function.Config = configure
-		// function.Request = request
-
-		// fmt.Println(jwt.ResponseToString(
-		// 	function.DoRequestWithSignedHeader(RequestDelete, profile, "txt", uri)))
-	},
+// DeleteOptions are the options of the delete command
+type DeleteOptions struct {
+	// NumParallel is the number of deletions to run in parallel
+	NumParallel int
+	// NoPrompt deletes the records without asking for confirmation
+	NoPrompt bool
+	// DryRun only lists the records that would be deleted
+	DryRun bool
+}
+
+// DeleteRecords lists the records of objects that will be deleted along with their stored files, and deletes them once
+// the user has confirmed. Entries without GUID and repeated GUIDs are left out. The result of each GUID is written to
+// the delete log of the profile of client. ErrUserAbort is returned if the user has declined, and a PartialFailureError
+// if some of the records could not be deleted.
+func DeleteRecords(ctx context.Context, client *gen3.Client, objects []gen3.ManifestObject, options DeleteOptions) error {
+	seenGUIDs := make(map[string]bool)
+	toDelete := make([]gen3.ManifestObject, 0, len(objects))
+	for _, obj := range objects {
+		if obj.ObjectID == "" {
+			log.Println("Found empty object_id (GUID), skipping this entry")
+			continue
+		}
+		if seenGUIDs[obj.ObjectID] {
+			continue
+		}
+		seenGUIDs[obj.ObjectID] = true
+		toDelete = append(toDelete, obj)
+	}
+	if len(toDelete) == 0 {
+		log.Println("No record to delete")
+		return nil
+	}
+
+	fmt.Fprintf(logs.MessageOutput(), "\nThe following %d record(s) and their stored files will be deleted from \"%s\":\n", len(toDelete), client.Credential.APIEndpoint)
+	for _, obj := range toDelete {
+		if obj.Filename != "" {
+			fmt.Fprintf(logs.MessageOutput(), "\t%s (%s)\n", obj.ObjectID, obj.Filename)
+		} else {
+			fmt.Fprintln(logs.MessageOutput(), "\t"+obj.ObjectID)
+		}
+	}
+	fmt.Fprintln(logs.MessageOutput())
+	if options.DryRun {
+		log.Println("Dry run only, no record has been deleted")
+		for _, obj := range toDelete {
+			logs.RecordFileResult(obj.ObjectID, logs.FileResult{GUID: obj.ObjectID, Filename: obj.Filename, Status: logs.StatusSkipped})
+		}
+		return nil
+	}
+	if !options.NoPrompt && !commonUtils.AskForConfirmation("Deleted records cannot be recovered. Proceed?") {
+		return ErrUserAbort
+	}
+
+	results, err := client.Delete(ctx, toDelete, options.NumParallel)
+
+	deleted := 0
+	for _, result := range results {
+		if result.Deleted {
+			deleted++
+		}
+	}
+	log.Printf("%d of %d record(s) have been deleted\n", deleted, len(results))
+	if deleted < len(results) {
+		log.Printf("%d record(s) could not be deleted, see the delete log for details\n", len(results)-deleted)
+	}
+	if _, err := logs.WriteDeleteLog(client.Credential.Profile, results); err != nil {
+		log.Println("Error occurred when writing delete log: " + err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	if deleted < len(results) {
+		return &gen3.PartialFailureError{Failed: len(results) - deleted, Total: len(results)}
+	}
+	return nil
 }
 
 func init() {
-	// RootCmd.AddCommand(deleteCmd)
+	var guid string
+	var manifestPath string
+	var noPrompt bool
+	var dryRun bool
+	var numParallel int
+
+	var deleteCmd = &cobra.Command{
+		Use:   "delete",
+		Short: "Delete records and their stored files from a data commons",
+		Long: `Deletes the Indexd record of each given GUID along with its files in object storage.
+A single GUID can be given with --guid, or multiple GUIDs can be given in a manifest file with --manifest.
+Records are deleted through Shepherd if it is enabled and deployed, otherwise through Fence.`,
+		Example: `./gen3-client delete --profile=<profile-name> --guid=206dfaa6-bcf1-4bc9-b2d0-77179f0f48fc
+./gen3-client delete --profile=<profile-name> --manifest=<path-to-manifest/manifest.json> --numparallel=5
+./gen3-client delete --profile=<profile-name> --manifest=<path-to-manifest/manifest.json> --dry-run`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// don't initialize transmission logs for non-uploading related commands
+			logs.SetToBoth()
+
+			if (guid == "") == (manifestPath == "") {
+				return errors.New("Exactly one of \"--guid\" or \"--manifest\" must be provided")
+			}
+			if numParallel < 1 {
+				return errors.New("Invalid value for option \"numparallel\": must be a positive integer! Please check your input.")
+			}
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+
+			var objects []gen3.ManifestObject
+			if guid != "" {
+				objects = []gen3.ManifestObject{{ObjectID: strings.TrimSpace(guid)}}
+			} else {
+				manifestPath, _ = commonUtils.GetAbsolutePath(manifestPath)
+				manifestBytes, err := ioutil.ReadFile(manifestPath)
+				if err != nil {
+					return fmt.Errorf("Failed reading manifest %s, %v", manifestPath, err)
+				}
+				err = json.Unmarshal(manifestBytes, &objects)
+				if err != nil {
+					return fmt.Errorf("Error has occurred during unmarshalling manifest object: %v", err)
+				}
+			}
+
+			deleteErr := DeleteRecords(cmd.Context(), client, objects, DeleteOptions{NumParallel: numParallel, NoPrompt: noPrompt, DryRun: dryRun})
+			printCommandResult(cmd.Name())
+			err = logs.CloseMessageLog()
+			if err != nil {
+				log.Println(err.Error())
+			}
+			return deleteErr
+		},
+	}
+
+	deleteCmd.Flags().StringVar(&profile, "profile", "", "Specify profile to use")
+	deleteCmd.Flags().StringVar(&guid, "guid", "", "Specify the GUID of the record to delete")
+	deleteCmd.Flags().StringVar(&manifestPath, "manifest", "", "The manifest file containing the GUIDs (\"object_id\") of the records to delete")
+	deleteCmd.Flags().BoolVar(&noPrompt, "no-prompt", false, "If set to true, will not display user prompt message for confirmation")
+	deleteCmd.Flags().BoolVar(&dryRun, "dry-run", false, "If set to true, will only list the records that would be deleted")
+	deleteCmd.Flags().IntVar(&numParallel, "numparallel", 3, "Number of deletions to run in parallel")
+	RootCmd.AddCommand(deleteCmd)
 }
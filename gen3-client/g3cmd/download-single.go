@@ -4,6 +4,7 @@ import (
 	"log"
 
 	"github.com/spf13/cobra"
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
 	"github.com/uc-cdis/gen3-client/gen3-client/logs"
 )
 
@@ -14,32 +15,48 @@ func init() {
 	var filenameFormat string
 	var rename bool
 	var noPrompt bool
+	var segments int
 	var skipCompleted bool
+	var verifyChecksum bool
+	var deleteCorrupted bool
 
 	var downloadSingleCmd = &cobra.Command{
 		Use:     "download-single",
 		Short:   "Download a single file from a GUID",
 		Long:    `Gets a presigned URL for a file from a GUID and then downloads the specified file.`,
 		Example: `./gen3-client download-single --profile=<profile-name> --guid=206dfaa6-bcf1-4bc9-b2d0-77179f0f48fc`,
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			// don't initialize transmission logs for non-uploading related commands
 			logs.SetToBoth()
-			profileConfig = conf.ParseConfig(profile)
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+			err = initDownloadLogs(profile)
+			if err != nil {
+				return err
+			}
 
-			obj := ManifestObject{
+			obj := gen3.ManifestObject{
 				ObjectID: guid,
 			}
-			objects := []ManifestObject{obj}
-			downloadFile(objects, downloadPath, filenameFormat, rename, noPrompt, protocol, 1, skipCompleted)
-			err := logs.CloseMessageLog()
+			objects := []gen3.ManifestObject{obj}
+			options := gen3.DownloadOptions{DownloadPath: downloadPath, FilenameFormat: filenameFormat, Rename: rename, Protocol: protocol, NumParallel: 1, Segments: segments, SkipCompleted: skipCompleted, VerifyChecksum: verifyChecksum, DeleteCorrupted: deleteCorrupted}
+			downloadErr := downloadFile(cmd.Context(), client, gen3.NewManifestReaderFromObjects(objects), options, noPrompt)
+			printCommandResult(cmd.Name())
+			err = logs.CloseFailedDownloadLog()
+			if err != nil {
+				log.Println(err.Error())
+			}
+			err = logs.CloseMessageLog()
 			if err != nil {
 				log.Println(err.Error())
 			}
+			return downloadErr
 		},
 	}
 
 	downloadSingleCmd.Flags().StringVar(&profile, "profile", "", "Specify profile to use")
-	downloadSingleCmd.MarkFlagRequired("profile") //nolint:errcheck
 	downloadSingleCmd.Flags().StringVar(&guid, "guid", "", "Specify the guid for the data you would like to work with")
 	downloadSingleCmd.MarkFlagRequired("guid") //nolint:errcheck
 	downloadSingleCmd.Flags().StringVar(&downloadPath, "download-path", ".", "The directory in which to store the downloaded files")
@@ -47,6 +64,9 @@ func init() {
 	downloadSingleCmd.Flags().BoolVar(&rename, "rename", false, "Only useful when \"--filename-format=original\", will rename file by appending a counter value to its filename if set to true, otherwise the same filename will be used")
 	downloadSingleCmd.Flags().BoolVar(&noPrompt, "no-prompt", false, "If set to true, will not display user prompt message for confirmation")
 	downloadSingleCmd.Flags().StringVar(&protocol, "protocol", "", "Specify the preferred protocol with --protocol=gs")
+	downloadSingleCmd.Flags().IntVar(&segments, "segments", gen3.DefaultSegments, "Number of byte ranges to download the file in concurrently, if the storage server supports ranged requests")
 	downloadSingleCmd.Flags().BoolVar(&skipCompleted, "skip-completed", false, "If set to true, will check for filename and size before download and skip any files in \"download-path\" that matches both")
+	downloadSingleCmd.Flags().BoolVar(&verifyChecksum, "verify-checksum", true, "If set to true, will verify the md5 / sha256 checksum of the downloaded file against its Indexd record")
+	downloadSingleCmd.Flags().BoolVar(&deleteCorrupted, "delete-corrupted", false, "If set to true, will delete the downloaded file if it fails the checksum verification")
 	RootCmd.AddCommand(downloadSingleCmd)
 }
@@ -0,0 +1,53 @@
+package g3cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
+	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
+)
+
+// Exit codes of gen3-client
+const (
+	// ExitSuccess is returned when a command has completed without error
+	ExitSuccess = 0
+	// ExitError is returned for any error that doesn't have its own exit code
+	ExitError = 1
+	// ExitPartialFailure is returned when some but not all of the files or records of a command have failed
+	ExitPartialFailure = 2
+	// ExitAuthFailure is returned when the data commons has rejected the credential of the profile
+	ExitAuthFailure = 3
+	// ExitConfigError is returned when the profile or the config file is missing or invalid
+	ExitConfigError = 4
+	// ExitUserAbort is returned when the user has declined a confirmation prompt
+	ExitUserAbort = 5
+	// ExitInterrupted is returned when gen3-client has been stopped by SIGINT or SIGTERM before the command has completed
+	ExitInterrupted = 130
+)
+
+// ErrUserAbort is returned by a command when the user has declined to proceed
+var ErrUserAbort = errors.New("Aborted by user")
+
+// ExitCode returns the exit code of gen3-client for an error returned by a command
+func ExitCode(err error) int {
+	var partialFailureError *gen3.PartialFailureError
+	var authError *jwt.AuthError
+	var configError *jwt.ConfigError
+	switch {
+	case err == nil:
+		return ExitSuccess
+	case errors.Is(err, ErrUserAbort):
+		return ExitUserAbort
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	case errors.As(err, &partialFailureError):
+		return ExitPartialFailure
+	case errors.As(err, &authError):
+		return ExitAuthFailure
+	case errors.As(err, &configError):
+		return ExitConfigError
+	default:
+		return ExitError
+	}
+}
@@ -0,0 +1,45 @@
+package g3cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+)
+
+func init() {
+	var secretStore string
+	var migrateSecretsCmd = &cobra.Command{
+		Use:   "migrate-secrets",
+		Short: "Move the API key and access token of a profile out of the config file",
+		Long: `Moves the API key and access token of a profile into another secret store.
+Only a reference to the secret store is left in the profile section of ~/.gen3/gen3_client_config.ini.
+The passphrase of the encrypted file secret store can be given with the ` + jwt.EnvSecretPassphrase + ` environment variable.`,
+		Example: `./gen3-client migrate-secrets --profile=<profile-name> --secret-store=keyring`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// don't initialize transmission logs for non-uploading related commands
+			logs.SetToBoth()
+
+			secretStore = strings.TrimSpace(secretStore)
+			err := conf.MigrateSecrets(profile, secretStore)
+			if err != nil {
+				return &jwt.ConfigError{Err: errors.New("Error occurred when migrating secrets: " + err.Error())}
+			}
+			log.Println(`Secrets of profile '` + profile + `' have been moved to the ` + secretStore + ` secret store.`)
+			err = logs.CloseMessageLog()
+			if err != nil {
+				log.Println(err.Error())
+			}
+			return nil
+		},
+	}
+
+	migrateSecretsCmd.Flags().StringVar(&profile, "profile", "", "Specify profile to use")
+	migrateSecretsCmd.Flags().StringVar(&secretStore, "secret-store", "", fmt.Sprintf("Specify the secret store to move the secrets to: %q, %q or %q", jwt.SecretStorePlaintext, jwt.SecretStoreEncryptedFile, jwt.SecretStoreKeyring))
+	migrateSecretsCmd.MarkFlagRequired("secret-store") //nolint:errcheck
+	RootCmd.AddCommand(migrateSecretsCmd)
+}
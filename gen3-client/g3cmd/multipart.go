@@ -0,0 +1,101 @@
+package g3cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+)
+
+// listMultipartUploads loads the local multipart state of the profile, and the failed log if one is given,
+// and returns the unfinished multipart uploads found in them
+func listMultipartUploads(failedLogPath string) ([]gen3.UnfinishedMultipartUpload, error) {
+	err := logs.InitMultipartStateLog(profile)
+	if err != nil {
+		return nil, err
+	}
+	var failedLogMap map[string]commonUtils.RetryObject
+	if failedLogPath != "" {
+		err = logs.LoadFailedLogFile(commonUtils.ParseRootPath(failedLogPath))
+		if err != nil {
+			return nil, err
+		}
+		failedLogMap = logs.GetFailedLogMap()
+	}
+	return gen3.ListMultipartUploads(failedLogMap), nil
+}
+
+// describeParts returns the progress of a multipart upload as shown in the listing
+func describeParts(upload gen3.UnfinishedMultipartUpload) string {
+	if upload.TotalParts == 0 {
+		return "unknown"
+	}
+	return strconv.Itoa(upload.UploadedParts) + "/" + strconv.Itoa(upload.TotalParts)
+}
+
+func printMultipartUploads(uploads []gen3.UnfinishedMultipartUpload) {
+	if logs.IsJSONOutput() {
+		if err := logs.PrintJSON(uploads); err != nil {
+			log.Println("Error occurred when printing results: " + err.Error())
+		}
+		return
+	}
+	if len(uploads) == 0 {
+		log.Println("No unfinished multipart upload has been found")
+		return
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "FILE\tGUID\tUPLOAD ID\tPARTS\tSIZE\tFAILED")
+	for _, upload := range uploads {
+		uploadID := upload.UploadID
+		if uploadID == "" {
+			uploadID = "unknown"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%t\n", upload.FilePath, upload.GUID, uploadID, describeParts(upload), gen3.FormatSize(upload.FileSize), upload.Failed)
+	}
+	writer.Flush()
+}
+
+func init() {
+	var failedLogPath string
+
+	var multipartCmd = &cobra.Command{
+		Use:   "multipart",
+		Short: "Manage the unfinished multipart uploads",
+		Long: `Lists the multipart uploads that have been started and not completed, as recorded in the local multipart
+state of the profile and, with --failed-log-path, in a failed log.
+The storage keeps the uploaded parts of an unfinished multipart upload, and bills for them, until it's completed.
+The data commons cannot abort multipart uploads: the uploaded parts of the uploads that won't be resumed have to be
+cleaned up by an S3 lifecycle rule of the bucket that aborts incomplete multipart uploads (AbortIncompleteMultipartUpload).`,
+	}
+
+	var multipartListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the unfinished multipart uploads",
+		Long: `Lists the unfinished multipart uploads with their upload ID and the number of parts uploaded so far.
+The upload ID of an upload only found in the failed log is unknown, as it's only kept in the local multipart state.`,
+		Example: `./gen3-client multipart list --profile=<profile-name>
+./gen3-client multipart list --profile=<profile-name> --failed-log-path=<path-to-failed-log>`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs.SetToBoth()
+			uploads, err := listMultipartUploads(failedLogPath)
+			if err != nil {
+				return err
+			}
+			printMultipartUploads(uploads)
+			return nil
+		},
+	}
+
+	multipartListCmd.Flags().StringVar(&profile, "profile", "", "Specify profile to use")
+	multipartListCmd.Flags().StringVar(&failedLogPath, "failed-log-path", "", "The path to a failed log, whose multipart uploads are also included")
+	multipartCmd.AddCommand(multipartListCmd)
+	RootCmd.AddCommand(multipartCmd)
+}
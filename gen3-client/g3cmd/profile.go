@@ -0,0 +1,279 @@
+package g3cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+)
+
+// describeAPIKeyExpiry returns the expiry date of an API key as shown in the profile listing
+func describeAPIKeyExpiry(apiKey string) string {
+	if apiKey == "" {
+		return "no API key"
+	}
+	expiry := jwt.GetAPIKeyExpiry(apiKey)
+	if expiry.IsZero() {
+		return "unknown"
+	}
+	remaining := time.Until(expiry)
+	if remaining <= 0 {
+		return expiry.Format("2006-01-02") + " (expired)"
+	}
+	if remaining < jwt.APIKeyExpiryWarningPeriod {
+		return fmt.Sprintf("%v (in %d day(s))", expiry.Format("2006-01-02"), int(remaining.Hours()/24))
+	}
+	return expiry.Format("2006-01-02")
+}
+
+// redactSecret hides a secret in the output of "profile show", keeping whether it is set
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "<redacted>"
+}
+
+func printProfiles(config jwt.ConfigureInterface) error {
+	profiles, err := config.ListProfiles()
+	if err != nil {
+		return &jwt.ConfigError{Err: err}
+	}
+	defaultProfile, err := config.GetDefaultProfile()
+	if err != nil {
+		log.Println(err.Error())
+	}
+	writer := tabwriter.NewWriter(logs.MessageOutput(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "PROFILE\tAPI ENDPOINT\tAPI KEY EXPIRES\tDEFAULT")
+	for _, name := range profiles {
+		profileConfig, err := config.ReadProfile(name)
+		expiry := describeAPIKeyExpiry(profileConfig.APIKey)
+		if err != nil {
+			log.Println(err.Error())
+			expiry = "unreadable"
+		}
+		isDefault := ""
+		if name == defaultProfile {
+			isDefault = "*"
+		}
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\n", name, profileConfig.APIEndpoint, expiry, isDefault)
+	}
+	return writer.Flush()
+}
+
+// describeSetting describes a network setting of a profile, showing its default value if it hasn't been set
+func describeSetting(isSet bool, value interface{}, defaultValue interface{}) string {
+	if !isSet {
+		return fmt.Sprintf("%v (default)", defaultValue)
+	}
+	return fmt.Sprint(value)
+}
+
+func printProfile(config jwt.ConfigureInterface, name string) error {
+	profileConfig, err := config.ReadProfile(name)
+	if err != nil {
+		return &jwt.ConfigError{Err: err}
+	}
+	secretStore := profileConfig.SecretStore
+	if secretStore == "" {
+		secretStore = jwt.SecretStorePlaintext
+	}
+	writer := tabwriter.NewWriter(logs.MessageOutput(), 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "profile\t%v\n", profileConfig.Profile)
+	fmt.Fprintf(writer, "api_endpoint\t%v\n", profileConfig.APIEndpoint)
+	fmt.Fprintf(writer, "key_id\t%v\n", profileConfig.KeyId)
+	fmt.Fprintf(writer, "api_key\t%v\n", redactSecret(profileConfig.APIKey))
+	fmt.Fprintf(writer, "api_key_expires\t%v\n", describeAPIKeyExpiry(profileConfig.APIKey))
+	fmt.Fprintf(writer, "access_token\t%v\n", redactSecret(profileConfig.AccessToken))
+	fmt.Fprintf(writer, "use_shepherd\t%v\n", profileConfig.UseShepherd)
+	fmt.Fprintf(writer, "min_shepherd_version\t%v\n", profileConfig.MinShepherdVersion)
+	fmt.Fprintf(writer, "secret_store\t%v\n", secretStore)
+	fmt.Fprintf(writer, "proxy\t%v\n", profileConfig.HTTP.Proxy)
+	fmt.Fprintf(writer, "ca_cert_file\t%v\n", profileConfig.HTTP.CACertFile)
+	fmt.Fprintf(writer, "client_cert_file\t%v\n", profileConfig.HTTP.ClientCertFile)
+	fmt.Fprintf(writer, "client_key_file\t%v\n", profileConfig.HTTP.ClientKeyFile)
+	fmt.Fprintf(writer, "connect_timeout\t%v\n", describeSetting(profileConfig.HTTP.ConnectTimeout != 0, profileConfig.HTTP.ConnectTimeout, commonUtils.DefaultConnectTimeout))
+	fmt.Fprintf(writer, "response_header_timeout\t%v\n", describeSetting(profileConfig.HTTP.ResponseHeaderTimeout != 0, profileConfig.HTTP.ResponseHeaderTimeout, commonUtils.DefaultResponseHeaderTimeout))
+	fmt.Fprintf(writer, "idle_conn_timeout\t%v\n", describeSetting(profileConfig.HTTP.IdleConnTimeout != 0, profileConfig.HTTP.IdleConnTimeout, commonUtils.DefaultIdleConnTimeout))
+	fmt.Fprintf(writer, "max_idle_conns_per_host\t%v\n", describeSetting(profileConfig.HTTP.MaxIdleConnsPerHost != 0, profileConfig.HTTP.MaxIdleConnsPerHost, commonUtils.DefaultMaxIdleConnsPerHost))
+	return writer.Flush()
+}
+
+// validateProfile exchanges the API key of a profile for an access token at Fence, by default the one of the profile,
+// through the network settings of the profile
+func validateProfile(ctx context.Context, config jwt.ConfigureInterface, name string, fenceEndpoint string) error {
+	profileConfig, err := config.ParseConfig(name)
+	if err != nil {
+		return err
+	}
+	transport, err := profileConfig.HTTP.Transport()
+	if err != nil {
+		return &jwt.ConfigError{Err: err}
+	}
+	request := &jwt.Request{Transport: transport}
+	if profileConfig.APIKey == "" {
+		return &jwt.ConfigError{Err: fmt.Errorf("Profile '%v' has no API key to exchange for an access token", name)}
+	}
+	if fenceEndpoint == "" {
+		fenceEndpoint = profileConfig.APIEndpoint
+	}
+	parsedURL, err := config.ValidateUrl(strings.TrimSuffix(strings.TrimSpace(fenceEndpoint), "/"))
+	if err != nil {
+		return &jwt.ConfigError{Err: err}
+	}
+	prefixEndPoint := parsedURL.Scheme + "://" + parsedURL.Host
+	err = request.RequestNewAccessToken(ctx, prefixEndPoint+commonUtils.FenceAccessTokenEndpoint, &profileConfig)
+	if err != nil {
+		return fmt.Errorf("Token exchange at %v failed: %w", prefixEndPoint, err)
+	}
+	log.Printf("Profile '%v' is valid: its API key has been exchanged for an access token at %v\n", name, prefixEndPoint)
+	return nil
+}
+
+func init() {
+	var fenceEndpoint string
+
+	var profileCmd = &cobra.Command{
+		Use:   "profile",
+		Short: "Manage the profiles of your config file",
+		Long: `Lists, shows, removes, renames, copies and validates the profiles in ~/.gen3/gen3_client_config.ini,
+and sets the default profile.
+Use the configure command to add a profile.`,
+	}
+
+	var profileListCmd = &cobra.Command{
+		Use:     "list",
+		Short:   "List the configured profiles",
+		Long:    `Lists the profiles with their API endpoint and the expiry date of their API key.`,
+		Example: `./gen3-client profile list`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs.SetToBoth()
+			return printProfiles(&conf)
+		},
+	}
+
+	var profileShowCmd = &cobra.Command{
+		Use:     "show <profile>",
+		Short:   "Show the settings of a profile",
+		Long:    `Shows the settings of a profile. The API key and access token are redacted.`,
+		Example: `./gen3-client profile show myprofile`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs.SetToBoth()
+			return printProfile(&conf, args[0])
+		},
+	}
+
+	var profileRemoveCmd = &cobra.Command{
+		Use:     "remove <profile>",
+		Short:   "Remove a profile",
+		Long:    `Removes a profile from the config file, along with its secrets in its secret store.`,
+		Example: `./gen3-client profile remove myprofile`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs.SetToBoth()
+			err := conf.RemoveProfile(args[0])
+			if err != nil {
+				return &jwt.ConfigError{Err: errors.New("Error occurred when removing profile: " + err.Error())}
+			}
+			log.Println(`Profile '` + args[0] + `' has been removed.`)
+			return nil
+		},
+	}
+
+	var profileRenameCmd = &cobra.Command{
+		Use:     "rename <profile> <new-profile>",
+		Short:   "Rename a profile",
+		Long:    `Renames a profile, moving its secrets along with it.`,
+		Example: `./gen3-client profile rename myprofile mycommons`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs.SetToBoth()
+			err := conf.RenameProfile(args[0], args[1])
+			if err != nil {
+				return &jwt.ConfigError{Err: errors.New("Error occurred when renaming profile: " + err.Error())}
+			}
+			log.Println(`Profile '` + args[0] + `' has been renamed to '` + args[1] + `'.`)
+			return nil
+		},
+	}
+
+	var profileCopyCmd = &cobra.Command{
+		Use:     "copy <profile> <new-profile>",
+		Short:   "Copy a profile",
+		Long:    `Copies a profile and its secrets to a new profile, for example to change its settings afterwards with the configure command.`,
+		Example: `./gen3-client profile copy myprofile myprofile-shepherd`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs.SetToBoth()
+			err := conf.CopyProfile(args[0], args[1])
+			if err != nil {
+				return &jwt.ConfigError{Err: errors.New("Error occurred when copying profile: " + err.Error())}
+			}
+			log.Println(`Profile '` + args[0] + `' has been copied to '` + args[1] + `'.`)
+			return nil
+		},
+	}
+
+	var profileSetDefaultCmd = &cobra.Command{
+		Use:   "set-default [profile]",
+		Short: "Set the profile to use when none is given",
+		Long: `Sets the profile used by the commands run without --profile, or unsets it if no profile is given.
+The ` + EnvProfile + ` environment variable and the profile pinned by a ` + ProjectConfigFileName + ` project config take precedence over it.`,
+		Example: `./gen3-client profile set-default myprofile`,
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs.SetToBoth()
+			defaultProfile := ""
+			if len(args) > 0 {
+				defaultProfile = args[0]
+			}
+			err := conf.SetDefaultProfile(defaultProfile)
+			if err != nil {
+				return &jwt.ConfigError{Err: errors.New("Error occurred when setting default profile: " + err.Error())}
+			}
+			if defaultProfile == "" {
+				log.Println("The default profile has been unset.")
+			} else {
+				log.Println(`Profile '` + defaultProfile + `' is now the default profile.`)
+			}
+			return nil
+		},
+	}
+
+	var profileValidateCmd = &cobra.Command{
+		Use:   "validate <profile>",
+		Short: "Check that the API key of a profile can be exchanged for an access token",
+		Long: `Exchanges the API key of a profile for an access token at Fence. The Fence of the profile's API endpoint is used,
+unless another one, e.g. a local Fence, is given with --fence.`,
+		Example: `./gen3-client profile validate myprofile
+./gen3-client profile validate myprofile --fence=http://localhost:8000`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs.SetToBoth()
+			return validateProfile(cmd.Context(), &conf, args[0], fenceEndpoint)
+		},
+	}
+	profileValidateCmd.Flags().StringVar(&fenceEndpoint, "fence", "", "Specify the URL of the Fence to exchange the API key at, instead of the profile's API endpoint")
+
+	var profilesCmd = &cobra.Command{
+		Use:     "profiles",
+		Short:   "List the configured profiles (same as \"profile list\")",
+		Long:    `Lists the profiles in ~/.gen3/gen3_client_config.ini with their API endpoint and the expiry date of their API key.`,
+		Example: `./gen3-client profiles`,
+		Args:    cobra.NoArgs,
+		RunE:    profileListCmd.RunE,
+	}
+
+	profileCmd.AddCommand(profileListCmd, profileShowCmd, profileRemoveCmd, profileRenameCmd, profileCopyCmd, profileSetDefaultCmd, profileValidateCmd)
+	RootCmd.AddCommand(profileCmd, profilesCmd)
+}
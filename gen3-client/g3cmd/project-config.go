@@ -0,0 +1,152 @@
+package g3cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfigFileName is the name of the per-project config file, looked up from the current directory upwards
+const ProjectConfigFileName = ".gen3client.yaml"
+
+// EnvProfile selects the profile to use when --profile isn't given
+const EnvProfile = "GEN3_PROFILE"
+
+// ProjectConfig pins settings for all the commands run in a project directory. Its fields are named after the
+// flags they set, and flags given on the command line take precedence over them.
+type ProjectConfig struct {
+	Profile             string `yaml:"profile"`
+	Bucket              string `yaml:"bucket"`
+	DownloadPath        string `yaml:"download-path"`
+	FilenameFormat      string `yaml:"filename-format"`
+	NumParallel         int    `yaml:"numparallel"`
+	LimitRate           string `yaml:"limit-rate"`
+	LimitRateSchedule   string `yaml:"limit-rate-schedule"`
+	AdaptiveConcurrency bool   `yaml:"adaptive-concurrency"`
+	MinParallel         int    `yaml:"min-parallel"`
+	MaxParallel         int    `yaml:"max-parallel"`
+}
+
+// FindProjectConfig walks up from dir to the root of the file system and returns the path of the first project
+// config file found, or an empty string if there is none
+func FindProjectConfig(dir string) string {
+	for {
+		configPath := filepath.Join(dir, ProjectConfigFileName)
+		if info, err := os.Stat(configPath); err == nil && !info.IsDir() {
+			return configPath
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// LoadProjectConfig reads a project config file. A relative download path is relative to the directory of the file.
+func LoadProjectConfig(configPath string) (ProjectConfig, error) {
+	var projectConfig ProjectConfig
+	content, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return projectConfig, errors.New("Error occurred when reading project config file: " + err.Error())
+	}
+	err = yaml.Unmarshal(content, &projectConfig)
+	if err != nil {
+		return projectConfig, errors.New("Error occurred when parsing project config file " + configPath + ": " + err.Error())
+	}
+	if projectConfig.DownloadPath != "" && !filepath.IsAbs(projectConfig.DownloadPath) {
+		projectConfig.DownloadPath = filepath.Join(filepath.Dir(configPath), projectConfig.DownloadPath)
+	}
+	return projectConfig, nil
+}
+
+var projectConfig *ProjectConfig
+
+// getProjectConfig returns the project config of the current directory, which is empty if there is none
+func getProjectConfig() (ProjectConfig, error) {
+	if projectConfig != nil {
+		return *projectConfig, nil
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		projectConfig = &ProjectConfig{}
+		return *projectConfig, nil
+	}
+	configPath := FindProjectConfig(workingDir)
+	if configPath == "" {
+		projectConfig = &ProjectConfig{}
+		return *projectConfig, nil
+	}
+	loadedConfig, err := LoadProjectConfig(configPath)
+	if err != nil {
+		return loadedConfig, &jwt.ConfigError{Err: err}
+	}
+	projectConfig = &loadedConfig
+	return *projectConfig, nil
+}
+
+// resolveProfile returns the profile to use when --profile isn't given: the one from the GEN3_PROFILE
+// environment variable, then the one pinned by the project config, then the default profile of the config file
+func resolveProfile() (string, error) {
+	if envProfile := os.Getenv(EnvProfile); envProfile != "" {
+		return envProfile, nil
+	}
+	projectConfig, err := getProjectConfig()
+	if err != nil {
+		return "", err
+	}
+	if projectConfig.Profile != "" {
+		return projectConfig.Profile, nil
+	}
+	defaultProfile, err := conf.GetDefaultProfile()
+	if err != nil {
+		log.Println(err.Error())
+	}
+	return defaultProfile, nil
+}
+
+// applyProjectConfig sets the flags of a command that haven't been given on the command line from the project config
+func applyProjectConfig(cmd *cobra.Command) error {
+	projectConfig, err := getProjectConfig()
+	if err != nil {
+		return err
+	}
+	settings := map[string]string{
+		"bucket":              projectConfig.Bucket,
+		"download-path":       projectConfig.DownloadPath,
+		"filename-format":     projectConfig.FilenameFormat,
+		"limit-rate":          projectConfig.LimitRate,
+		"limit-rate-schedule": projectConfig.LimitRateSchedule,
+	}
+	if projectConfig.NumParallel > 0 {
+		settings["numparallel"] = strconv.Itoa(projectConfig.NumParallel)
+	}
+	if projectConfig.AdaptiveConcurrency {
+		settings["adaptive-concurrency"] = "true"
+	}
+	if projectConfig.MinParallel > 0 {
+		settings["min-parallel"] = strconv.Itoa(projectConfig.MinParallel)
+	}
+	if projectConfig.MaxParallel > 0 {
+		settings["max-parallel"] = strconv.Itoa(projectConfig.MaxParallel)
+	}
+	for name, value := range settings {
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil || flag.Changed || value == "" {
+			continue
+		}
+		err := cmd.Flags().Set(name, value)
+		if err != nil {
+			return &jwt.ConfigError{Err: fmt.Errorf("Invalid %v in %v: %v", name, ProjectConfigFileName, err.Error())}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package g3cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+)
+
+func init() {
+	var failedLogPath string
+	var protocol string
+	var numParallel int
+	var segments int
+	var skipCompleted bool
+	var verifyChecksum bool
+	var deleteCorrupted bool
+
+	var retryDownloadCmd = &cobra.Command{
+		Use:   "retry-download",
+		Short: "Retry downloading file(s) from a failed download log.",
+		Long: `Re-download the files found in a given failed download log, to the same paths and under the same filenames.
+Large files that have been downloaded in segments are resumed from the bytes already in their partial file.
+Files that still fail are recorded in a new failed download log.`,
+		Example: "For retrying file download:\n./gen3-client retry-download --profile=<profile-name> --failed-log-path=<path-to-failed-download-log>\n",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// don't initialize transmission logs for non-uploading related commands
+			logs.SetToBoth()
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+			err = initDownloadLogs(profile)
+			if err != nil {
+				return err
+			}
+
+			failedLogPath = commonUtils.ParseRootPath(failedLogPath)
+			err = logs.LoadFailedDownloadLogFile(failedLogPath)
+			if err != nil {
+				return err
+			}
+			options := gen3.DownloadOptions{Protocol: protocol, NumParallel: numParallel, Segments: segments, SkipCompleted: skipCompleted, VerifyChecksum: verifyChecksum, DeleteCorrupted: deleteCorrupted}
+			downloadErr := client.RetryDownload(cmd.Context(), logs.GetFailedDownloadLogMap(), options)
+			printCommandResult(cmd.Name())
+			err = logs.CloseFailedDownloadLog()
+			if err != nil {
+				log.Println(err.Error())
+			}
+			err = logs.CloseMessageLog()
+			if err != nil {
+				log.Println(err.Error())
+			}
+			return downloadErr
+		},
+	}
+
+	retryDownloadCmd.Flags().StringVar(&profile, "profile", "", "Specify profile to use")
+	retryDownloadCmd.Flags().StringVar(&failedLogPath, "failed-log-path", "", "The path to the failed download log file.")
+	retryDownloadCmd.MarkFlagRequired("failed-log-path") //nolint:errcheck
+	retryDownloadCmd.Flags().StringVar(&protocol, "protocol", "", "Specify the preferred protocol with --protocol=s3")
+	retryDownloadCmd.Flags().IntVar(&numParallel, "numparallel", 1, "Number of downloads to run in parallel")
+	retryDownloadCmd.Flags().IntVar(&segments, "segments", gen3.DefaultSegments, "Number of byte ranges to download each large file in concurrently, if the storage server supports ranged requests")
+	retryDownloadCmd.Flags().BoolVar(&skipCompleted, "skip-completed", false, "If set to true, will skip files that are already complete locally and resume the partially downloaded ones")
+	retryDownloadCmd.Flags().BoolVar(&verifyChecksum, "verify-checksum", true, "If set to true, will verify the md5 / sha256 checksum of each downloaded file against its Indexd record")
+	retryDownloadCmd.Flags().BoolVar(&deleteCorrupted, "delete-corrupted", false, "If set to true, will delete downloaded files that fail the checksum verification")
+	RootCmd.AddCommand(retryDownloadCmd)
+}
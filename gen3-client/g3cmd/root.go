@@ -1,11 +1,15 @@
 package g3cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	latest "github.com/tcnksm/go-latest"
@@ -14,22 +18,72 @@ import (
 )
 
 var profile string
-var profileConfig jwt.Credential
+var outputFormat string
+var limitRate string
+var limitRateSchedule string
+var adaptiveConcurrency bool
+var minParallel int
+var maxParallel int
+
+// initErr is the error that has occurred when initializing the logs and the config file, returned by the command
+var initErr error
 
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
-	Use:     "gen3-client",
-	Short:   "Use the gen3-client to interact with a Gen3 Data Commons",
-	Long:    "Gen3 Client for downloading, uploading and submitting data to data commons.\ngen3-client version: " + gitversion + ", commit: " + gitcommit,
-	Version: gitversion,
+	Use:   "gen3-client",
+	Short: "Use the gen3-client to interact with a Gen3 Data Commons",
+	Long: "Gen3 Client for downloading, uploading and submitting data to data commons.\ngen3-client version: " + gitversion + ", commit: " + gitcommit + `
+
+Exit codes:
+  0  success
+  1  error
+  2  some of the files or records have failed
+  3  authentication failure
+  4  missing or invalid profile or config file
+  5  aborted by user
+  130  interrupted by SIGINT or SIGTERM, the logs have been saved and the command can be resumed`,
+	Version:       gitversion,
+	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// the flags have been parsed, errors from here on aren't usage errors
+		cmd.SilenceUsage = true
+		if err := logs.SetOutputFormat(outputFormat); err != nil {
+			return err
+		}
+		if initErr != nil {
+			return initErr
+		}
+		if err := applyProjectConfig(cmd); err != nil {
+			return err
+		}
+		// the commands that work on a profile declare their own --profile flag
+		if profile == "" && cmd.Flags().Lookup("profile") != cmd.InheritedFlags().Lookup("profile") {
+			return &jwt.ConfigError{Err: errors.New("No profile given. Use --profile, set " + EnvProfile + ", pin one in " + ProjectConfigFileName + " or set a default profile with \"gen3-client profile set-default <profile>\"")}
+		}
+		return nil
+	},
 }
 
 // Execute adds all child commands to the root command sets flags appropriately
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// It exits with the exit code of the error returned by the command, see ExitCode.
+// On SIGINT or SIGTERM the context of the command is cancelled, so that it stops scheduling new transfers and saves
+// its logs before exiting with ExitInterrupted. A second signal terminates gen3-client right away.
 func Execute() {
-	if err := RootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		// restore the default behavior so that the next signal terminates gen3-client
+		stop()
+		fmt.Fprintln(os.Stderr, "Interrupted, stopping the transfers and saving the logs. Interrupt again to exit immediately.")
+	}()
+
+	if err := RootCmd.ExecuteContext(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: "+err.Error())
+		if ctx.Err() != nil {
+			os.Exit(ExitInterrupted)
+		}
+		os.Exit(ExitCode(err))
 	}
 }
 
@@ -38,19 +92,46 @@ func init() {
 
 	// Define flags and configuration settings.
 	RootCmd.PersistentFlags().StringVar(&profile, "profile", "", "Specify profile to use")
-	_ = RootCmd.MarkFlagRequired("profile")
+	RootCmd.PersistentFlags().StringVar(&outputFormat, "output", logs.OutputText, "The output format, \"text\" or \"json\". With \"json\" the results are printed as JSON on stdout and progress bars are disabled")
+	RootCmd.PersistentFlags().StringVar(&limitRate, "limit-rate", "", "Cap the total throughput of all the uploads or downloads of the command, for example \"200MB/s\". No limit by default")
+	RootCmd.PersistentFlags().StringVar(&limitRateSchedule, "limit-rate-schedule", "", "Override --limit-rate during times of day, as a comma separated list such as \"19:00-07:00=1GB/s,12:00-13:00=0\" (local time, 0 for no limit)")
+	RootCmd.PersistentFlags().BoolVar(&adaptiveConcurrency, "adaptive-concurrency", false, "Tune the number of uploads, downloads and multipart parts in flight from the measured throughput, backing off when throttled (429/503). Overrides --numparallel")
+	RootCmd.PersistentFlags().IntVar(&minParallel, "min-parallel", 1, "Lowest number of transfers in flight with --adaptive-concurrency")
+	RootCmd.PersistentFlags().IntVar(&maxParallel, "max-parallel", 16, "Highest number of transfers in flight with --adaptive-concurrency")
+}
+
+// printCommandResult prints the results of the files a command has uploaded, downloaded or deleted if the output format is JSON
+func printCommandResult(command string) {
+	if !logs.IsJSONOutput() {
+		return
+	}
+	err := logs.PrintJSON(logs.NewCommandResult(command, profile))
+	if err != nil {
+		log.Println("Error occurred when printing results: " + err.Error())
+	}
 }
 
 func initConfig() {
+	if profile == "" {
+		profile, initErr = resolveProfile()
+		if initErr != nil {
+			return
+		}
+	}
 
-	logs.Init()
-	logs.InitMessageLog(profile)
+	if initErr = logs.Init(); initErr != nil {
+		return
+	}
+	if initErr = logs.InitMessageLog(profile); initErr != nil {
+		return
+	}
 	logs.SetToBoth()
 
 	// init local config file
 	err := conf.InitConfigFile()
 	if err != nil {
-		log.Fatalln("Error occurred when trying to init config file: " + err.Error())
+		initErr = &jwt.ConfigError{Err: errors.New("Error occurred when trying to init config file: " + err.Error())}
+		return
 	}
 
 	// version checker
@@ -3,6 +3,7 @@ package g3cmd
 // Deprecated: Use upload instead.
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -12,6 +13,8 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
+	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
 	"github.com/uc-cdis/gen3-client/gen3-client/logs"
 )
 
@@ -23,38 +26,48 @@ func init() {
 	var numParallel int
 	var forceMultipart bool
 	var includeSubDirName bool
+	var computeSHA256 bool
+	var chunkSize string
 
 	var uploadMultipleCmd = &cobra.Command{
 		Use:     "upload-multiple",
 		Short:   "Upload multiple of files from a specified manifest",
 		Long:    `Get presigned URLs for multiple of files specified in a manifest file and then upload all of them. Options to run multipart uploads for large files and running multiple workers to batch upload available.`,
 		Example: `./gen3-client upload-multiple --profile=<profile-name> --manifest=<path-to-manifest/manifest.json> --upload-path=<path-to-file-dir/> --bucket=<bucket-name> --force-multipart=<boolean> --include-subdirname=<boolean> --batch=<boolean>`,
-		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Printf("Notice: this is the upload method which requires the user to provide GUIDs. In this method files will be uploaded to specified GUIDs.\nIf your intention is to upload files without pre-existing GUIDs, consider to use \"./gen3-client upload\" instead.\n\n")
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fmt.Fprintf(logs.MessageOutput(), "Notice: this is the upload method which requires the user to provide GUIDs. In this method files will be uploaded to specified GUIDs.\nIf your intention is to upload files without pre-existing GUIDs, consider to use \"./gen3-client upload\" instead.\n\n")
 
-			// Instantiate interface to Gen3
-			gen3Interface := NewGen3Interface()
-			profileConfig = conf.ParseConfig(profile)
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+			client.ComputeSHA256 = computeSHA256
+			client.MultipartChunkSize, err = gen3.ParseSize(chunkSize)
+			if err != nil {
+				return errors.New("Invalid value for option \"chunk-size\": " + err.Error())
+			}
 
-			host, err := gen3Interface.GetHost(&profileConfig)
+			host, err := client.Gen3Interface.GetHost(&client.Credential)
 			if err != nil {
-				log.Fatalln("Error occurred during parsing config file for hostname: " + err.Error())
+				return &jwt.ConfigError{Err: errors.New("Error occurred during parsing config file for hostname: " + err.Error())}
 			}
 			dataExplorerURL := host.Scheme + "://" + host.Host + "/explorer"
 
-			var objects []ManifestObject
+			var objects []gen3.ManifestObject
 
 			// initialize transmission logs
-			logs.InitSucceededLog(profile)
-			logs.InitFailedLog(profile)
+			err = initTransmissionLogs(profile)
+			if err != nil {
+				return err
+			}
 			logs.SetToBoth()
-			logs.InitScoreBoard(MaxRetryCount)
+			logs.InitScoreBoard(gen3.MaxRetryCount)
 			logs.InitScoreBoard(0)
 
 			manifestFile, err := os.Open(manifestPath)
 			if err != nil {
 				log.Println("Failed to open manifest file")
-				log.Fatalln("A valid manifest can be acquired by using the \"Download Manifest\" button on " + dataExplorerURL)
+				return errors.New("A valid manifest can be acquired by using the \"Download Manifest\" button on " + dataExplorerURL)
 			}
 			defer manifestFile.Close()
 
@@ -63,20 +76,20 @@ func init() {
 				manifestBytes, err := ioutil.ReadFile(manifestPath)
 				if err != nil {
 					log.Printf("Failed reading manifest %s, %v\n", manifestPath, err)
-					log.Fatalln("A valid manifest can be acquired by using the \"Download Manifest\" button on " + dataExplorerURL)
+					return errors.New("A valid manifest can be acquired by using the \"Download Manifest\" button on " + dataExplorerURL)
 				}
 				err = json.Unmarshal(manifestBytes, &objects)
 				if err != nil {
-					log.Fatalln("Unmarshalling manifest failed with error: " + err.Error())
+					return errors.New("Unmarshalling manifest failed with error: " + err.Error())
 				}
 			default:
 				log.Println("Unsupported manifast format")
-				log.Fatalln("A valid manifest can be acquired by using the \"Download Manifest\" button on " + dataExplorerURL)
+				return errors.New("A valid manifest can be acquired by using the \"Download Manifest\" button on " + dataExplorerURL)
 			}
 
 			uploadPath, err := commonUtils.GetAbsolutePath(uploadPath)
 			if err != nil {
-				log.Fatalf("Error when parsing file paths: " + err.Error())
+				return errors.New("Error when parsing file paths: " + err.Error())
 			}
 
 			filePaths := make([]string, 0)
@@ -98,46 +111,16 @@ func init() {
 				filePaths = append(filePaths, filePath)
 			}
 
-			singlePartFilePaths, multipartFilePaths := separateSingleAndMultipartUploads(filePaths, forceMultipart)
-
-			if batch {
-				workers, respCh, errCh, batchFURObjects := initBatchUploadChannels(numParallel, len(singlePartFilePaths))
-				for i, filePath := range singlePartFilePaths {
-					fileInfo, err := ProcessFilename(uploadPath, filePath, includeSubDirName, false)
-					if err != nil {
-						logs.AddToFailedLog(filePath, filepath.Base(filePath), commonUtils.FileMetadata{}, "", 0, false, true)
-						log.Println("Process filename error: " + err.Error())
-						return
-					}
-					if len(batchFURObjects) < workers {
-						furObject := commonUtils.FileUploadRequestObject{FilePath: fileInfo.FilePath, Filename: fileInfo.Filename, FileMetadata: fileInfo.FileMetadata, GUID: ""}
-						batchFURObjects = append(batchFURObjects, furObject) //nolint:ineffassign
-					} else {
-						batchUpload(gen3Interface, batchFURObjects, workers, respCh, errCh, bucketName)
-						batchFURObjects = make([]commonUtils.FileUploadRequestObject, 0)
-						furObject := commonUtils.FileUploadRequestObject{FilePath: fileInfo.FilePath, Filename: fileInfo.Filename, FileMetadata: fileInfo.FileMetadata, GUID: ""}
-						batchFURObjects = append(batchFURObjects, furObject) //nolint:ineffassign
-					}
-					if !forceMultipart && i == len(singlePartFilePaths)-1 { // upload remainders
-						batchUpload(gen3Interface, batchFURObjects, workers, respCh, errCh, bucketName)
-					}
-				}
-			} else {
-				processSingleUploads(gen3Interface, singlePartFilePaths, bucketName, includeSubDirName, uploadPath)
-			}
-			if len(multipartFilePaths) > 0 {
-				processMultipartUpload(gen3Interface, multipartFilePaths, bucketName, includeSubDirName, uploadPath)
-			}
-			if !logs.IsFailedLogMapEmpty() {
-				retryUpload(logs.GetFailedLogMap())
-			}
+			options := gen3.UploadOptions{UploadPath: uploadPath, Bucket: bucketName, Batch: batch, NumParallel: numParallel, ForceMultipart: forceMultipart, IncludeSubDirName: includeSubDirName}
+			uploadErr := client.Upload(cmd.Context(), filePaths, options)
+			printCommandResult(cmd.Name())
 			logs.PrintScoreBoard()
 			logs.CloseAll()
+			return uploadErr
 		},
 	}
 
 	uploadMultipleCmd.Flags().StringVar(&profile, "profile", "", "Specify profile to use")
-	uploadMultipleCmd.MarkFlagRequired("profile") //nolint:errcheck
 	uploadMultipleCmd.Flags().StringVar(&manifestPath, "manifest", "", "The manifest file to read from. A valid manifest can be acquired by using the \"Download Manifest\" button in Data Explorer for Common portal")
 	uploadMultipleCmd.MarkFlagRequired("manifest") //nolint:errcheck
 	uploadMultipleCmd.Flags().StringVar(&uploadPath, "upload-path", "", "The directory in which contains files to be uploaded")
@@ -147,84 +130,7 @@ func init() {
 	uploadMultipleCmd.Flags().StringVar(&bucketName, "bucket", "", "The bucket to which files will be uploaded. If not provided, defaults to Gen3's configured DATA_UPLOAD_BUCKET.")
 	uploadMultipleCmd.Flags().BoolVar(&forceMultipart, "force-multipart", false, "Force to use multipart upload when possible (file size >= 5MB)")
 	uploadMultipleCmd.Flags().BoolVar(&includeSubDirName, "include-subdirname", false, "Include subdirectory names in file name")
+	uploadMultipleCmd.Flags().BoolVar(&computeSHA256, "sha256", false, "Also compute the sha256 checksum of each uploaded file, in addition to the md5 checksum")
+	uploadMultipleCmd.Flags().StringVar(&chunkSize, "chunk-size", "", "Size of the parts of multipart uploads, such as \"64MB\". It's kept within 5MB and 5GB, and raised for the files that would have more than 10,000 parts (default 16MB)")
 	RootCmd.AddCommand(uploadMultipleCmd)
 }
-
-func processSingleUploads(gen3Interface Gen3Interface, singleFilePaths []string, bucketName string, includeSubDirName bool, uploadPath string) {
-	for _, filePath := range singleFilePaths {
-		file, err := os.Open(filePath)
-		if err != nil {
-			logs.AddToFailedLog(filePath, filepath.Base(filePath), commonUtils.FileMetadata{}, "", 0, false, true)
-			log.Println("File open error: " + err.Error())
-			continue
-		}
-
-		startSingleFileUpload(gen3Interface, filePath, file, bucketName, includeSubDirName, uploadPath)
-		file.Close()
-	}
-}
-
-func startSingleFileUpload(gen3Interface Gen3Interface, filePath string, file *os.File, bucketName string, includeSubDirName bool, uploadPath string) {
-	fi, err := file.Stat()
-	if err != nil {
-		logs.AddToFailedLog(filePath, filepath.Base(filePath), commonUtils.FileMetadata{}, "", 0, false, true)
-		log.Println("File stat error for file" + fi.Name() + ", file may be missing or unreadable because of permissions.\n")
-		return
-	}
-
-	fileInfo, err := ProcessFilename(uploadPath, filePath, includeSubDirName, false)
-	if err != nil {
-		logs.AddToFailedLog(filePath, filepath.Base(filePath), commonUtils.FileMetadata{}, "", 0, false, true)
-		log.Println("Process filename error for file: " + err.Error())
-		return
-	}
-
-	respURL, guid, err := GeneratePresignedURL(gen3Interface, fileInfo.Filename, fileInfo.FileMetadata, bucketName)
-	if err != nil {
-		logs.AddToFailedLog(fileInfo.FilePath, fileInfo.Filename, fileInfo.FileMetadata, guid, 0, false, true)
-		log.Println(err.Error())
-		return
-	}
-
-	logs.AddToFailedLog(fileInfo.FilePath, fileInfo.Filename, fileInfo.FileMetadata, guid, 0, false, true)
-
-	furObject := commonUtils.FileUploadRequestObject{FilePath: fileInfo.FilePath, Filename: fileInfo.Filename, GUID: guid, PresignedURL: respURL}
-	furObject, err = GenerateUploadRequest(gen3Interface, furObject, file)
-	if err != nil {
-		file.Close()
-		log.Printf("Error occurred during request generation: %s\n", err.Error())
-		return
-	}
-
-	err = uploadFile(furObject, 0)
-	if err != nil {
-		log.Println(err.Error())
-	} else {
-		logs.IncrementScore(0)
-	}
-	file.Close()
-}
-
-func processMultipartUpload(gen3Interface Gen3Interface, multipartFilePaths []string, bucketName string, includeSubDirName bool, uploadPath string) {
-	profileConfig := conf.ParseConfig(profile)
-	if profileConfig.UseShepherd == "true" ||
-		profileConfig.UseShepherd == "" && commonUtils.DefaultUseShepherd == true {
-		log.Fatalf("Error: Shepherd currently does not support multipart uploads. For the moment, please disable Shepherd with\n	$ gen3-client configure --profile=%v --use-shepherd=false\nand try again.\n", profile)
-	}
-	log.Println("Multipart uploading....")
-
-	for _, filePath := range multipartFilePaths {
-		fileInfo, err := ProcessFilename(uploadPath, filePath, includeSubDirName, false)
-		if err != nil {
-			logs.AddToFailedLog(filePath, filepath.Base(filePath), commonUtils.FileMetadata{}, "", 0, false, true)
-			log.Println("Process filename error for file: " + err.Error())
-			continue
-		}
-		err = multipartUpload(gen3Interface, fileInfo, 0, bucketName)
-		if err != nil {
-			log.Println(err.Error())
-		} else {
-			logs.IncrementScore(0)
-		}
-	}
-}
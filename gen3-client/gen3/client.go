@@ -0,0 +1,83 @@
+package gen3
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
+)
+
+// Client uploads, downloads and deletes files in a Gen3 data commons on behalf of a profile.
+// A Client is safe for use by one command at a time, the transmission logs of the logs package are shared by all Clients.
+type Client struct {
+	// Credential is the profile the requests are made for. Its access token is only the one the requests start with:
+	// the tokens refreshed on expiry are kept by the token manager of Gen3Interface, and Credential isn't updated.
+	Credential jwt.Credential
+	// Gen3Interface makes the authorized requests to the Gen3 services
+	Gen3Interface Gen3Interface
+	// ComputeSHA256 also computes the sha256 checksum of each uploaded file, in addition to the md5 checksum
+	ComputeSHA256 bool
+	// Transport makes the requests to the presigned URLs of the object storage, http.DefaultTransport is used if it isn't set
+	Transport http.RoundTripper
+	// RateLimiter caps the throughput of all the uploads and downloads of the client together, there is no limit if it isn't set
+	RateLimiter *RateLimiter
+	// MultipartChunkSize is the size of the parts of multipart uploads, DefaultMultipartChunkSize is used if it's 0.
+	// It's kept within the 5MB to 5GB part sizes of S3, and raised for the files that would have more than 10,000 parts.
+	MultipartChunkSize int64
+	// Concurrency tunes the number of batch uploads, downloads and multipart parts in flight, the number of
+	// parallel transfers asked for is used as is if it isn't set
+	Concurrency *ConcurrencyController
+}
+
+// NewClient returns a Client that makes requests with gen3Interface on behalf of credential
+func NewClient(credential jwt.Credential, gen3Interface Gen3Interface) *Client {
+	return &Client{Credential: credential, Gen3Interface: gen3Interface}
+}
+
+// NewClientFromProfile returns a Client for a profile of the local config file. All of its requests,
+// to the data commons as well as to the object storage, share the transport built from the network settings of the profile.
+func NewClientFromProfile(profile string) (*Client, error) {
+	conf := jwt.Configure{}
+	credential, err := conf.ParseConfig(profile)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := credential.HTTP.Transport()
+	if err != nil {
+		return nil, &jwt.ConfigError{Err: errors.New("Error occurred when setting up the network settings of profile " + profile + ": " + err.Error())}
+	}
+	client := NewClient(credential, NewGen3InterfaceWithTransport(transport))
+	client.Transport = transport
+	return client, nil
+}
+
+// httpClient returns the http client for the requests to the presigned URLs, which are not subject to DefaultTimeout
+// as they may transfer large files
+func (c *Client) httpClient() *http.Client {
+	return &http.Client{Transport: c.Transport}
+}
+
+// uploadHashAlgorithms lists the hash algorithms of the checksums computed for every uploaded file
+func (c *Client) uploadHashAlgorithms() []string {
+	if c.ComputeSHA256 {
+		return []string{"md5", "sha256"}
+	}
+	return []string{"md5"}
+}
+
+// Stat gets the file name, size, URLs and hashes of an object from Shepherd if it's deployed, otherwise from Indexd
+func (c *Client) Stat(ctx context.Context, guid string) (FileRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return FileRecord{}, err
+	}
+	return c.GetFileRecord(ctx, guid)
+}
+
+// Privileges returns the host of the data commons and the resources the profile has access to, along with its permissions on each of them
+func (c *Client) Privileges(ctx context.Context) (string, map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	return c.Gen3Interface.CheckPrivileges(ctx, &c.Credential)
+}
@@ -0,0 +1,246 @@
+package gen3
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// DefaultConcurrencyWindow is how often a ConcurrencyController measures the throughput and adjusts its limit by default
+const DefaultConcurrencyWindow = 2 * time.Second
+
+// concurrencyGain is the throughput increase a higher limit must bring to be kept
+const concurrencyGain = 1.05
+
+// concurrencyHoldWindows is the number of windows the limit is held after a higher limit hasn't paid off, before probing again
+const concurrencyHoldWindows = 5
+
+// throttledRegexp matches the errors of the requests the data commons or the object storage has asked to slow down
+var throttledRegexp = regexp.MustCompile(`status code (for response: )?(429|503)\b`)
+
+// isThrottled tells if err is a 429 Too Many Requests or 503 Slow Down response
+func isThrottled(err error) bool {
+	return err != nil && throttledRegexp.MatchString(err.Error())
+}
+
+// ConcurrencyController limits the number of transfers in flight, and tunes that limit between its bounds from the
+// throughput and the errors measured over each window. The limit doubles while throughput improves, then grows by
+// one at a time, is brought back when a higher limit doesn't pay off, drops by one when more than 10% of the
+// requests fail, and is halved as soon as a request is throttled with a 429 or 503.
+type ConcurrencyController struct {
+	// Window is how often the throughput is measured and the limit adjusted, DefaultConcurrencyWindow is used if it's 0
+	Window time.Duration
+
+	min int
+	max int
+
+	lock          sync.Mutex
+	changed       chan struct{}
+	limit         int
+	previousLimit int
+	inFlight      int
+	slowStart     bool
+	hold          int
+
+	windowStart     time.Time
+	windowBytes     int64
+	windowRequests  int
+	windowErrors    int
+	windowThrottled int
+	maxInFlight     int
+	lastThroughput  float64
+}
+
+// NewConcurrencyController returns a ConcurrencyController that keeps between min and max transfers in flight,
+// starting from min
+func NewConcurrencyController(min int, max int) *ConcurrencyController {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	return &ConcurrencyController{min: min, max: max, limit: min, previousLimit: min, slowStart: true, changed: make(chan struct{})}
+}
+
+// Max returns the upper bound of the limit
+func (cc *ConcurrencyController) Max() int {
+	return cc.max
+}
+
+// Limit returns the current number of transfers allowed in flight
+func (cc *ConcurrencyController) Limit() int {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+	return cc.limit
+}
+
+// Acquire waits until a transfer can start within the limit. Every successful Acquire must be followed by a Release.
+func (cc *ConcurrencyController) Acquire(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		cc.lock.Lock()
+		if cc.inFlight < cc.limit {
+			cc.inFlight++
+			if cc.inFlight > cc.maxInFlight {
+				cc.maxInFlight = cc.inFlight
+			}
+			cc.lock.Unlock()
+			return nil
+		}
+		changed := cc.changed
+		cc.lock.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Release ends a transfer started by Acquire
+func (cc *ConcurrencyController) Release() {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+	cc.inFlight--
+	cc.notify()
+}
+
+// Report records the outcome of a request, err being nil if it has succeeded
+func (cc *ConcurrencyController) Report(err error) {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+	cc.windowRequests++
+	if isThrottled(err) {
+		cc.windowThrottled++
+	} else if err != nil {
+		cc.windowErrors++
+	}
+	cc.adjust(time.Now())
+}
+
+// Add records n bytes transferred
+func (cc *ConcurrencyController) Add(n int) {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+	cc.windowBytes += int64(n)
+	cc.adjust(time.Now())
+}
+
+// notify wakes up the transfers waiting in Acquire, it must be called with the lock held
+func (cc *ConcurrencyController) notify() {
+	close(cc.changed)
+	cc.changed = make(chan struct{})
+}
+
+// adjust sets the limit for the next window once the current one is over, it must be called with the lock held
+func (cc *ConcurrencyController) adjust(now time.Time) {
+	if cc.windowStart.IsZero() {
+		cc.windowStart = now
+		return
+	}
+	window := cc.Window
+	if window <= 0 {
+		window = DefaultConcurrencyWindow
+	}
+	elapsed := now.Sub(cc.windowStart)
+	if elapsed < window {
+		return
+	}
+	throughput := float64(cc.windowBytes) / elapsed.Seconds()
+
+	limit := cc.limit
+	raised := cc.limit > cc.previousLimit
+	switch {
+	case cc.windowThrottled > 0:
+		limit = cc.limit / 2
+		cc.slowStart = false
+	case cc.windowErrors*10 > cc.windowRequests:
+		limit = cc.limit - 1
+		cc.slowStart = false
+	case raised && throughput < cc.lastThroughput*concurrencyGain:
+		// the higher limit hasn't paid off, go back and stay there for a while
+		limit = cc.previousLimit
+		cc.slowStart = false
+		cc.hold = concurrencyHoldWindows
+	case cc.hold > 0:
+		cc.hold--
+	case cc.maxInFlight >= cc.limit:
+		// only raise the limit if it's what held the transfers back
+		if cc.slowStart {
+			limit = cc.limit * 2
+		} else {
+			limit = cc.limit + 1
+		}
+	}
+	if limit < cc.min {
+		limit = cc.min
+	}
+	if limit > cc.max {
+		limit = cc.max
+	}
+
+	cc.previousLimit = cc.limit
+	cc.limit = limit
+	cc.lastThroughput = throughput
+	cc.windowStart = now
+	cc.windowBytes = 0
+	cc.windowRequests = 0
+	cc.windowErrors = 0
+	cc.windowThrottled = 0
+	cc.maxInFlight = cc.inFlight
+	if cc.limit > cc.previousLimit {
+		cc.notify()
+	}
+}
+
+// countingReader reports the bytes read from reader to a ConcurrencyController
+type countingReader struct {
+	reader     io.Reader
+	controller *ConcurrencyController
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.reader.Read(p)
+	if n > 0 {
+		cr.controller.Add(n)
+	}
+	return n, err
+}
+
+// parallelism returns the number of transfers to prepare at once, which is numParallel unless the client tunes its
+// concurrency, in which case it's the upper bound of the controller
+func (c *Client) parallelism(numParallel int) int {
+	if c.Concurrency == nil {
+		return numParallel
+	}
+	return c.Concurrency.Max()
+}
+
+// acquireTransfer waits until the concurrency controller of the client lets another transfer start, if it has one
+func (c *Client) acquireTransfer(ctx context.Context) error {
+	if c.Concurrency == nil {
+		return nil
+	}
+	return c.Concurrency.Acquire(ctx)
+}
+
+// releaseTransfer ends a transfer started by acquireTransfer
+func (c *Client) releaseTransfer() {
+	if c.Concurrency != nil {
+		c.Concurrency.Release()
+	}
+}
+
+// reportTransfer records the outcome of a transfer request in the concurrency controller of the client, if it has one.
+// Requests that have failed because of a cancellation aren't counted.
+func (c *Client) reportTransfer(ctx context.Context, err error) {
+	if c.Concurrency != nil && ctx.Err() == nil {
+		c.Concurrency.Report(err)
+	}
+}
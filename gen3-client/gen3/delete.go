@@ -0,0 +1,62 @@
+package gen3
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+)
+
+// Delete deletes the records of objects along with their files in object storage, running up to numParallel deletions in parallel.
+// If ctx is cancelled, the objects that haven't been deleted yet are left out of the results and ctx.Err() is returned.
+func (c *Client) Delete(ctx context.Context, objects []ManifestObject, numParallel int) ([]commonUtils.DeleteResultObject, error) {
+	results := make([]commonUtils.DeleteResultObject, len(objects))
+	done := make([]bool, len(objects))
+	workers := getNumberOfWorkers(numParallel, len(objects))
+	indexCh := make(chan int, len(objects))
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexCh {
+				if ctx.Err() != nil {
+					continue
+				}
+				obj := objects[index]
+				result := commonUtils.DeleteResultObject{GUID: obj.ObjectID, Filename: obj.Filename}
+				logs.StartFileResult(obj.ObjectID)
+				msg, err := c.DeleteRecord(ctx, obj.ObjectID)
+				if err != nil {
+					result.Message = err.Error()
+					log.Println(err.Error())
+					logs.RecordFileResult(obj.ObjectID, logs.FileResult{GUID: obj.ObjectID, Filename: obj.Filename, Status: logs.StatusFailed, Error: err.Error()})
+				} else {
+					result.Deleted = true
+					result.Message = msg
+					log.Println(msg)
+					logs.RecordFileResult(obj.ObjectID, logs.FileResult{GUID: obj.ObjectID, Filename: obj.Filename, Status: logs.StatusSucceeded})
+				}
+				results[index] = result
+				done[index] = true
+			}
+		}()
+	}
+
+	for i := range objects {
+		indexCh <- i
+	}
+	close(indexCh)
+	wg.Wait()
+
+	processed := make([]commonUtils.DeleteResultObject, 0, len(results))
+	for i, result := range results {
+		if done[i] {
+			processed = append(processed, result)
+		}
+	}
+	return processed, ctx.Err()
+}
@@ -0,0 +1,282 @@
+package gen3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+)
+
+// minSegmentSize is the smallest byte range a segmented download will fetch on its own connection
+const minSegmentSize int64 = 16 * 1024 * 1024
+
+// DefaultSegments is the number of byte ranges a large file is downloaded in by default
+const DefaultSegments = 4
+
+// partialSuffix is appended to the name of a file while its segments are being downloaded. The preallocated file
+// only gets its final name once all segments have completed, so that an interrupted download is never taken as a
+// completed one. The progress of each segment is recorded in the partial download state, so that an interrupted
+// or failed download is resumed from the bytes already in the partial file.
+const partialSuffix = ".partial"
+
+// segmentWriter writes the bytes of a segment sequentially into the partial file, from the first byte of the segment
+// that hasn't been written yet, and records the progress of the segment in the partial download state
+type segmentWriter struct {
+	file     *os.File
+	filePath string
+	index    int
+	start    int64
+	offset   int64
+}
+
+func (sw *segmentWriter) Write(p []byte) (int, error) {
+	n, err := sw.file.WriteAt(p, sw.offset)
+	sw.offset += int64(n)
+	logs.UpdatePartialDownloadSegment(sw.filePath, sw.index, sw.offset-sw.start)
+	return n, err
+}
+
+// probeRangeSupport sends a single byte ranged GET to the presigned URL of fdrObject, and returns the
+// total size of the object if the storage server honours byte ranges, or 0 if it doesn't
+func (c *Client) probeRangeSupport(ctx context.Context, fdrObject *commonUtils.FileDownloadResponseObject) (int64, error) {
+	headers := map[string]string{"Range": "bytes=0-0"}
+	resp, err := c.Gen3Interface.MakeARequest(ctx, http.MethodGet, fdrObject.URL, "", "", headers, nil, false)
+	if err != nil {
+		errorMsg := "Error occurred when sending ranged request to URL associated with GUID " + fdrObject.GUID
+		errorMsg += "\n Details of error: " + sanitizeErrorMsg(err.Error(), fdrObject.URL)
+		return 0, errors.New(errorMsg)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, nil
+	}
+	// Content-Range looks like "bytes 0-0/12345", the total size is unknown if it ends with "*"
+	contentRange := resp.Header.Get("Content-Range")
+	slashIndex := strings.LastIndex(contentRange, "/")
+	if slashIndex == -1 {
+		return 0, nil
+	}
+	totalSize, err := strconv.ParseInt(contentRange[slashIndex+1:], 10, 64)
+	if err != nil {
+		return 0, nil
+	}
+	return totalSize, nil
+}
+
+// prepareSegmentedDownload decides whether the object of fdrObject should be downloaded in segments,
+// and sets fdrObject.Segments and fdrObject.TotalSize accordingly
+func (c *Client) prepareSegmentedDownload(ctx context.Context, fdrObject *commonUtils.FileDownloadResponseObject, segments int) error {
+	fdrObject.Segments = 0
+	totalSize, err := c.probeRangeSupport(ctx, fdrObject)
+	if err != nil {
+		return err
+	}
+	if totalSize < 2*minSegmentSize {
+		return nil
+	}
+	if maxSegments := int(totalSize / minSegmentSize); segments > maxSegments {
+		segments = maxSegments
+	}
+	fdrObject.Segments = segments
+	fdrObject.TotalSize = totalSize
+	return nil
+}
+
+// prepareResumedSegmentedDownload checks that the unfinished segmented download of fdrObject can be resumed, that is
+// the storage server still honours byte ranges and the object still has the size of the partial file, and sets
+// fdrObject.Segments accordingly. Otherwise the partial file is dropped, and false is returned so that the object is
+// downloaded from the start.
+func (c *Client) prepareResumedSegmentedDownload(ctx context.Context, fdrObject *commonUtils.FileDownloadResponseObject) (bool, error) {
+	totalSize, err := c.probeRangeSupport(ctx, fdrObject)
+	if err != nil {
+		return false, err
+	}
+	if totalSize != fdrObject.TotalSize {
+		log.Printf("The partial download of file \"%s\" (GUID: %s) cannot be resumed, it will be downloaded from the start\n", fdrObject.Filename, fdrObject.GUID)
+		discardPartialDownload(fdrObject)
+		fdrObject.SegmentStates = nil
+		fdrObject.TotalSize = 0
+		return false, nil
+	}
+	fdrObject.Segments = len(fdrObject.SegmentStates)
+	return true, nil
+}
+
+// partialDownloadState returns the progress of the unfinished segmented download of a file if it can be resumed, that is
+// it's been recorded for the same GUID and its partial file is still there with the size of the object
+func partialDownloadState(downloadPath string, filename string, guid string) (commonUtils.PartialDownloadStateObject, bool) {
+	filePath := downloadPath + filename
+	state, present := logs.GetPartialDownloadState(filePath)
+	if !present || state.GUID != guid || len(state.Segments) == 0 {
+		return state, false
+	}
+	fi, err := os.Stat(filePath + partialSuffix)
+	if err != nil || fi.Size() != state.TotalSize {
+		logs.DeleteFromPartialDownloadState(filePath)
+		return state, false
+	}
+	return state, true
+}
+
+// partialFilePath returns the path of the file the segments of fdrObject are written into until they have all completed
+func partialFilePath(fdrObject *commonUtils.FileDownloadResponseObject) string {
+	return fdrObject.DownloadPath + fdrObject.Filename + partialSuffix
+}
+
+// splitSegments divides the object of fdrObject into fdrObject.Segments byte ranges of about the same size
+func splitSegments(fdrObject *commonUtils.FileDownloadResponseObject) []commonUtils.SegmentStateObject {
+	segmentSize := fdrObject.TotalSize / int64(fdrObject.Segments)
+	segmentStates := make([]commonUtils.SegmentStateObject, fdrObject.Segments)
+	for i := range segmentStates {
+		segmentStates[i].Start = int64(i) * segmentSize
+		segmentStates[i].End = segmentStates[i].Start + segmentSize - 1
+		if i == fdrObject.Segments-1 {
+			segmentStates[i].End = fdrObject.TotalSize - 1
+		}
+	}
+	return segmentStates
+}
+
+// writtenBytes returns the number of bytes of the segments already in the partial file
+func writtenBytes(segmentStates []commonUtils.SegmentStateObject) int64 {
+	var written int64
+	for _, segmentState := range segmentStates {
+		written += segmentState.Written
+	}
+	return written
+}
+
+// downloadSegments fetches the byte ranges of the object of fdrObject that haven't been downloaded yet concurrently and
+// writes them into file, the partial file of fdrObject, which is preallocated to the total size of the object. Every
+// byte written is also written to progress. Once all segments have completed the partial file is closed and renamed to
+// the file name of fdrObject. On errors it's closed and kept, along with the progress of its segments, for a retry.
+func (c *Client) downloadSegments(ctx context.Context, fdrObject *commonUtils.FileDownloadResponseObject, protocolText string, file *os.File, progress io.Writer) error {
+	err := file.Truncate(fdrObject.TotalSize)
+	if err != nil {
+		file.Close()
+		discardPartialDownload(fdrObject)
+		return errors.New("Error occurred during preallocating local file: " + err.Error())
+	}
+
+	filePath := fdrObject.DownloadPath + fdrObject.Filename
+	segmentStates := fdrObject.SegmentStates
+	if len(segmentStates) == 0 {
+		segmentStates = splitSegments(fdrObject)
+	}
+	logs.SavePartialDownloadState(commonUtils.PartialDownloadStateObject{FilePath: filePath, GUID: fdrObject.GUID, TotalSize: fdrObject.TotalSize, Segments: segmentStates})
+
+	errs := make([]error, len(segmentStates))
+	progress = &lockedWriter{writer: progress, lock: &sync.Mutex{}}
+	wg := sync.WaitGroup{}
+	for i, segmentState := range segmentStates {
+		if segmentState.Start+segmentState.Written > segmentState.End {
+			continue
+		}
+		writer := &segmentWriter{file: file, filePath: filePath, index: i, start: segmentState.Start, offset: segmentState.Start + segmentState.Written}
+		wg.Add(1)
+		go func(index int, writer *segmentWriter, end int64) {
+			defer wg.Done()
+			errs[index] = c.downloadSegment(ctx, fdrObject, protocolText, writer, end, progress)
+		}(i, writer, segmentState.End)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			file.Close()
+			logs.FlushPartialDownloadState()
+			if ctx.Err() != nil {
+				return errors.New("Download of file \"" + filePath + "\" (GUID: " + fdrObject.GUID + ") has been interrupted, it will be resumed by a retry: " + err.Error())
+			}
+			return err
+		}
+	}
+	if err = file.Close(); err != nil {
+		return errors.New("Error occurred during closing local file: " + err.Error())
+	}
+	if err = os.Rename(file.Name(), filePath); err != nil {
+		return errors.New("Error occurred during renaming local file: " + err.Error())
+	}
+	logs.DeleteFromPartialDownloadState(filePath)
+	return nil
+}
+
+// discardPartialDownload removes the partial file of a segmented download along with its recorded progress
+func discardPartialDownload(fdrObject *commonUtils.FileDownloadResponseObject) {
+	filePath := partialFilePath(fdrObject)
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		log.Printf("Error occurred when removing partial file \"%s\": %s\n", filePath, err.Error())
+	}
+	logs.DeleteFromPartialDownloadState(fdrObject.DownloadPath + fdrObject.Filename)
+}
+
+// downloadSegment fetches the bytes of the object of fdrObject from the offset of writer to end (inclusive) into writer.
+// On errors it retries with exponential backoff from the first byte it hasn't written yet, with a fresh presigned URL,
+// until ctx is done.
+func (c *Client) downloadSegment(ctx context.Context, fdrObject *commonUtils.FileDownloadResponseObject, protocolText string, writer *segmentWriter, end int64, progress io.Writer) error {
+	url := fdrObject.URL
+	for retryCount := 0; ; retryCount++ {
+		err := c.fetchRange(ctx, fdrObject.GUID, url, writer, end, progress)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		if retryCount >= MaxRetryCount {
+			return errors.New("Error occurred when downloading bytes " + strconv.FormatInt(writer.offset, 10) + "-" + strconv.FormatInt(end, 10) + " of GUID " + fdrObject.GUID + " after " + strconv.Itoa(MaxRetryCount) + " retries: " + err.Error())
+		}
+		if waitForRetry(ctx, retryCount) != nil {
+			return err
+		}
+		log.Printf("Retrying download of bytes %d-%d of GUID %s after error: %s\n", writer.offset, end, fdrObject.GUID, err.Error())
+
+		// the presigned URL may have expired, request a fresh one for the retry
+		freshFDRObject := *fdrObject
+		if err := c.GetDownloadURL(ctx, &freshFDRObject, protocolText); err != nil {
+			log.Println(err.Error())
+		} else {
+			url = freshFDRObject.URL
+		}
+	}
+}
+
+// fetchRange writes the bytes of url from the offset of writer to end (inclusive) into writer
+func (c *Client) fetchRange(ctx context.Context, guid string, url string, writer *segmentWriter, end int64, progress io.Writer) error {
+	headers := map[string]string{"Range": "bytes=" + strconv.FormatInt(writer.offset, 10) + "-" + strconv.FormatInt(end, 10)}
+	resp, err := c.Gen3Interface.MakeARequest(ctx, http.MethodGet, url, "", "", headers, nil, true)
+	if err != nil {
+		errorMsg := "Error occurred when making ranged request to URL associated with GUID " + guid
+		errorMsg += "\n Details of error: " + sanitizeErrorMsg(err.Error(), url)
+		return errors.New(errorMsg)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		errorMsg := "Got a non-206 response when making ranged request to URL associated with GUID " + guid
+		errorMsg += "\n HTTP status code for response: " + strconv.Itoa(resp.StatusCode)
+		return errors.New(errorMsg)
+	}
+
+	_, err = io.CopyN(io.MultiWriter(writer, progress), c.limitReader(ctx, resp.Body), end-writer.offset+1)
+	return err
+}
+
+// lockedWriter serializes the writes of concurrent segments into a shared writer such as a progress bar
+type lockedWriter struct {
+	writer io.Writer
+	lock   *sync.Mutex
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	return lw.writer.Write(p)
+}
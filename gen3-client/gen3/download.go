@@ -0,0 +1,919 @@
+package gen3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+	pb "gopkg.in/cheggaaa/pb.v1"
+)
+
+// pipelineBufferSize bounds the number of objects queued between the stages of a download
+const pipelineBufferSize = 100
+
+// fileInfoWorkers is the number of file info lookups that run concurrently while files are being downloaded
+const fileInfoWorkers = 4
+
+// fileInfoBatchSize is the maximum number of GUIDs whose file records are retrieved from Indexd in one bulk request
+const fileInfoBatchSize = 200
+
+// mockgen -destination=../mocks/mock_gen3interface.go -package=mocks . Gen3Interface
+
+// FileRecord represents the information of an object file as recorded in Shepherd or Indexd
+type FileRecord struct {
+	FileName string
+	Size     int64
+	URLs     []string
+	Hashes   map[string]string
+}
+
+// GetFileRecord gets the file name, size, URLs and hashes of an object from Shepherd if it's deployed, otherwise from Indexd
+func (c *Client) GetFileRecord(ctx context.Context, guid string) (FileRecord, error) {
+	// If the commons has the newer Shepherd API deployed, get the file record from the Shepherd API.
+	// Otherwise, fall back on Indexd.
+	hasShepherd, err := c.Gen3Interface.CheckForShepherdAPI(ctx, &c.Credential)
+	if err != nil {
+		log.Println("Error occurred when checking for Shepherd API: " + err.Error())
+		log.Println("Falling back to Indexd...")
+	}
+	if hasShepherd {
+		endPointPostfix := commonUtils.ShepherdEndpoint + "/objects/" + guid
+		_, res, err := c.Gen3Interface.GetResponse(ctx, &c.Credential, endPointPostfix, "GET", "", nil)
+		if err != nil {
+			return FileRecord{}, errors.New("Error occurred when querying file record from Shepherd: " + err.Error())
+		}
+		defer res.Body.Close()
+
+		decoded := struct {
+			Record struct {
+				FileName string            `json:"file_name"`
+				Size     int64             `json:"size"`
+				URLs     []string          `json:"urls"`
+				Hashes   map[string]string `json:"hashes"`
+			}
+		}{}
+		err = json.NewDecoder(res.Body).Decode(&decoded)
+		if err != nil {
+			return FileRecord{}, errors.New("Error occurred when reading response from Shepherd: " + err.Error())
+		}
+		return FileRecord{FileName: decoded.Record.FileName, Size: decoded.Record.Size, URLs: decoded.Record.URLs, Hashes: decoded.Record.Hashes}, nil
+	}
+
+	endPointPostfix := commonUtils.IndexdIndexEndpoint + "/" + guid
+	indexdMsg, err := c.Gen3Interface.DoRequestWithSignedHeader(ctx, &c.Credential, endPointPostfix, "", nil)
+	if err != nil {
+		return FileRecord{}, errors.New("Error occurred when querying file record from IndexD: " + err.Error())
+	}
+	return FileRecord{FileName: indexdMsg.FileName, Size: indexdMsg.Size, URLs: indexdMsg.URLs, Hashes: indexdMsg.Hashes}, nil
+}
+
+// GetFileRecords gets the file records of multiple objects from Indexd in a single bulk request, keyed by GUID.
+// GUIDs that are not found in Indexd are left out of the result.
+func (c *Client) GetFileRecords(ctx context.Context, guids []string) (map[string]FileRecord, error) {
+	bodyBytes, err := json.Marshal(guids)
+	if err != nil {
+		return nil, errors.New("Error occurred when marshaling GUIDs: " + err.Error())
+	}
+	_, res, err := c.Gen3Interface.GetResponse(ctx, &c.Credential, commonUtils.IndexdBulkDocumentsEndpoint, "POST", "application/json", bodyBytes)
+	if err != nil {
+		return nil, errors.New("Error occurred when querying file records from IndexD: " + err.Error())
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, errors.New("Error occurred when querying file records from IndexD: got a non-200 response with HTTP status code " + strconv.Itoa(res.StatusCode))
+	}
+
+	decoded := make([]struct {
+		DID      string            `json:"did"`
+		FileName string            `json:"file_name"`
+		Size     int64             `json:"size"`
+		URLs     []string          `json:"urls"`
+		Hashes   map[string]string `json:"hashes"`
+	}, 0)
+	err = json.NewDecoder(res.Body).Decode(&decoded)
+	if err != nil {
+		return nil, errors.New("Error occurred when reading response from IndexD: " + err.Error())
+	}
+	records := make(map[string]FileRecord)
+	for _, record := range decoded {
+		records[record.DID] = FileRecord{FileName: record.FileName, Size: record.Size, URLs: record.URLs, Hashes: record.Hashes}
+	}
+	return records, nil
+}
+
+// AskGen3ForFileInfo returns the local filename, the file size and the hashes to be used for downloading an object
+func (c *Client) AskGen3ForFileInfo(ctx context.Context, guid string, protocol string, downloadPath string, filenameFormat string, rename bool, renamedFiles *[]RenamedOrSkippedFileInfo) (string, int64, map[string]string) {
+	record, err := c.GetFileRecord(ctx, guid)
+	return fileInfoFromRecord(record, err, guid, protocol, downloadPath, filenameFormat, rename, renamedFiles)
+}
+
+func fileInfoFromRecord(record FileRecord, err error, guid string, protocol string, downloadPath string, filenameFormat string, rename bool, renamedFiles *[]RenamedOrSkippedFileInfo) (string, int64, map[string]string) {
+	if err != nil {
+		log.Println(err.Error())
+		log.Println("Using GUID for filename instead.")
+		if filenameFormat != "guid" {
+			*renamedFiles = append(*renamedFiles, RenamedOrSkippedFileInfo{GUID: guid, OldFilename: "N/A", NewFilename: guid})
+		}
+		return guid, 0, nil
+	}
+	return resolveFilename(record, guid, protocol, downloadPath, filenameFormat, rename, renamedFiles), record.Size, record.Hashes
+}
+
+func resolveFilename(record FileRecord, guid string, protocol string, downloadPath string, filenameFormat string, rename bool, renamedFiles *[]RenamedOrSkippedFileInfo) string {
+	if filenameFormat == "guid" {
+		return guid
+	}
+
+	fileName := record.FileName
+	if fileName == "" {
+		if len(record.URLs) == 0 {
+			// Neither file name nor URLs exist in the record
+			// The record is busted for that file, just return as we are renaming the file for now
+			// The download logic will handle the errors
+			log.Println("Neither file name nor URLs exist in the Indexd record of " + guid)
+			log.Println("The attempt of downloading file is likely to fail! Check Indexd record!")
+			log.Println("Using GUID for filename instead.")
+			*renamedFiles = append(*renamedFiles, RenamedOrSkippedFileInfo{GUID: guid, OldFilename: "N/A", NewFilename: guid})
+			return guid
+		}
+		// Record has no file name but does have URLs, try to guess file name from URL
+		var recordURL = record.URLs[0]
+		if protocol != "" {
+			for _, url := range record.URLs {
+				if strings.HasPrefix(url, protocol) {
+					recordURL = url
+				}
+			}
+		}
+
+		fileName = guessFilenameFromURL(recordURL)
+		if fileName == "" {
+			log.Println("Error occurred when guessing filename for object " + guid)
+			log.Println("Using GUID for filename instead.")
+			*renamedFiles = append(*renamedFiles, RenamedOrSkippedFileInfo{GUID: guid, OldFilename: "N/A", NewFilename: guid})
+			return guid
+		}
+	}
+
+	if filenameFormat == "original" {
+		if !rename { // no renaming in original mode
+			return fileName
+		}
+		newFilename := processOriginalFilename(downloadPath, fileName)
+		if fileName != newFilename {
+			*renamedFiles = append(*renamedFiles, RenamedOrSkippedFileInfo{GUID: guid, OldFilename: fileName, NewFilename: newFilename})
+		}
+		return newFilename
+	}
+	// filenameFormat == "combined"
+	return guid + "_" + fileName
+}
+
+func guessFilenameFromURL(URL string) string {
+	splittedURLWithFilename := strings.Split(URL, "/")
+	actualFilename := splittedURLWithFilename[len(splittedURLWithFilename)-1]
+	return actualFilename
+}
+
+func processOriginalFilename(downloadPath string, actualFilename string) string {
+	_, err := os.Stat(downloadPath + actualFilename)
+	if os.IsNotExist(err) {
+		return actualFilename
+	}
+	extension := filepath.Ext(actualFilename)
+	filename := strings.TrimSuffix(actualFilename, extension)
+	counter := 2
+	for {
+		newFilename := filename + "_" + strconv.Itoa(counter) + extension
+		_, err := os.Stat(downloadPath + newFilename)
+		if os.IsNotExist(err) {
+			return newFilename
+		}
+		counter++
+	}
+}
+
+// validateLocalFileStat checks for an existing local copy of an object, to tell whether it has to be downloaded, skipped,
+// resumed or overwritten. An unfinished segmented download of the same object is resumed from its partial file.
+func validateLocalFileStat(downloadPath string, filename string, guid string, filesize int64, skipCompleted bool) commonUtils.FileDownloadResponseObject {
+	fdrObject := checkLocalFile(downloadPath, filename, filesize, skipCompleted)
+	if fdrObject.Skip {
+		return fdrObject
+	}
+	if state, resumable := partialDownloadState(downloadPath, filename, guid); resumable {
+		fdrObject.TotalSize = state.TotalSize
+		fdrObject.SegmentStates = state.Segments
+	}
+	return fdrObject
+}
+
+// checkLocalFile compares an existing local file with the size of its object
+func checkLocalFile(downloadPath string, filename string, filesize int64, skipCompleted bool) commonUtils.FileDownloadResponseObject {
+	fi, err := os.Stat(downloadPath + filename) // check filename for local existence
+	if err != nil {
+		if os.IsNotExist(err) {
+			return commonUtils.FileDownloadResponseObject{DownloadPath: downloadPath, Filename: filename} // no local file, normal full length download
+		}
+		log.Printf("Error occurred when getting information for file \"%s\": %s\n", downloadPath+filename, err.Error())
+		log.Println("Will try to download the whole file")
+		return commonUtils.FileDownloadResponseObject{DownloadPath: downloadPath, Filename: filename} // errorred when trying to get local FI, normal full length download
+	}
+
+	// have existing local file and may want to skip, check more conditions
+	if !skipCompleted {
+		return commonUtils.FileDownloadResponseObject{DownloadPath: downloadPath, Filename: filename, Overwrite: true} // not skipping any local files, normal full length download
+	}
+
+	localFilesize := fi.Size()
+	if localFilesize == filesize {
+		return commonUtils.FileDownloadResponseObject{DownloadPath: downloadPath, Filename: filename, Skip: true} // both filename and filesize matches, consider as completed
+	}
+	if localFilesize > filesize {
+		return commonUtils.FileDownloadResponseObject{DownloadPath: downloadPath, Filename: filename, Overwrite: true} // local filesize is greater than INDEXD record, overwrite local existing
+	}
+	// local filesize is less than INDEXD record, try ranged download
+	return commonUtils.FileDownloadResponseObject{DownloadPath: downloadPath, Filename: filename, Range: localFilesize}
+}
+
+// downloadFile downloads the object of fdrObject into its local file with a progress bar in pool, and verifies its checksum
+// if it has hashes. The outcome is recorded in the failed download log and in the file results, and the error of a failed
+// download is returned.
+func (c *Client) downloadFile(ctx context.Context, fdrObject commonUtils.FileDownloadResponseObject, protocolText string, segments int, pool *progressPool, deleteCorrupted bool) error {
+	filePath := fdrObject.DownloadPath + fdrObject.Filename
+	logs.StartFileResult(filePath)
+	presigned := fdrObject.URL != ""
+	err := c.openDownload(ctx, &fdrObject, protocolText, segments)
+	if err != nil && presigned {
+		// the presigned URL has been requested ahead of time and may have expired while waiting, try once more with a fresh one
+		fdrObject.URL = ""
+		err = c.openDownload(ctx, &fdrObject, protocolText, segments)
+	}
+	c.reportTransfer(ctx, err)
+	if err != nil {
+		return failDownload(fdrObject, err, 0)
+	}
+	file, err := openLocalFile(&fdrObject)
+	if err != nil {
+		closeDownloadResponse(fdrObject)
+		return failDownload(fdrObject, err, 0)
+	}
+
+	var bar *pb.ProgressBar
+	if fdrObject.Segments > 0 {
+		// segments are written straight into the file, so the writer only tracks progress
+		// and the checksum is computed from the file once all segments have completed
+		bar = newProgressBar(fdrObject.TotalSize, fdrObject.Filename)
+		bar.Set64(writtenBytes(fdrObject.SegmentStates))
+		if algorithms := commonUtils.VerifiableHashAlgorithms(fdrObject.Hashes); len(algorithms) > 0 {
+			fdrObject.HashWriter = commonUtils.NewHashWriter(algorithms...)
+		}
+		fdrObject.Writer = bar
+		pool.Add(bar)
+		err = c.downloadSegments(ctx, &fdrObject, protocolText, file, bar)
+	} else {
+		bar = newProgressBar(fdrObject.Response.ContentLength+fdrObject.Range, fdrObject.Filename)
+		bar.Set64(fdrObject.Range)
+		writers := []io.Writer{file, bar}
+		if algorithms := commonUtils.VerifiableHashAlgorithms(fdrObject.Hashes); len(algorithms) > 0 {
+			fdrObject.HashWriter = commonUtils.NewHashWriter(algorithms...)
+			if err := hashExistingContent(fdrObject.HashWriter, filePath, fdrObject.Range); err != nil {
+				log.Printf("Unable to verify checksum of file \"%s\" (GUID: %s): %s\n", fdrObject.Filename, fdrObject.GUID, err.Error())
+				fdrObject.HashWriter = nil
+			} else {
+				writers = append(writers, fdrObject.HashWriter)
+			}
+		}
+		fdrObject.Writer = io.MultiWriter(writers...)
+		pool.Add(bar)
+		err = c.downloadStreamWithRetry(ctx, &fdrObject, file, bar, protocolText)
+		file.Close()
+	}
+	bar.Finish()
+	if err != nil {
+		return failDownload(fdrObject, err, MaxRetryCount)
+	}
+
+	if fdrObject.Segments > 0 && fdrObject.HashWriter != nil {
+		if err := hashExistingContent(fdrObject.HashWriter, filePath, fdrObject.TotalSize); err != nil {
+			return failDownload(fdrObject, errors.New("Error occurred when computing checksum of file \""+filePath+"\" (GUID: "+fdrObject.GUID+"): "+err.Error()), 0)
+		}
+	}
+	if fdrObject.HashWriter != nil {
+		if err := commonUtils.VerifyHashes(fdrObject.Hashes, fdrObject.HashWriter.Sums()); err != nil {
+			if deleteCorrupted {
+				if err := os.Remove(filePath); err != nil {
+					log.Printf("Error occurred when deleting corrupted file \"%s\": %s\n", filePath, err.Error())
+				} else {
+					log.Printf("Corrupted file \"%s\" has been deleted\n", filePath)
+				}
+			}
+			return failDownload(fdrObject, errors.New("Checksum verification failed for file \""+filePath+"\" (GUID: "+fdrObject.GUID+"): "+err.Error()), 0)
+		}
+	}
+	logs.DeleteFromFailedDownloadLog(fdrObject.DownloadPath, fdrObject.Filename, true)
+	logs.RecordFileResult(filePath, logs.FileResult{GUID: fdrObject.GUID, Filename: fdrObject.Filename, FilePath: filePath, Status: logs.StatusSucceeded, Bytes: bar.Get()})
+	return nil
+}
+
+// openLocalFile creates the folders of the local file of fdrObject and opens it for the download: for appending if the
+// download is resumed, truncated if the file is overwritten, or its partial file if it's downloaded in segments
+func openLocalFile(fdrObject *commonUtils.FileDownloadResponseObject) (*os.File, error) {
+	filePath := fdrObject.DownloadPath + fdrObject.Filename
+	if err := os.MkdirAll(filepath.Dir(filePath), 0766); err != nil {
+		return nil, errors.New("Cannot create folder \"" + filepath.Dir(filePath) + "\": " + err.Error())
+	}
+	fileFlag := os.O_CREATE | os.O_RDWR
+	if fdrObject.Range != 0 {
+		fileFlag = os.O_APPEND | os.O_RDWR
+	} else if fdrObject.Overwrite {
+		fileFlag = os.O_TRUNC | os.O_RDWR
+	}
+	if fdrObject.Segments > 0 {
+		// the partial file of an unfinished segmented download already has the bytes of the segments that are resumed
+		filePath = partialFilePath(fdrObject)
+		fileFlag = os.O_CREATE | os.O_RDWR
+		if len(fdrObject.SegmentStates) == 0 {
+			fileFlag |= os.O_TRUNC
+		}
+	}
+	file, err := os.OpenFile(filePath, fileFlag, 0666)
+	if err != nil {
+		return nil, errors.New("Error occurred during opening local file: " + err.Error())
+	}
+	return file, nil
+}
+
+// failDownload records a download that has failed in the failed download log, and returns its error
+func failDownload(fdrObject commonUtils.FileDownloadResponseObject, err error, retryCount int) error {
+	logs.AddToFailedDownloadLog(fdrObject.GUID, fdrObject.DownloadPath, fdrObject.Filename, fdrObject.FileSize, err.Error(), retryCount, true)
+	return err
+}
+
+// openDownload requests the presigned URL of fdrObject if it doesn't have one yet, and then either prepares a
+// segmented download of it or opens its response body
+func (c *Client) openDownload(ctx context.Context, fdrObject *commonUtils.FileDownloadResponseObject, protocolText string, segments int) error {
+	if fdrObject.URL == "" {
+		err := c.GetDownloadURL(ctx, fdrObject, protocolText)
+		if err != nil {
+			return err
+		}
+	}
+	if len(fdrObject.SegmentStates) > 0 {
+		resumed, err := c.prepareResumedSegmentedDownload(ctx, fdrObject)
+		if err != nil || resumed {
+			return err
+		}
+	}
+	// Only fresh downloads are split into segments, resumed downloads keep using a single stream
+	if segments > 1 && fdrObject.Range == 0 {
+		err := c.prepareSegmentedDownload(ctx, fdrObject, segments)
+		if err != nil {
+			return err
+		}
+	}
+	if fdrObject.Segments == 0 {
+		return c.getDownloadStream(ctx, fdrObject)
+	}
+	return nil
+}
+
+// downloadStreamWithRetry copies the response body of fdrObject into file. On errors it retries with exponential backoff,
+// requesting a fresh presigned URL each time and resuming from the bytes already on disk if the server supports ranges.
+func (c *Client) downloadStreamWithRetry(ctx context.Context, fdrObject *commonUtils.FileDownloadResponseObject, file *os.File, bar *pb.ProgressBar, protocolText string) error {
+	_, err := io.Copy(fdrObject.Writer, c.limitReader(ctx, fdrObject.Response.Body))
+	fdrObject.Response.Body.Close()
+	c.reportTransfer(ctx, err)
+	for retryCount := 0; err != nil && ctx.Err() == nil && retryCount < MaxRetryCount; retryCount++ {
+		if waitForRetry(ctx, retryCount) != nil {
+			break
+		}
+		log.Printf("Retrying download of file \"%s\" (GUID: %s) after error: %s\n", fdrObject.Filename, fdrObject.GUID, err.Error())
+		err = c.resumeDownloadStream(ctx, fdrObject, file, bar, protocolText)
+		if err == nil {
+			_, err = io.Copy(fdrObject.Writer, c.limitReader(ctx, fdrObject.Response.Body))
+			fdrObject.Response.Body.Close()
+		}
+		c.reportTransfer(ctx, err)
+	}
+	if err != nil && ctx.Err() != nil {
+		return errors.New("Download of file \"" + fdrObject.DownloadPath + fdrObject.Filename + "\" (GUID: " + fdrObject.GUID + ") has been interrupted, it will be resumed by a retry: " + err.Error())
+	}
+	if err != nil {
+		return errors.New("Failed to download file \"" + fdrObject.DownloadPath + fdrObject.Filename + "\" (GUID: " + fdrObject.GUID + ") after " + strconv.Itoa(MaxRetryCount) + " retries, last error: " + err.Error())
+	}
+	return nil
+}
+
+// resumeDownloadStream gets a new response for fdrObject starting from the end of file, or from the beginning
+// if the server doesn't support ranges, and points the writer of fdrObject at the right position
+func (c *Client) resumeDownloadStream(ctx context.Context, fdrObject *commonUtils.FileDownloadResponseObject, file *os.File, bar *pb.ProgressBar, protocolText string) error {
+	fi, err := file.Stat()
+	if err != nil {
+		return errors.New("Error occurred when getting information for local file: " + err.Error())
+	}
+	fdrObject.Range = fi.Size()
+	// the presigned URL may have expired, so always request a fresh one
+	err = c.GetDownloadResponse(ctx, fdrObject, protocolText)
+	if err != nil {
+		return err
+	}
+	if fdrObject.Range != 0 && fdrObject.Response.StatusCode != http.StatusPartialContent {
+		fdrObject.Range = 0
+	}
+	if fdrObject.Range == 0 {
+		err = file.Truncate(0)
+		if err != nil {
+			fdrObject.Response.Body.Close()
+			return errors.New("Error occurred during truncating local file: " + err.Error())
+		}
+	}
+	_, err = file.Seek(fdrObject.Range, io.SeekStart)
+	if err != nil {
+		fdrObject.Response.Body.Close()
+		return errors.New("Error occurred during seeking in local file: " + err.Error())
+	}
+	bar.Set64(fdrObject.Range)
+
+	writers := []io.Writer{file, bar}
+	if fdrObject.HashWriter != nil {
+		if fdrObject.HashWriter.Size() != fdrObject.Range {
+			fdrObject.HashWriter = commonUtils.NewHashWriter(commonUtils.VerifiableHashAlgorithms(fdrObject.Hashes)...)
+			err = hashExistingContent(fdrObject.HashWriter, fdrObject.DownloadPath+fdrObject.Filename, fdrObject.Range)
+			if err != nil {
+				fdrObject.Response.Body.Close()
+				return errors.New("Error occurred when computing checksum of local file: " + err.Error())
+			}
+		}
+		writers = append(writers, fdrObject.HashWriter)
+	}
+	fdrObject.Writer = io.MultiWriter(writers...)
+	return nil
+}
+
+func closeDownloadResponse(fdrObject commonUtils.FileDownloadResponseObject) {
+	if fdrObject.Response != nil {
+		fdrObject.Response.Body.Close()
+	}
+}
+
+// hashExistingContent feeds the first "length" bytes of a partially downloaded local file into the hash writer
+func hashExistingContent(hashWriter *commonUtils.HashWriter, filePath string, length int64) error {
+	if length == 0 {
+		return nil
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.CopyN(hashWriter, file, length)
+	return err
+}
+
+// prepareFDRObject looks up the local filename, size and hashes of a manifest object if needed, and checks for an existing local copy of it
+func (c *Client) prepareFDRObject(ctx context.Context, obj ManifestObject, records map[string]FileRecord, downloadPath string, filenameFormat string, rename bool, protocol string, skipCompleted bool, verifyChecksum bool, renamedFiles *[]RenamedOrSkippedFileInfo, renamedFilesLock *sync.Mutex) commonUtils.FileDownloadResponseObject {
+	filename := obj.Filename
+	filesize := obj.Filesize
+	var hashes map[string]string
+	if obj.MD5 != "" {
+		hashes = map[string]string{"md5": obj.MD5}
+	}
+	// only queries Gen3 services if any of these 2 values doesn't exists in manifest, or if hashes are needed for checksum verification
+	if filename == "" || filesize == 0 {
+		record, err := c.getFileRecordFromBatch(ctx, obj.ObjectID, records)
+		// filenames are resolved one at a time, since renaming depends on the files that already exist locally
+		renamedFilesLock.Lock()
+		var recordHashes map[string]string
+		filename, filesize, recordHashes = fileInfoFromRecord(record, err, obj.ObjectID, protocol, downloadPath, filenameFormat, rename, renamedFiles)
+		renamedFilesLock.Unlock()
+		if len(recordHashes) > 0 {
+			hashes = recordHashes
+		}
+	} else if verifyChecksum && hashes == nil {
+		record, err := c.getFileRecordFromBatch(ctx, obj.ObjectID, records)
+		if err != nil {
+			log.Println(err.Error())
+		}
+		hashes = record.Hashes
+	}
+	if verifyChecksum && len(commonUtils.VerifiableHashAlgorithms(hashes)) == 0 {
+		log.Printf("No md5 or sha256 hash found for object %s, its checksum will not be verified\n", obj.ObjectID)
+	}
+	fdrObject := commonUtils.FileDownloadResponseObject{DownloadPath: downloadPath, Filename: filename}
+	if !rename {
+		fdrObject = validateLocalFileStat(downloadPath, filename, obj.ObjectID, filesize, skipCompleted)
+	}
+	fdrObject.GUID = obj.ObjectID
+	fdrObject.FileSize = filesize
+	if verifyChecksum {
+		fdrObject.Hashes = hashes
+	}
+	return fdrObject
+}
+
+// needsFileRecord tells if the file record of a manifest object has to be looked up before it can be downloaded
+func needsFileRecord(obj ManifestObject, verifyChecksum bool) bool {
+	return obj.Filename == "" || obj.Filesize == 0 || (verifyChecksum && obj.MD5 == "")
+}
+
+// getFileRecordFromBatch returns the file record of guid from the records of a bulk lookup if it's there, otherwise looks it up on its own
+func (c *Client) getFileRecordFromBatch(ctx context.Context, guid string, records map[string]FileRecord) (FileRecord, error) {
+	if record, ok := records[guid]; ok {
+		return record, nil
+	}
+	return c.GetFileRecord(ctx, guid)
+}
+
+// lookUpFileRecords retrieves the file records of the objects that need one with a single bulk request
+func (c *Client) lookUpFileRecords(ctx context.Context, batch []pendingObject, verifyChecksum bool) (map[string]FileRecord, error) {
+	guids := make([]string, 0, len(batch))
+	for _, pending := range batch {
+		if needsFileRecord(pending.obj, verifyChecksum) {
+			guids = append(guids, pending.obj.ObjectID)
+		}
+	}
+	if len(guids) == 0 {
+		return nil, nil
+	}
+	return c.GetFileRecords(ctx, guids)
+}
+
+// pendingObject is an object to download that waits for the file records of its batch to be looked up,
+// prepare then turns it into the object to download using those records
+type pendingObject struct {
+	obj     ManifestObject
+	prepare func(records map[string]FileRecord) commonUtils.FileDownloadResponseObject
+}
+
+// prepareInBatches groups the pending objects into batches of up to fileInfoBatchSize as they come in, looks up the file
+// records of each batch from Indexd with a single bulk request, fileInfoWorkers batches at a time, and sends the prepared
+// objects to the returned channel. Once a bulk request has failed, the file records are looked up one GUID at a time.
+func (c *Client) prepareInBatches(ctx context.Context, pendingCh <-chan pendingObject, verifyChecksum bool) <-chan commonUtils.FileDownloadResponseObject {
+	// Shepherd doesn't support bulk lookups, so the file records are only retrieved in batches from Indexd
+	hasShepherd, err := c.Gen3Interface.CheckForShepherdAPI(ctx, &c.Credential)
+	if err != nil {
+		log.Println("Error occurred when checking for Shepherd API: " + err.Error())
+	}
+	batchCh := make(chan []pendingObject)
+	go func() {
+		defer close(batchCh)
+		for pending := range pendingCh {
+			// take whatever else has come in already, up to a full batch
+			batch := []pendingObject{pending}
+		collect:
+			for len(batch) < fileInfoBatchSize {
+				select {
+				case next, ok := <-pendingCh:
+					if !ok {
+						break collect
+					}
+					batch = append(batch, next)
+				default:
+					break collect
+				}
+			}
+			batchCh <- batch
+		}
+	}()
+
+	var bulkLookupDisabled int32
+	fdrCh := make(chan commonUtils.FileDownloadResponseObject, pipelineBufferSize)
+	wg := sync.WaitGroup{}
+	for i := 0; i < fileInfoWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batchCh {
+				var records map[string]FileRecord
+				if !hasShepherd && atomic.LoadInt32(&bulkLookupDisabled) == 0 {
+					var err error
+					records, err = c.lookUpFileRecords(ctx, batch, verifyChecksum)
+					// the bulk endpoint is likely to be unavailable in this commons, so stop trying it after the first failure
+					if err != nil && atomic.CompareAndSwapInt32(&bulkLookupDisabled, 0, 1) {
+						log.Println(err.Error())
+						log.Println("Falling back to looking up file records one GUID at a time...")
+					}
+				}
+				for _, pending := range batch {
+					fdrCh <- pending.prepare(records)
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(fdrCh)
+	}()
+	return fdrCh
+}
+
+// DownloadOptions are the options of a download
+type DownloadOptions struct {
+	// DownloadPath is the directory in which to store the downloaded files
+	DownloadPath string
+	// FilenameFormat is the format of the local filenames, either "original", "guid" or "combined"
+	FilenameFormat string
+	// Rename appends a counter value to the filenames of files that already exist locally, only in "original" format
+	Rename bool
+	// Protocol is the preferred protocol of the presigned URLs, such as "s3" or "gs"
+	Protocol string
+	// NumParallel is the number of downloads to run in parallel, unless the concurrency of the client is tuned by a ConcurrencyController
+	NumParallel int
+	// Segments is the number of byte ranges to download each large file in concurrently, if the storage server supports ranged requests
+	Segments int
+	// SkipCompleted skips the files that are already complete locally and resumes the partially downloaded ones
+	SkipCompleted bool
+	// VerifyChecksum verifies the md5 / sha256 checksum of each downloaded file against its Indexd record
+	VerifyChecksum bool
+	// DeleteCorrupted deletes the downloaded files that fail the checksum verification
+	DeleteCorrupted bool
+}
+
+// Normalize validates the options and puts the download path and the filename format in the form used by Download
+func (options *DownloadOptions) Normalize() error {
+	if options.NumParallel < 1 {
+		return errors.New("Invalid value for option \"numparallel\": must be a positive integer! Please check your input.")
+	}
+	if options.Segments < 1 {
+		return errors.New("Invalid value for option \"segments\": must be a positive integer! Please check your input.")
+	}
+	options.DownloadPath = commonUtils.ParseRootPath(options.DownloadPath)
+	if !strings.HasSuffix(options.DownloadPath, "/") {
+		options.DownloadPath += "/"
+	}
+	options.FilenameFormat = strings.ToLower(strings.TrimSpace(options.FilenameFormat))
+	if options.FilenameFormat != "original" && options.FilenameFormat != "guid" && options.FilenameFormat != "combined" {
+		return errors.New("Invalid option found! Option \"filename-format\" can either be \"original\", \"guid\" or \"combined\" only")
+	}
+	if options.FilenameFormat != "original" && options.Rename {
+		fmt.Fprintln(logs.MessageOutput(), "NOTICE: flag \"rename\" only works if flag \"filename-format\" is \"original\"")
+		options.Rename = false
+	}
+	return nil
+}
+
+func (options DownloadOptions) protocolText() string {
+	if options.Protocol == "" {
+		return ""
+	}
+	return "?protocol=" + options.Protocol
+}
+
+// Download downloads the files of a manifest, looking up the file info of each object as needed.
+// It returns a PartialFailureError if some of the files have failed.
+func (c *Client) Download(ctx context.Context, manifestReader *ManifestReader, options DownloadOptions) error {
+	err := options.Normalize()
+	if err != nil {
+		return err
+	}
+	downloadPath := options.DownloadPath
+	protocolText := options.protocolText()
+
+	err = os.MkdirAll(downloadPath, 0766)
+	if err != nil {
+		return errors.New("Cannot create folder \"" + downloadPath + "\": " + err.Error())
+	}
+
+	// The manifest is read, the file info is looked up and the files are downloaded concurrently,
+	// with bounded channels in between so that downloads start right away and memory use stays flat
+	totalObjects := 0
+	renamedFiles := make([]RenamedOrSkippedFileInfo, 0)
+	renamedFilesLock := sync.Mutex{}
+	pendingCh := make(chan pendingObject, fileInfoBatchSize)
+	go func() {
+		defer close(pendingCh)
+		for ctx.Err() == nil {
+			obj, err := manifestReader.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				log.Println("Error occurred when reading manifest, no more files will be downloaded: " + err.Error())
+				return
+			}
+			totalObjects++
+			if obj.ObjectID == "" {
+				log.Println("Found empty object_id (GUID), skipping this entry")
+				continue
+			}
+			pendingCh <- pendingObject{obj: obj, prepare: func(records map[string]FileRecord) commonUtils.FileDownloadResponseObject {
+				return c.prepareFDRObject(ctx, obj, records, downloadPath, options.FilenameFormat, options.Rename, options.Protocol, options.SkipCompleted, options.VerifyChecksum, &renamedFiles, &renamedFilesLock)
+			}}
+		}
+	}()
+	fdrCh := c.prepareInBatches(ctx, pendingCh, options.VerifyChecksum)
+
+	log.Println("Downloading files, file info will be prepared along the way...")
+	totalCompeleted, skippedFiles, errs := c.downloadFDRObjects(ctx, fdrCh, protocolText, options.NumParallel, options.Segments, options.DeleteCorrupted)
+
+	log.Printf("Total number of objects in manifest: %d", totalObjects)
+	logDownloadSummary(totalCompeleted, renamedFiles, skippedFiles, errs)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return failedFilesError()
+}
+
+// downloadFDRObjects requests the presigned URLs of the prepared objects as they come in, and downloads them with a fixed
+// pool of numParallel transfer workers, or of as many workers as the upper bound of the concurrency controller of the client
+// if it has one. Each worker downloads one file at a time and picks up the next one as soon as it's done, so that a large
+// file doesn't hold back the others. It returns the number of files downloaded, the files skipped and the errors that have occurred.
+func (c *Client) downloadFDRObjects(ctx context.Context, fdrCh <-chan commonUtils.FileDownloadResponseObject, protocolText string, numParallel int, segments int, deleteCorrupted bool) (int, []RenamedOrSkippedFileInfo, []error) {
+	errs := make([]error, 0)
+	errCh := make(chan error)
+	errsCollected := make(chan struct{})
+	go func() {
+		for err := range errCh {
+			errs = append(errs, err)
+		}
+		close(errsCollected)
+	}()
+
+	numParallel = c.parallelism(numParallel)
+	skippedFiles := make([]RenamedOrSkippedFileInfo, 0)
+	lock := sync.Mutex{}
+	signedCh := make(chan commonUtils.FileDownloadResponseObject, numParallel)
+	wg := sync.WaitGroup{}
+	for i := 0; i < numParallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fdrObject := range fdrCh {
+				if fdrObject.Skip {
+					log.Printf("File \"%s\" (GUID: %s) has been skipped because there is a complete local copy\n", fdrObject.Filename, fdrObject.GUID)
+					lock.Lock()
+					skippedFiles = append(skippedFiles, RenamedOrSkippedFileInfo{GUID: fdrObject.GUID, OldFilename: fdrObject.Filename})
+					lock.Unlock()
+					logs.DeleteFromFailedDownloadLog(fdrObject.DownloadPath, fdrObject.Filename, true)
+					logs.RecordFileResult(fdrObject.DownloadPath+fdrObject.Filename, logs.FileResult{GUID: fdrObject.GUID, Filename: fdrObject.Filename, FilePath: fdrObject.DownloadPath + fdrObject.Filename, Status: logs.StatusSkipped, Bytes: fdrObject.FileSize})
+					continue
+				}
+				if ctx.Err() != nil { // cancelled, the remaining files are drained and kept in the failed download log for a retry
+					addInterruptedDownload(fdrObject)
+					continue
+				}
+				err := c.GetDownloadURL(ctx, &fdrObject, protocolText)
+				if err != nil {
+					errCh <- failDownload(fdrObject, err, 0)
+					continue
+				}
+				signedCh <- fdrObject
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(signedCh)
+	}()
+
+	pool := startDynamicProgressPool()
+	var totalCompeleted int32
+	workers := sync.WaitGroup{}
+	for i := 0; i < numParallel; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for fdrObject := range signedCh {
+				if ctx.Err() != nil || c.acquireTransfer(ctx) != nil {
+					addInterruptedDownload(fdrObject)
+					continue
+				}
+				err := c.downloadFile(ctx, fdrObject, protocolText, segments, pool, deleteCorrupted)
+				c.releaseTransfer()
+				if err != nil {
+					errCh <- err
+					continue
+				}
+				atomic.AddInt32(&totalCompeleted, 1)
+			}
+		}()
+	}
+	workers.Wait()
+	pool.Stop()
+	logs.FlushFailedDownloadLog()
+
+	close(errCh)
+	<-errsCollected
+	return int(totalCompeleted), skippedFiles, errs
+}
+
+// addInterruptedDownload records a file whose download hasn't started before cancellation in the failed download log,
+// so that it's downloaded by the next retry
+func addInterruptedDownload(fdrObject commonUtils.FileDownloadResponseObject) {
+	logs.AddToFailedDownloadLog(fdrObject.GUID, fdrObject.DownloadPath, fdrObject.Filename, fdrObject.FileSize, "Interrupted before the download has started", 0, true)
+}
+
+func logDownloadSummary(totalCompeleted int, renamedFiles []RenamedOrSkippedFileInfo, skippedFiles []RenamedOrSkippedFileInfo, errs []error) {
+	log.Printf("%d files downloaded.\n", totalCompeleted)
+
+	if len(renamedFiles) > 0 {
+		log.Printf("%d files have been renamed as the following:\n", len(renamedFiles))
+		for _, rfi := range renamedFiles {
+			log.Printf("File \"%s\" (GUID: %s) has been renamed as: %s\n", rfi.OldFilename, rfi.GUID, rfi.NewFilename)
+		}
+	}
+	if len(skippedFiles) > 0 {
+		log.Printf("%d files have been skipped\n", len(skippedFiles))
+	}
+	if len(errs) > 0 {
+		log.Printf("%d files have encountered an error during downloading, detailed error messages are:\n", len(errs))
+		for _, err := range errs {
+			log.Println(err.Error())
+		}
+	}
+	if failedDownloads := len(logs.GetFailedDownloadLogMap()); failedDownloads > 0 {
+		log.Printf("%d failed downloads have been recorded in \"%s\"\n", failedDownloads, logs.GetFailedDownloadLogFilename())
+	}
+}
+
+// RetryDownload downloads the files of a failed download log again, to the same paths and under the same filenames.
+// The download path, filename format and renaming options are ignored. It returns a PartialFailureError if some of the files have failed again.
+func (c *Client) RetryDownload(ctx context.Context, failedDownloadLogMap map[string]commonUtils.DownloadRetryObject, options DownloadOptions) error {
+	if options.NumParallel < 1 {
+		return errors.New("Invalid value for option \"numparallel\": must be a positive integer! Please check your input.")
+	}
+	if options.Segments < 1 {
+		return errors.New("Invalid value for option \"segments\": must be a positive integer! Please check your input.")
+	}
+
+	fmt.Fprintln(logs.MessageOutput())
+	if len(failedDownloadLogMap) == 0 {
+		log.Println("No failed download in log, no need to retry download.")
+		return nil
+	}
+	log.Printf("%d failed download(s) found in log, retrying...\n", len(failedDownloadLogMap))
+
+	// the target filenames have already been resolved by the failed run, so they are reused as they are
+	filePaths := make([]string, 0, len(failedDownloadLogMap))
+	for filePath := range failedDownloadLogMap {
+		filePaths = append(filePaths, filePath)
+	}
+	sort.Strings(filePaths)
+	// the failed download log is updated while the files are downloaded, so its entries are taken beforehand
+	retryObjects := make([]commonUtils.DownloadRetryObject, 0, len(filePaths))
+	for _, filePath := range filePaths {
+		retryObjects = append(retryObjects, failedDownloadLogMap[filePath])
+	}
+
+	pendingCh := make(chan pendingObject, fileInfoBatchSize)
+	go func() {
+		defer close(pendingCh)
+		for _, ro := range retryObjects {
+			if ctx.Err() != nil {
+				return
+			}
+			ro := ro
+			pendingCh <- pendingObject{obj: ManifestObject{ObjectID: ro.GUID, Filename: ro.Filename, Filesize: ro.FileSize}, prepare: func(records map[string]FileRecord) commonUtils.FileDownloadResponseObject {
+				return c.prepareRetryFDRObject(ctx, ro, records, options)
+			}}
+		}
+	}()
+	fdrCh := c.prepareInBatches(ctx, pendingCh, options.VerifyChecksum)
+
+	totalCompeleted, skippedFiles, errs := c.downloadFDRObjects(ctx, fdrCh, options.protocolText(), options.NumParallel, options.Segments, options.DeleteCorrupted)
+	logDownloadSummary(totalCompeleted, nil, skippedFiles, errs)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return failedFilesError()
+}
+
+// prepareRetryFDRObject looks up the size and hashes of a failed download if needed, and checks for an existing local copy
+// of it under its original path and filename
+func (c *Client) prepareRetryFDRObject(ctx context.Context, ro commonUtils.DownloadRetryObject, records map[string]FileRecord, options DownloadOptions) commonUtils.FileDownloadResponseObject {
+	var hashes map[string]string
+	if ro.FileSize == 0 || options.VerifyChecksum {
+		record, err := c.getFileRecordFromBatch(ctx, ro.GUID, records)
+		if err != nil {
+			log.Println(err.Error())
+		}
+		if ro.FileSize == 0 {
+			ro.FileSize = record.Size
+		}
+		hashes = record.Hashes
+	}
+	if options.VerifyChecksum && len(commonUtils.VerifiableHashAlgorithms(hashes)) == 0 {
+		log.Printf("No md5 or sha256 hash found for object %s, its checksum will not be verified\n", ro.GUID)
+	}
+
+	fdrObject := validateLocalFileStat(ro.DownloadPath, ro.Filename, ro.GUID, ro.FileSize, options.SkipCompleted)
+	fdrObject.GUID = ro.GUID
+	fdrObject.FileSize = ro.FileSize
+	if options.VerifyChecksum {
+		fdrObject.Hashes = hashes
+	}
+	return fdrObject
+}
@@ -0,0 +1,29 @@
+package gen3
+
+import (
+	"strconv"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+)
+
+// PartialFailureError is returned when some of the files or records that have been processed have failed
+type PartialFailureError struct {
+	Failed int
+	Total  int
+}
+
+func (e *PartialFailureError) Error() string {
+	if e.Total > 0 {
+		return strconv.Itoa(e.Failed) + " of " + strconv.Itoa(e.Total) + " file(s) or record(s) have failed"
+	}
+	return strconv.Itoa(e.Failed) + " file(s) or record(s) have failed"
+}
+
+// failedFilesError returns a PartialFailureError if some of the files recorded in the results of the command have failed
+func failedFilesError() error {
+	result := logs.NewCommandResult("", "")
+	if result.Failed == 0 {
+		return nil
+	}
+	return &PartialFailureError{Failed: result.Failed, Total: len(result.Files)}
+}
@@ -0,0 +1,243 @@
+package gen3
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Supported formats of download manifests
+const (
+	ManifestFormatJSON     = "json"
+	ManifestFormatTSV      = "tsv"
+	ManifestFormatCSV      = "csv"
+	ManifestFormatGUIDList = "guids"
+)
+
+// defaultManifestColumns lists the column names that are recognized in TSV/CSV manifests for each manifest field, in order of preference
+var defaultManifestColumns = map[string][]string{
+	"object_id":  {"object_id", "guid", "did", "id"},
+	"subject_id": {"subject_id"},
+	"file_name":  {"file_name", "filename"},
+	"file_size":  {"file_size", "size", "filesize"},
+	"md5":        {"md5", "md5sum"},
+}
+
+// ManifestReader reads the objects of a download manifest one at a time, so that large manifests don't need to be loaded into memory at once
+type ManifestReader struct {
+	next func() (ManifestObject, error)
+}
+
+// Next returns the next object in the manifest, or io.EOF if there are no more objects
+func (mr *ManifestReader) Next() (ManifestObject, error) {
+	return mr.next()
+}
+
+// NewManifestReaderFromObjects returns a ManifestReader over objects that are already in memory
+func NewManifestReaderFromObjects(objects []ManifestObject) *ManifestReader {
+	index := 0
+	return &ManifestReader{next: func() (ManifestObject, error) {
+		if index >= len(objects) {
+			return ManifestObject{}, io.EOF
+		}
+		index++
+		return objects[index-1], nil
+	}}
+}
+
+// DetectManifestFormat guesses the format of a manifest from its file extension, it returns "" if the extension is not recognized
+func DetectManifestFormat(manifestPath string) string {
+	switch strings.ToLower(filepath.Ext(manifestPath)) {
+	case ".json":
+		return ManifestFormatJSON
+	case ".tsv", ".tab":
+		return ManifestFormatTSV
+	case ".csv":
+		return ManifestFormatCSV
+	case ".txt", ".list":
+		return ManifestFormatGUIDList
+	}
+	return ""
+}
+
+// ParseManifestColumns parses a column mapping such as "object_id=guid,file_size=bytes" into a map from manifest field to column name
+func ParseManifestColumns(columnsSpec string) (map[string]string, error) {
+	columns := make(map[string]string)
+	if strings.TrimSpace(columnsSpec) == "" {
+		return columns, nil
+	}
+	for _, pair := range strings.Split(columnsSpec, ",") {
+		keyValue := strings.SplitN(pair, "=", 2)
+		if len(keyValue) != 2 || strings.TrimSpace(keyValue[1]) == "" {
+			return nil, errors.New("Invalid column mapping \"" + pair + "\", expected <field>=<column>")
+		}
+		field := strings.ToLower(strings.TrimSpace(keyValue[0]))
+		if _, ok := defaultManifestColumns[field]; !ok {
+			return nil, errors.New("Unknown manifest field \"" + field + "\" in column mapping, supported fields are object_id, subject_id, file_name, file_size and md5")
+		}
+		columns[field] = strings.TrimSpace(keyValue[1])
+	}
+	return columns, nil
+}
+
+// NewManifestReader returns a ManifestReader for a manifest of the given format. If format is "", the format is
+// guessed from the content. columns overrides the names of the columns to read each manifest field from in TSV/CSV manifests.
+func NewManifestReader(reader io.Reader, format string, columns map[string]string) (*ManifestReader, error) {
+	bufferedReader := bufio.NewReader(reader)
+	if format == "" {
+		format = sniffManifestFormat(bufferedReader)
+	}
+	switch format {
+	case ManifestFormatJSON:
+		return newJSONManifestReader(bufferedReader)
+	case ManifestFormatTSV:
+		return newDelimitedManifestReader(bufferedReader, '\t', columns)
+	case ManifestFormatCSV:
+		return newDelimitedManifestReader(bufferedReader, ',', columns)
+	case ManifestFormatGUIDList:
+		return newGUIDListManifestReader(bufferedReader), nil
+	}
+	return nil, errors.New("Invalid manifest format \"" + format + "\", supported formats are \"json\", \"tsv\", \"csv\" and \"guids\"")
+}
+
+// sniffManifestFormat guesses the format of a manifest from its first line
+func sniffManifestFormat(reader *bufio.Reader) string {
+	// the error is ignored since a short manifest just returns fewer bytes
+	head, _ := reader.Peek(4096)
+	head = []byte(strings.TrimPrefix(strings.TrimSpace(string(head)), "\ufeff"))
+	if len(head) > 0 && (head[0] == '[' || head[0] == '{') {
+		return ManifestFormatJSON
+	}
+	firstLine := strings.SplitN(string(head), "\n", 2)[0]
+	if strings.Contains(firstLine, "\t") {
+		return ManifestFormatTSV
+	}
+	if strings.Contains(firstLine, ",") {
+		return ManifestFormatCSV
+	}
+	return ManifestFormatGUIDList
+}
+
+func newJSONManifestReader(reader io.Reader) (*ManifestReader, error) {
+	decoder := json.NewDecoder(reader)
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, errors.New("Error occurred when reading JSON manifest: " + err.Error())
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("Error occurred when reading JSON manifest: a JSON manifest must be an array of objects")
+	}
+	return &ManifestReader{next: func() (ManifestObject, error) {
+		var obj ManifestObject
+		if !decoder.More() {
+			return obj, io.EOF
+		}
+		err := decoder.Decode(&obj)
+		if err != nil {
+			return obj, errors.New("Error occurred when reading JSON manifest: " + err.Error())
+		}
+		return obj, nil
+	}}, nil
+}
+
+func newDelimitedManifestReader(reader io.Reader, delimiter rune, columns map[string]string) (*ManifestReader, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.Comma = delimiter
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+	csvReader.ReuseRecord = true
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, errors.New("Error occurred when reading manifest header: " + err.Error())
+	}
+	headerIndices := make(map[string]int)
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if _, present := headerIndices[column]; !present {
+			headerIndices[column] = i
+		}
+	}
+
+	fieldIndices := make(map[string]int)
+	for field, candidates := range defaultManifestColumns {
+		if column, ok := columns[field]; ok {
+			candidates = []string{column}
+		}
+		for _, candidate := range candidates {
+			if index, present := headerIndices[strings.ToLower(candidate)]; present {
+				fieldIndices[field] = index
+				break
+			}
+		}
+	}
+	if _, ok := fieldIndices["object_id"]; !ok {
+		return nil, fmt.Errorf("No object_id column found in manifest header %v, use \"--manifest-columns=object_id=<column>\" to specify it", header)
+	}
+
+	getField := func(record []string, field string) string {
+		index, ok := fieldIndices[field]
+		if !ok || index >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[index])
+	}
+	return &ManifestReader{next: func() (ManifestObject, error) {
+		var obj ManifestObject
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return obj, io.EOF
+		}
+		if err != nil {
+			return obj, errors.New("Error occurred when reading manifest: " + err.Error())
+		}
+		obj.ObjectID = getField(record, "object_id")
+		obj.SubjectID = getField(record, "subject_id")
+		obj.Filename = getField(record, "file_name")
+		obj.MD5 = getField(record, "md5")
+		if fileSize := getField(record, "file_size"); fileSize != "" {
+			obj.Filesize, err = strconv.ParseInt(fileSize, 10, 64)
+			if err != nil {
+				line, _ := csvReader.FieldPos(0)
+				return obj, fmt.Errorf("Invalid file size \"%s\" on line %d of manifest", fileSize, line)
+			}
+		}
+		return obj, nil
+	}}, nil
+}
+
+func newGUIDListManifestReader(reader io.Reader) *ManifestReader {
+	scanner := bufio.NewScanner(reader)
+	return &ManifestReader{next: func() (ManifestObject, error) {
+		for scanner.Scan() {
+			guid := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+			if guid == "" || strings.HasPrefix(guid, "#") {
+				continue
+			}
+			// skip a header line if there is one
+			if isObjectIDColumn(guid) {
+				continue
+			}
+			return ManifestObject{ObjectID: guid}, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return ManifestObject{}, errors.New("Error occurred when reading manifest: " + err.Error())
+		}
+		return ManifestObject{}, io.EOF
+	}}
+}
+
+func isObjectIDColumn(column string) bool {
+	for _, candidate := range defaultManifestColumns["object_id"] {
+		if strings.EqualFold(column, candidate) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package gen3
+
+import (
+	"math"
+	"sort"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+)
+
+// UnfinishedMultipartUpload is a multipart upload that has been started and not completed.
+// The storage keeps its uploaded parts until the upload is completed, or cleaned up by a lifecycle rule of the bucket.
+type UnfinishedMultipartUpload struct {
+	FilePath string `json:"file_path"`
+	Filename string `json:"file_name"`
+	GUID     string `json:"guid"`
+	// UploadID and Key identify the upload in the storage, they are only known for the uploads of the local multipart state
+	UploadID      string `json:"upload_id,omitempty"`
+	Key           string `json:"key,omitempty"`
+	Bucket        string `json:"bucket,omitempty"`
+	FileSize      int64  `json:"file_size,omitempty"`
+	UploadedParts int    `json:"uploaded_parts"`
+	TotalParts    int    `json:"total_parts,omitempty"`
+	// Failed tells if the file is in the failed log, Error being its last error
+	Failed bool   `json:"failed"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ListMultipartUploads returns the unfinished multipart uploads recorded in the local multipart state, along with the
+// multipart uploads of failedLogMap, sorted by file path. failedLogMap may be nil.
+func ListMultipartUploads(failedLogMap map[string]commonUtils.RetryObject) []UnfinishedMultipartUpload {
+	uploads := make(map[string]UnfinishedMultipartUpload)
+	for _, state := range logs.GetMultipartStates() {
+		upload := UnfinishedMultipartUpload{FilePath: state.FilePath, Filename: state.Filename, GUID: state.GUID, UploadID: state.UploadID, Key: state.Key, Bucket: state.Bucket, FileSize: state.FileSize, UploadedParts: len(state.ETags)}
+		if state.ChunkSize > 0 {
+			upload.TotalParts = int(math.Ceil(float64(state.FileSize) / float64(state.ChunkSize)))
+		}
+		uploads[state.FilePath] = upload
+	}
+	for filePath, ro := range failedLogMap {
+		if !ro.Multipart || ro.GUID == "" {
+			continue
+		}
+		upload, present := uploads[filePath]
+		if !present {
+			upload = UnfinishedMultipartUpload{FilePath: ro.FilePath, Filename: ro.Filename, GUID: ro.GUID, Bucket: ro.Bucket}
+		}
+		upload.Failed = true
+		upload.Error = ro.Error
+		uploads[filePath] = upload
+	}
+
+	sorted := make([]UnfinishedMultipartUpload, 0, len(uploads))
+	for _, upload := range uploads {
+		sorted = append(sorted, upload)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].FilePath < sorted[j].FilePath
+	})
+	return sorted
+}
@@ -0,0 +1,94 @@
+package gen3
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+	pb "gopkg.in/cheggaaa/pb.v1"
+)
+
+// progressPool shows the progress bars of the files that are being transferred concurrently. Unlike pb.Pool, bars can be
+// added while it's running, and the bars of the completed transfers are printed one last time and then let go, so that
+// only the transfers in progress are redrawn however many files go through the pool.
+type progressPool struct {
+	output    io.Writer
+	lock      sync.Mutex
+	bars      []*pb.ProgressBar
+	lastLines int
+	stop      chan struct{}
+	stopped   chan struct{}
+}
+
+// startDynamicProgressPool starts showing the progress bars added to the returned pool until it's stopped
+func startDynamicProgressPool() *progressPool {
+	pool := &progressPool{output: os.Stdout, stop: make(chan struct{}), stopped: make(chan struct{})}
+	if logs.IsJSONOutput() {
+		pool.output = ioutil.Discard
+	}
+	go pool.refresh()
+	return pool
+}
+
+// Add starts showing the progress bar of a transfer that has just started
+func (pp *progressPool) Add(bar *pb.ProgressBar) {
+	bar.ManualUpdate = true
+	bar.NotPrint = true
+	bar.Start()
+	pp.lock.Lock()
+	defer pp.lock.Unlock()
+	pp.bars = append(pp.bars, bar)
+}
+
+// Stop prints the progress bars one last time and stops refreshing them
+func (pp *progressPool) Stop() {
+	close(pp.stop)
+	<-pp.stopped
+}
+
+func (pp *progressPool) refresh() {
+	defer close(pp.stopped)
+	ticker := time.NewTicker(pb.DefaultRefreshRate)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			pp.print()
+		case <-pp.stop:
+			pp.print()
+			return
+		}
+	}
+}
+
+// print redraws the bars printed last time: the bars of the completed transfers are printed above the ones in progress,
+// and are left out of the next redraws
+func (pp *progressPool) print() {
+	pp.lock.Lock()
+	defer pp.lock.Unlock()
+	var out strings.Builder
+	if pp.lastLines > 0 {
+		fmt.Fprintf(&out, "\033[%dA", pp.lastLines)
+	}
+	inProgress := make([]*pb.ProgressBar, 0, len(pp.bars))
+	for _, bar := range pp.bars {
+		if !bar.IsFinished() {
+			inProgress = append(inProgress, bar)
+			continue
+		}
+		bar.Update()
+		fmt.Fprintf(&out, "\r%s\033[K\n", bar.String())
+	}
+	for _, bar := range inProgress {
+		bar.Update()
+		fmt.Fprintf(&out, "\r%s\033[K\n", bar.String())
+	}
+	pp.bars = inProgress
+	pp.lastLines = len(inProgress)
+	fmt.Fprint(pp.output, out.String())
+}
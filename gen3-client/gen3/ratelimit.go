@@ -0,0 +1,213 @@
+package gen3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimitChunkSize is the most bytes a throttled reader reads at once, so that the transfers sharing a
+// RateLimiter take turns in small steps
+const rateLimitChunkSize = 32 * KB
+
+// RateWindow raises or lowers the rate limit during a time of day. A window that ends before it starts wraps around midnight.
+type RateWindow struct {
+	// Start and End are the minutes since midnight, local time, at which the window starts (inclusive) and ends (exclusive)
+	Start int
+	End   int
+	// BytesPerSecond is the rate limit during the window, 0 for no limit
+	BytesPerSecond int64
+}
+
+func (window RateWindow) contains(minute int) bool {
+	if window.Start <= window.End {
+		return minute >= window.Start && minute < window.End
+	}
+	return minute >= window.Start || minute < window.End
+}
+
+// RateLimiter is a token bucket that caps the throughput of all the uploads and downloads sharing it
+type RateLimiter struct {
+	bytesPerSecond int64
+	schedule       []RateWindow
+
+	lock     sync.Mutex
+	tokens   float64
+	lastFill time.Time
+}
+
+// NewRateLimiter returns a RateLimiter of bytesPerSecond, or without limit if it's 0, except during the windows of the schedule
+func NewRateLimiter(bytesPerSecond int64, schedule []RateWindow) *RateLimiter {
+	return &RateLimiter{bytesPerSecond: bytesPerSecond, schedule: schedule}
+}
+
+// Rate returns the rate limit in bytes per second at a point in time, 0 for no limit
+func (l *RateLimiter) Rate(t time.Time) int64 {
+	minute := t.Hour()*60 + t.Minute()
+	for _, window := range l.schedule {
+		if window.contains(minute) {
+			return window.BytesPerSecond
+		}
+	}
+	return l.bytesPerSecond
+}
+
+// WaitN takes n bytes from the bucket, and waits until the bucket has refilled if it's overdrawn. The bucket holds
+// up to one second worth of bytes, so that a transfer that has been idle doesn't burst above the limit.
+func (l *RateLimiter) WaitN(ctx context.Context, n int) error {
+	l.lock.Lock()
+	now := time.Now()
+	rate := float64(l.Rate(now))
+	if rate <= 0 {
+		l.lastFill = now
+		l.tokens = 0
+		l.lock.Unlock()
+		return ctx.Err()
+	}
+	if !l.lastFill.IsZero() {
+		l.tokens += now.Sub(l.lastFill).Seconds() * rate
+	}
+	if l.tokens > rate {
+		l.tokens = rate
+	}
+	l.lastFill = now
+	l.tokens -= float64(n)
+	wait := time.Duration(-l.tokens / rate * float64(time.Second))
+	l.lock.Unlock()
+
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttledReader reads from reader no faster than its RateLimiter allows
+type throttledReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *RateLimiter
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > int(rateLimitChunkSize) {
+		p = p[:rateLimitChunkSize]
+	}
+	n, err := tr.reader.Read(p)
+	if n > 0 {
+		if waitErr := tr.limiter.WaitN(tr.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// limitReader returns a reader that reads from reader within the rate limit of the client, or reader itself if the client has none.
+// The bytes read are also reported to the concurrency controller of the client, if it has one.
+func (c *Client) limitReader(ctx context.Context, reader io.Reader) io.Reader {
+	if c.Concurrency != nil {
+		reader = &countingReader{reader: reader, controller: c.Concurrency}
+	}
+	if c.RateLimiter == nil {
+		return reader
+	}
+	return &throttledReader{ctx: ctx, reader: reader, limiter: c.RateLimiter}
+}
+
+// ParseRate parses a rate limit such as "200MB/s", "512KB" or "1.5GB/s" into bytes per second.
+// The units are binary, as in FormatSize. An empty string or "0" means no limit.
+func ParseRate(rate string) (int64, error) {
+	value := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(rate)), "/S")
+	bytesPerSecond, ok := parseBytes(value)
+	if !ok {
+		return 0, errors.New("Invalid rate \"" + rate + "\". A valid rate looks like: 200MB/s")
+	}
+	return bytesPerSecond, nil
+}
+
+// ParseSize parses a size such as "64MB", "512KB" or "1.5GB" into bytes. The units are binary, as in FormatSize.
+// An empty string is 0.
+func ParseSize(size string) (int64, error) {
+	bytes, ok := parseBytes(strings.ToUpper(strings.TrimSpace(size)))
+	if !ok {
+		return 0, errors.New("Invalid size \"" + size + "\". A valid size looks like: 64MB")
+	}
+	return bytes, nil
+}
+
+// parseBytes parses an upper case number of bytes with an optional unit suffix
+func parseBytes(value string) (int64, bool) {
+	if value == "" || value == "0" {
+		return 0, true
+	}
+	unit := int64(1)
+	for _, suffix := range []struct {
+		name string
+		size int64
+	}{{"TB", TB}, {"GB", GB}, {"MB", MB}, {"KB", KB}, {"T", TB}, {"G", GB}, {"M", MB}, {"K", KB}, {"B", 1}} {
+		if strings.HasSuffix(value, suffix.name) {
+			value = strings.TrimSuffix(value, suffix.name)
+			unit = suffix.size
+			break
+		}
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || number < 0 {
+		return 0, false
+	}
+	return int64(number * float64(unit)), true
+}
+
+// ParseRateSchedule parses a comma separated list of time-of-day windows and their rate limits, such as
+// "19:00-07:00=1GB/s,12:00-13:00=500MB/s". A rate of 0 lifts the limit during its window.
+func ParseRateSchedule(schedule string) ([]RateWindow, error) {
+	windows := make([]RateWindow, 0)
+	for _, entry := range strings.Split(schedule, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		invalidErr := errors.New("Invalid rate schedule entry \"" + entry + "\". A valid entry looks like: 19:00-07:00=1GB/s")
+		timesAndRate := strings.SplitN(entry, "=", 2)
+		if len(timesAndRate) != 2 {
+			return nil, invalidErr
+		}
+		times := strings.SplitN(timesAndRate[0], "-", 2)
+		if len(times) != 2 {
+			return nil, invalidErr
+		}
+		startMinute, err := parseTimeOfDay(times[0])
+		if err != nil {
+			return nil, invalidErr
+		}
+		endMinute, err := parseTimeOfDay(times[1])
+		if err != nil {
+			return nil, invalidErr
+		}
+		bytesPerSecond, err := ParseRate(timesAndRate[1])
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, RateWindow{Start: startMinute, End: endMinute, BytesPerSecond: bytesPerSecond})
+	}
+	return windows, nil
+}
+
+// parseTimeOfDay parses a time of day such as "07:30" into the minutes since midnight
+func parseTimeOfDay(timeOfDay string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(timeOfDay))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,320 @@
+package gen3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+)
+
+var multipartUploadLock sync.Mutex
+
+// bufferPool hands out up to count buffers of the same size, allocated as they are first needed and reused afterwards,
+// so that the memory taken by the parts in flight doesn't depend on the number of workers
+type bufferPool struct {
+	size      int64
+	count     int
+	free      chan []byte
+	lock      sync.Mutex
+	allocated int
+}
+
+func newBufferPool(size int64, count int) *bufferPool {
+	if count < 1 {
+		count = 1
+	}
+	return &bufferPool{size: size, count: count, free: make(chan []byte, count)}
+}
+
+// get returns a free buffer, and waits for one to be put back if all of them are in use
+func (p *bufferPool) get(ctx context.Context) ([]byte, error) {
+	select {
+	case buf := <-p.free:
+		return buf, nil
+	default:
+	}
+	p.lock.Lock()
+	if p.allocated < p.count {
+		p.allocated++
+		p.lock.Unlock()
+		return make([]byte, p.size), nil
+	}
+	p.lock.Unlock()
+	select {
+	case buf := <-p.free:
+		return buf, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// put gives a buffer returned by get back to the pool
+func (p *bufferPool) put(buf []byte) {
+	p.free <- buf[:cap(buf)]
+}
+
+func retry(ctx context.Context, attempts int, filePath string, guid string, f func() error) (err error) {
+	for i := 0; ; i++ {
+		err = f()
+		if err == nil {
+			return
+		}
+
+		if i >= (attempts - 1) {
+			break
+		}
+
+		if waitErr := waitForRetry(ctx, i); waitErr != nil {
+			return fmt.Errorf("Interrupted after %d attempts, last error: %s", i+1, err)
+		}
+
+		log.Println("Retrying after error: ", err)
+	}
+	return fmt.Errorf("After %d attempts, last error: %s", attempts, err)
+}
+
+func (c *Client) multipartUpload(ctx context.Context, fileInfo FileInfo, retryCount int, bucketName string) error {
+	// NOTE @mpingram -- multipartUpload does not yet use the new Shepherd API
+	// because Shepherd does not yet support multipart uploads.
+	file, err := os.Open(fileInfo.FilePath)
+	if err != nil {
+		err = fmt.Errorf("FAILED multipart upload for %s due to file open error: %s", fileInfo.FilePath, err.Error())
+		logs.AddToFailedLog(fileInfo.FilePath, fileInfo.Filename, fileInfo.FileMetadata, "", err.Error(), retryCount, true, true)
+		return err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		err = fmt.Errorf("FAILED multipart upload for %s: file stat error, file may be missing or unreadable because of permissions", fileInfo.Filename)
+		logs.AddToFailedLog(fileInfo.FilePath, fileInfo.Filename, fileInfo.FileMetadata, "", err.Error(), retryCount, true, true)
+		return err
+	}
+
+	if fi.Size() > MultipartFileSizeLimit {
+		err = fmt.Errorf("FAILED multipart upload for %s: the file size has exceeded the limit allowed and cannot be uploaded. The maximum allowed file size is %s", fi.Name(), FormatSize(MultipartFileSizeLimit))
+		logs.AddToFailedLog(fileInfo.FilePath, fileInfo.Filename, fileInfo.FileMetadata, "", err.Error(), retryCount, true, true)
+		return err
+	}
+
+	chunkSize, numOfChunks := CalculateChunkSize(fi.Size(), c.MultipartChunkSize)
+
+	// resume the previous multipart upload of this file if it was interrupted, as long as the file has not changed since
+	state, resumable := logs.GetMultipartState(fileInfo.FilePath)
+	if resumable && (state.FileSize != fi.Size() || !state.ModTime.Equal(fi.ModTime()) || state.Filename != fileInfo.Filename || (bucketName != "" && state.Bucket != bucketName) || state.ChunkSize <= 0) {
+		log.Printf("File \"%s\" has changed since its last multipart upload attempt, the upload will start over\n", fileInfo.FilePath)
+		logs.DeleteFromMultipartState(fileInfo.FilePath)
+		resumable = false
+	}
+
+	var uploadID, guid, key string
+	if resumable {
+		uploadID, guid, key, chunkSize, bucketName = state.UploadID, state.GUID, state.Key, state.ChunkSize, state.Bucket
+		numOfChunks = int(math.Ceil(float64(fi.Size()) / float64(chunkSize)))
+		log.Printf("Resuming multipart upload of \"%s\" to GUID %s, %d of %d parts have already been uploaded\n", fileInfo.FilePath, guid, len(state.ETags), numOfChunks)
+	} else {
+		uploadID, guid, err = c.InitMultipartUpload(ctx, fileInfo.Filename, bucketName)
+		if err != nil {
+			err = fmt.Errorf("FAILED multipart upload for %s: %s", fileInfo.Filename, err.Error())
+			logs.AddToFailedLog(fileInfo.FilePath, fileInfo.Filename, fileInfo.FileMetadata, guid, err.Error(), retryCount, true, true)
+			return err
+		}
+		key = guid + "/" + fileInfo.Filename
+		state = commonUtils.MultipartStateObject{FilePath: fileInfo.FilePath, Filename: fileInfo.Filename, GUID: guid, UploadID: uploadID, Key: key, Bucket: bucketName, FileSize: fi.Size(), ModTime: fi.ModTime(), ChunkSize: chunkSize}
+		logs.SaveMultipartState(state)
+	}
+	// update failed log with new guid
+	logs.AddToFailedLog(fileInfo.FilePath, fileInfo.Filename, fileInfo.FileMetadata, guid, "", retryCount, true, true)
+
+	var parts []MultipartPartObject
+	var uploadGone bool
+	bar := newProgressBar(fi.Size(), fileInfo.Filename)
+	bar.Start()
+	for partNumber, eTag := range state.ETags {
+		if partNumber < 1 || partNumber > numOfChunks {
+			continue
+		}
+		parts = append(parts, MultipartPartObject{PartNumber: partNumber, ETag: eTag})
+		if partNumber == numOfChunks {
+			bar.Add64(fi.Size() - int64(partNumber-1)*chunkSize)
+		} else {
+			bar.Add64(chunkSize)
+		}
+	}
+
+	// uploadPart uploads a part of the file that has been read into buf, and returns its ETag
+	uploadPart := func(chunkIndex int, buf []byte) (string, error) {
+		var presignedURL string
+		err := retry(ctx, MaxRetryCount, fileInfo.FilePath, guid, func() (err error) {
+			presignedURL, err = c.GenerateMultipartPresignedURL(ctx, key, uploadID, chunkIndex, bucketName)
+			return
+		})
+		if err != nil {
+			return "", err
+		}
+
+		var eTag string
+		err = retry(ctx, MaxRetryCount, fileInfo.FilePath, guid, func() (err error) {
+			defer func() { c.reportTransfer(ctx, err) }()
+			req, err := http.NewRequestWithContext(ctx, http.MethodPut, presignedURL, c.limitReader(ctx, bytes.NewReader(buf)))
+			if err != nil {
+				err = errors.New("Error occurred when creating HTTP request: " + err.Error())
+				return
+			}
+			req.ContentLength = int64(len(buf))
+			partMD5 := md5.Sum(buf)
+			req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(partMD5[:]))
+			resp, err := c.httpClient().Do(req)
+			if err != nil {
+				err = errors.New("Error occurred during upload: " + err.Error())
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode == 404 { // the multipart upload has been aborted or has expired
+				multipartUploadLock.Lock()
+				uploadGone = true
+				multipartUploadLock.Unlock()
+			}
+			if resp.StatusCode != 200 {
+				err = errors.New("Upload request got a non-200 response with status code " + strconv.Itoa(resp.StatusCode))
+				return
+			} else if eTag = resp.Header.Get("ETag"); eTag == "" {
+				err = errors.New("No ETag found in header")
+				return
+			}
+			return
+		})
+		return eTag, err
+	}
+
+	// a fixed pool of workers uploads the parts, and the buffers the parts are read into are bounded by maxMultipartBufferMemory
+	numOfWorkers := c.parallelism(defaultNumOfWorkers)
+	if remaining := numOfChunks - len(parts); numOfWorkers > remaining {
+		numOfWorkers = remaining
+	}
+	buffers := newBufferPool(chunkSize, MultipartBufferCount(chunkSize, numOfWorkers))
+
+	type filePart struct {
+		index int
+		buf   []byte
+	}
+	partCh := make(chan filePart, numOfWorkers)
+	wg := sync.WaitGroup{}
+	for i := 0; i < numOfWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for part := range partCh {
+				if ctx.Err() != nil || c.acquireTransfer(ctx) != nil { // cancelled, the remaining parts are left for a resumed upload
+					buffers.put(part.buf)
+					continue
+				}
+				eTag, err := uploadPart(part.index, part.buf)
+				n := len(part.buf)
+				buffers.put(part.buf)
+				c.releaseTransfer()
+				if err != nil {
+					logs.AddToFailedLog(fileInfo.FilePath, fileInfo.Filename, fileInfo.FileMetadata, guid, err.Error(), retryCount, true, true)
+					log.Println(err.Error())
+					continue
+				}
+
+				multipartUploadLock.Lock() // to avoid racing conditions
+				parts = append(parts, (MultipartPartObject{PartNumber: part.index, ETag: eTag}))
+				bar.Add(n)
+				multipartUploadLock.Unlock()
+				logs.AddPartToMultipartState(fileInfo.FilePath, part.index, eTag)
+			}
+		}()
+	}
+
+	// the parts are read in order and handed to the workers, so that the checksums of the whole file are computed from
+	// the same reads, the parts that have already been uploaded are only read for the checksums
+	hashWriter := commonUtils.NewHashWriter(c.uploadHashAlgorithms()...)
+	var hashErr error
+	for i := 1; i <= numOfChunks && ctx.Err() == nil; i++ {
+		offset := int64(i-1) * chunkSize
+		size := chunkSize
+		if i == numOfChunks {
+			size = fi.Size() - offset
+		}
+		reader := io.TeeReader(io.NewSectionReader(file, offset, size), hashWriter)
+		if _, uploaded := state.ETags[i]; uploaded {
+			if _, err := io.Copy(ioutil.Discard, reader); err != nil {
+				hashErr = err
+				break
+			}
+			continue
+		}
+		buf, err := buffers.get(ctx)
+		if err != nil {
+			break
+		}
+		if _, err := io.ReadFull(reader, buf[:size]); err != nil {
+			buffers.put(buf)
+			hashErr = err
+			break
+		}
+		partCh <- filePart{index: i, buf: buf[:size]}
+	}
+	close(partCh)
+	wg.Wait()
+	bar.Finish()
+	logs.FlushMultipartState()
+
+	if ctx.Err() != nil {
+		err = fmt.Errorf("FAILED multipart upload for %s: %s, the uploaded parts have been kept for the next attempt", fileInfo.Filename, ctx.Err().Error())
+		logs.AddToFailedLog(fileInfo.FilePath, fileInfo.Filename, fileInfo.FileMetadata, guid, err.Error(), retryCount, true, true)
+		return err
+	}
+
+	// the parts that have been read and uploaded are kept for the next attempt
+	if hashErr != nil {
+		err = fmt.Errorf("FAILED multipart upload for %s: file read error: %s", fileInfo.Filename, hashErr.Error())
+		logs.AddToFailedLog(fileInfo.FilePath, fileInfo.Filename, fileInfo.FileMetadata, guid, err.Error(), retryCount, true, true)
+		return err
+	}
+
+	if uploadGone {
+		logs.DeleteFromMultipartState(fileInfo.FilePath)
+		err = fmt.Errorf("FAILED multipart upload for %s: the multipart upload no longer exists in the storage, the upload will start over on the next attempt", fileInfo.Filename)
+		logs.AddToFailedLog(fileInfo.FilePath, fileInfo.Filename, fileInfo.FileMetadata, guid, err.Error(), retryCount, true, true)
+		return err
+	}
+
+	if len(parts) != numOfChunks {
+		err = fmt.Errorf("FAILED multipart upload for %s: Total number of received ETags doesn't match the total number of chunks", fileInfo.Filename)
+		logs.AddToFailedLog(fileInfo.FilePath, fileInfo.Filename, fileInfo.FileMetadata, guid, err.Error(), retryCount, true, true)
+		return err
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].PartNumber < parts[j].PartNumber // sort parts in ascending order
+	})
+
+	if err = c.CompleteMultipartUpload(ctx, key, uploadID, parts, bucketName); err != nil {
+		err = fmt.Errorf("FAILED multipart upload for %s: %s", fileInfo.Filename, err.Error())
+		logs.AddToFailedLog(fileInfo.FilePath, fileInfo.Filename, fileInfo.FileMetadata, guid, err.Error(), retryCount, true, true)
+		return err
+	}
+
+	log.Printf("Successfully uploaded file \"%s\" to GUID %s.\n", fileInfo.FilePath, guid)
+	logs.DeleteFromMultipartState(fileInfo.FilePath)
+	logs.DeleteFromFailedLog(fileInfo.FilePath, true)
+	logs.WriteToSucceededLog(fileInfo.FilePath, guid, fi.Size(), hashWriter.Sums(), true)
+	return nil
+}
@@ -0,0 +1,407 @@
+package gen3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+)
+
+// UploadOptions are the options of an upload
+type UploadOptions struct {
+	// UploadPath is the directory or file pattern the files have been found in, it's used for naming files with IncludeSubDirName
+	UploadPath string
+	// Bucket is the bucket to which files will be uploaded, Gen3's configured DATA_UPLOAD_BUCKET is used if it's empty
+	Bucket string
+	// Batch uploads up to NumParallel files in parallel, instead of one at a time
+	Batch bool
+	// NumParallel is the number of uploads to run in parallel in batch mode, unless the concurrency of the client is tuned by a ConcurrencyController
+	NumParallel int
+	// ForceMultipart uses multipart upload for every file of at least 5MB
+	ForceMultipart bool
+	// IncludeSubDirName includes the subdirectory names of the files under UploadPath in their filenames
+	IncludeSubDirName bool
+	// Metadata uploads the metadata found in the [filename]_metadata.json file of each file alongside it
+	Metadata bool
+}
+
+// Upload uploads files to new GUIDs, and retries the failed ones with exponential backoff.
+// Files found in the succeeded log are skipped. It returns a PartialFailureError if some of the files have failed.
+func (c *Client) Upload(ctx context.Context, filePaths []string, options UploadOptions) error {
+	if options.Metadata {
+		hasShepherd, err := c.Gen3Interface.CheckForShepherdAPI(ctx, &c.Credential)
+		if err != nil {
+			log.Printf("WARNING: Error when checking for Shepherd API: %v", err)
+		} else if !hasShepherd {
+			return errors.New("ERROR: Metadata upload (`--metadata`) is not supported in the environment you are uploading to. Double check that you are uploading to the right profile.")
+		}
+	}
+
+	singlepartFilePaths, multipartFilePaths := separateSingleAndMultipartUploads(filePaths, options.ForceMultipart)
+
+	if options.Batch {
+		workers, respCh, errCh, batchFURObjects := initBatchUploadChannels(c.parallelism(options.NumParallel), len(singlepartFilePaths))
+		for _, filePath := range singlepartFilePaths {
+			if ctx.Err() != nil {
+				break
+			}
+			fileInfo, err := ProcessFilename(options.UploadPath, filePath, options.IncludeSubDirName, options.Metadata)
+			if err != nil {
+				logs.AddToFailedLog(filePath, filepath.Base(filePath), commonUtils.FileMetadata{}, "", "Process filename error: "+err.Error(), 0, false, true)
+				log.Println("Process filename error: " + err.Error())
+				continue
+			}
+			if len(batchFURObjects) >= workers {
+				c.batchUpload(ctx, batchFURObjects, workers, respCh, errCh, options.Bucket)
+				batchFURObjects = make([]commonUtils.FileUploadRequestObject, 0)
+			}
+			furObject := commonUtils.FileUploadRequestObject{FilePath: fileInfo.FilePath, Filename: fileInfo.Filename, FileMetadata: fileInfo.FileMetadata, GUID: ""}
+			batchFURObjects = append(batchFURObjects, furObject)
+		}
+		c.batchUpload(ctx, batchFURObjects, workers, respCh, errCh, options.Bucket)
+
+		if len(errCh) > 0 {
+			close(errCh)
+			for err := range errCh {
+				if err != nil {
+					log.Printf("Error occurred during uploading: %s\n", err.Error())
+				}
+			}
+		}
+	} else {
+		c.processSingleUploads(ctx, singlepartFilePaths, options)
+	}
+
+	// multipart upload for large files here
+	var multipartErr error
+	if len(multipartFilePaths) > 0 {
+		multipartErr = c.processMultipartUpload(ctx, multipartFilePaths, options)
+	}
+
+	if ctx.Err() != nil {
+		addInterruptedUploads(singlepartFilePaths, false, options)
+		addInterruptedUploads(multipartFilePaths, true, options)
+	}
+
+	var retryErr error
+	if !logs.IsFailedLogMapEmpty() {
+		retryErr = c.RetryUpload(ctx, logs.GetFailedLogMap())
+	}
+	if multipartErr != nil {
+		return multipartErr
+	}
+	if retryErr != nil {
+		return retryErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return failedFilesError()
+}
+
+// UploadToGUID uploads a single file to the pre-existing GUID of a record, without retrying it if it fails
+func (c *Client) UploadToGUID(ctx context.Context, filePath string, guid string, bucketName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	filename := filepath.Base(filePath)
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		logs.AddToFailedLog(filePath, filename, commonUtils.FileMetadata{}, "", "The file does not exist locally", 0, false, true)
+		logs.IncrementScore(logs.ScoreBoardLen - 1)
+		return fmt.Errorf("The file you specified \"%s\" does not exist locally.", filePath)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		logs.AddToFailedLog(filePath, filename, commonUtils.FileMetadata{}, "", "File open error: "+err.Error(), 0, false, true)
+		logs.IncrementScore(logs.ScoreBoardLen - 1)
+		return errors.New("File open error: " + err.Error())
+	}
+	defer file.Close()
+
+	furObject := commonUtils.FileUploadRequestObject{FilePath: filePath, Filename: filename, GUID: guid, Bucket: bucketName}
+	furObject, err = c.GenerateUploadRequest(ctx, furObject, file)
+	if err != nil {
+		logs.AddToFailedLog(furObject.FilePath, furObject.Filename, commonUtils.FileMetadata{}, furObject.GUID, "Error occurred during request generation: "+err.Error(), 0, false, true)
+		logs.IncrementScore(logs.ScoreBoardLen - 1)
+		return errors.New("Error occurred during request generation: " + err.Error())
+	}
+	err = c.uploadFile(ctx, furObject, 0)
+	if err != nil {
+		log.Println(err.Error())
+		logs.IncrementScore(logs.ScoreBoardLen - 1) // update failed score
+	} else {
+		logs.IncrementScore(0) // update succeeded score
+	}
+	return failedFilesError()
+}
+
+// addInterruptedUploads records the files that haven't been uploaded before cancellation in the failed log,
+// so that they're uploaded by a retry
+func addInterruptedUploads(filePaths []string, isMultipart bool, options UploadOptions) {
+	failedLogMap := logs.GetFailedLogMap()
+	for _, filePath := range filePaths {
+		if _, found := failedLogMap[filePath]; found || logs.ExistsInSucceededLog(filePath) {
+			continue
+		}
+		fileInfo, err := ProcessFilename(options.UploadPath, filePath, options.IncludeSubDirName, options.Metadata && !isMultipart)
+		if err != nil {
+			fileInfo = FileInfo{FilePath: filePath, Filename: filepath.Base(filePath)}
+		}
+		logs.AddToFailedLog(filePath, fileInfo.Filename, fileInfo.FileMetadata, "", "Interrupted before the upload has started", 0, isMultipart, true)
+	}
+}
+
+func (c *Client) processSingleUploads(ctx context.Context, singleFilePaths []string, options UploadOptions) {
+	for _, filePath := range singleFilePaths {
+		if ctx.Err() != nil {
+			return
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			logs.AddToFailedLog(filePath, filepath.Base(filePath), commonUtils.FileMetadata{}, "", "File open error: "+err.Error(), 0, false, true)
+			log.Println("File open error: " + err.Error())
+			continue
+		}
+
+		c.startSingleFileUpload(ctx, filePath, file, options)
+		file.Close()
+	}
+}
+
+func (c *Client) startSingleFileUpload(ctx context.Context, filePath string, file *os.File, options UploadOptions) {
+	_, err := file.Stat()
+	if err != nil {
+		logs.AddToFailedLog(filePath, filepath.Base(filePath), commonUtils.FileMetadata{}, "", "File stat error: "+err.Error(), 0, false, true)
+		log.Println("File stat error for file " + filePath + ", file may be missing or unreadable because of permissions.")
+		return
+	}
+
+	fileInfo, err := ProcessFilename(options.UploadPath, filePath, options.IncludeSubDirName, options.Metadata)
+	if err != nil {
+		logs.AddToFailedLog(filePath, filepath.Base(filePath), commonUtils.FileMetadata{}, "", "Process filename error for file: "+err.Error(), 0, false, true)
+		log.Println("Process filename error for file: " + err.Error())
+		return
+	}
+
+	respURL, guid, err := c.GeneratePresignedURL(ctx, fileInfo.Filename, fileInfo.FileMetadata, options.Bucket)
+	if err != nil {
+		logs.AddToFailedLog(fileInfo.FilePath, fileInfo.Filename, fileInfo.FileMetadata, guid, err.Error(), 0, false, true)
+		log.Println(err.Error())
+		return
+	}
+
+	// update failed log with new guid
+	logs.AddToFailedLog(fileInfo.FilePath, fileInfo.Filename, fileInfo.FileMetadata, guid, "", 0, false, true)
+
+	furObject := commonUtils.FileUploadRequestObject{FilePath: fileInfo.FilePath, Filename: fileInfo.Filename, GUID: guid, PresignedURL: respURL}
+	furObject, err = c.GenerateUploadRequest(ctx, furObject, file)
+	if err != nil {
+		file.Close()
+		log.Printf("Error occurred during request generation: %s\n", err.Error())
+		return
+	}
+
+	err = c.uploadFile(ctx, furObject, 0)
+	if err != nil {
+		log.Println(err.Error())
+	} else {
+		logs.IncrementScore(0)
+	}
+	file.Close()
+}
+
+func (c *Client) processMultipartUpload(ctx context.Context, multipartFilePaths []string, options UploadOptions) error {
+	// NOTE(@mpingram) - For the moment Shepherd doesn't support multipart uploads.
+	// Throw an error if Shepherd is enabled and user attempts to multipart upload.
+	if c.Credential.UseShepherd == "true" ||
+		c.Credential.UseShepherd == "" && commonUtils.DefaultUseShepherd == true {
+		return &jwt.ConfigError{Err: fmt.Errorf("Error: Shepherd currently does not support multipart uploads. For the moment, please disable Shepherd with\n	$ gen3-client configure --profile=%v --use-shepherd=false\nand try again.", c.Credential.Profile)}
+	}
+	log.Println("Multipart uploading....")
+
+	for _, filePath := range multipartFilePaths {
+		if ctx.Err() != nil {
+			break
+		}
+		fileInfo, err := ProcessFilename(options.UploadPath, filePath, options.IncludeSubDirName, false)
+		if err != nil {
+			logs.AddToFailedLog(filePath, filepath.Base(filePath), commonUtils.FileMetadata{}, "", "Process filename error for file: "+err.Error(), 0, false, true)
+			log.Println("Process filename error for file: " + err.Error())
+			continue
+		}
+		err = c.multipartUpload(ctx, fileInfo, 0, options.Bucket)
+		if err != nil {
+			log.Println(err.Error())
+		} else {
+			logs.IncrementScore(0)
+		}
+	}
+	return nil
+}
+
+func updateRetryObject(ro *commonUtils.RetryObject, filePath string, filename string, fileMetadata commonUtils.FileMetadata, guid string, retryCount int, isMultipart bool) {
+	ro.FilePath = filePath
+	ro.Filename = filename
+	ro.FileMetadata = fileMetadata
+	ro.GUID = guid
+	ro.RetryCount = retryCount
+	ro.Multipart = isMultipart
+}
+
+func (c *Client) handleFailedRetry(ctx context.Context, ro commonUtils.RetryObject, retryObjCh chan commonUtils.RetryObject, err error, isMuted bool) {
+	lastError := ""
+	if err != nil {
+		lastError = err.Error()
+		log.Println(err.Error())
+	}
+	logs.AddToFailedLog(ro.FilePath, ro.Filename, ro.FileMetadata, ro.GUID, lastError, ro.RetryCount, ro.Multipart, isMuted)
+	if ro.RetryCount < MaxRetryCount { // try another time
+		retryObjCh <- ro
+	} else {
+		// keep the record of a resumable multipart upload, so that it can be resumed on the next run
+		if ro.GUID != "" && !logs.ExistsInMultipartState(ro.FilePath) {
+			msg, err := c.DeleteRecord(ctx, ro.GUID)
+			if err == nil {
+				log.Println(msg)
+			} else {
+				log.Println(err.Error())
+			}
+		}
+		logs.IncrementScore(logs.ScoreBoardLen - 1) // inevitable failure
+		if (len(retryObjCh)) == 0 {
+			close(retryObjCh)
+			log.Println("Retry channel has been closed")
+		}
+	}
+}
+
+// RetryUpload uploads the files of a failed log again, sequentially and with exponential backoff.
+// It returns a PartialFailureError if some of the files have failed again.
+func (c *Client) RetryUpload(ctx context.Context, failedLogMap map[string]commonUtils.RetryObject) error {
+	var guid string
+	var presignedURL string
+	var err error
+
+	fmt.Fprintln(logs.MessageOutput())
+	if len(failedLogMap) == 0 {
+		log.Println("No failed file in log, no need to retry upload.")
+		return nil
+	}
+
+	log.Println("Retry upload has started...")
+	retryObjCh := make(chan commonUtils.RetryObject, len(failedLogMap))
+	for _, v := range failedLogMap {
+		if logs.ExistsInSucceededLog(v.FilePath) {
+			log.Println("File \"" + v.FilePath + "\" has been found in local submission history and has been skipped to prevent duplicated submissions.")
+			continue
+		}
+		retryObjCh <- v
+	}
+	log.Printf("%d records has been sent to the retry channel\n\n", len(retryObjCh))
+	if len(retryObjCh) == 0 {
+		return nil
+	}
+
+	for ro := range retryObjCh {
+		if err := ctx.Err(); err != nil { // cancelled, the remaining files stay in the failed log
+			return err
+		}
+		ro.RetryCount++
+		log.Printf("#%d retry of record %s\n", ro.RetryCount, ro.FilePath)
+		log.Printf("Sleep for %.0f seconds\n", GetWaitTime(ro.RetryCount).Seconds())
+		if err := waitForRetry(ctx, ro.RetryCount); err != nil { // exponential wait for retry
+			return err
+		}
+
+		// a multipart upload that can be resumed keeps its GUID, the uploaded parts will be skipped
+		if ro.GUID != "" && !(ro.Multipart && logs.ExistsInMultipartState(ro.FilePath)) {
+			msg, err := c.DeleteRecord(ctx, ro.GUID)
+			if err == nil {
+				log.Println(msg)
+			} else {
+				log.Println(err.Error())
+			}
+		}
+
+		if ro.Filename == "" {
+			filePath, _ := commonUtils.GetAbsolutePath(ro.FilePath)
+			filename := filepath.Base(filePath)
+			updateRetryObject(&ro, filePath, filename, ro.FileMetadata, ro.GUID, ro.RetryCount, true)
+		}
+
+		if ro.Multipart {
+			fileInfo := FileInfo{FilePath: ro.FilePath, Filename: ro.Filename}
+			err = c.multipartUpload(ctx, fileInfo, ro.RetryCount, ro.Bucket)
+			if err != nil {
+				updateRetryObject(&ro, ro.FilePath, ro.Filename, ro.FileMetadata, ro.GUID, ro.RetryCount, true)
+				c.handleFailedRetry(ctx, ro, retryObjCh, err, true)
+				continue
+			} else { // succeeded
+				logs.IncrementScore(ro.RetryCount)
+				if (len(retryObjCh)) == 0 {
+					close(retryObjCh)
+					log.Println("Retry channel has been closed")
+				}
+			}
+		} else {
+			presignedURL, guid, err = c.GeneratePresignedURL(ctx, ro.Filename, ro.FileMetadata, ro.Bucket)
+			if err != nil {
+				updateRetryObject(&ro, ro.FilePath, ro.Filename, ro.FileMetadata, guid, ro.RetryCount, false)
+				c.handleFailedRetry(ctx, ro, retryObjCh, err, true)
+				continue
+			}
+			furObject := commonUtils.FileUploadRequestObject{FilePath: ro.FilePath, Filename: ro.Filename, FileMetadata: ro.FileMetadata, GUID: guid, PresignedURL: presignedURL}
+			file, err := os.Open(ro.FilePath)
+			if err != nil {
+				updateRetryObject(&ro, furObject.FilePath, furObject.Filename, furObject.FileMetadata, ro.GUID, ro.RetryCount, false)
+				c.handleFailedRetry(ctx, ro, retryObjCh, err, false)
+				continue
+			}
+			fi, err := file.Stat()
+			if err != nil {
+				updateRetryObject(&ro, furObject.FilePath, furObject.Filename, furObject.FileMetadata, ro.GUID, ro.RetryCount, false)
+				c.handleFailedRetry(ctx, ro, retryObjCh, err, false)
+				file.Close()
+				continue
+			}
+			if fi.Size() > FileSizeLimit { // guard for files, always check file size during retry upload
+				updateRetryObject(&ro, furObject.FilePath, furObject.Filename, furObject.FileMetadata, guid, ro.RetryCount, true)
+				err = fmt.Errorf("File size for %s is greater than the single part upload limit, will retry using multipart upload", furObject.Filename)
+				c.handleFailedRetry(ctx, ro, retryObjCh, err, false)
+				file.Close()
+				continue
+			}
+
+			furObject, err = c.GenerateUploadRequest(ctx, furObject, file)
+			if err != nil {
+				updateRetryObject(&ro, furObject.FilePath, furObject.Filename, furObject.FileMetadata, furObject.GUID, ro.RetryCount, false)
+				c.handleFailedRetry(ctx, ro, retryObjCh, err, false)
+				file.Close()
+				continue
+			}
+
+			err = c.uploadFile(ctx, furObject, ro.RetryCount)
+			if err != nil {
+				updateRetryObject(&ro, furObject.FilePath, furObject.Filename, furObject.FileMetadata, furObject.GUID, ro.RetryCount, false)
+				c.handleFailedRetry(ctx, ro, retryObjCh, err, false)
+				file.Close()
+				continue
+			}
+			logs.DeleteFromFailedLog(furObject.FilePath, true)
+			logs.IncrementScore(ro.RetryCount)
+			file.Close()
+			if (len(retryObjCh)) == 0 {
+				close(retryObjCh)
+				log.Println("Retry channel has been closed")
+			}
+		}
+	}
+	return failedFilesError()
+}
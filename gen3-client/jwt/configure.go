@@ -13,12 +13,21 @@ import (
 	"path"
 	"regexp"
 	"strings"
+	"sync"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
 	"gopkg.in/ini.v1"
 )
 
+// Environment variables that can provide the credentials of a profile instead of the config file
+const (
+	EnvAPIKey      = "GEN3_API_KEY"
+	EnvKeyID       = "GEN3_KEY_ID"
+	EnvAccessToken = "GEN3_ACCESS_TOKEN"
+	EnvEndpoint    = "GEN3_ENDPOINT"
+)
+
 type Credential struct {
 	Profile            string
 	KeyId              string
@@ -27,6 +36,12 @@ type Credential struct {
 	APIEndpoint        string
 	UseShepherd        string
 	MinShepherdVersion string
+	// SecretStore is the secret store that holds the API key and access token, see GetSecretStore
+	SecretStore string
+	// InMemory is set for credentials given through the environment or stdin, which are never written to the config file
+	InMemory bool
+	// HTTP holds the proxy, TLS and connection settings of the profile
+	HTTP HTTPConfig
 }
 
 type Configure struct{}
@@ -35,11 +50,24 @@ type ConfigureInterface interface {
 	ReadFile(string, string) string
 	ValidateUrl(string) (*url.URL, error)
 	GetConfigPath() (string, error)
-	UpdateConfigFile(Credential)
+	UpdateConfigFile(Credential) error
+	UpdateAccessToken(Credential) error
 	ParseKeyValue(str string, expr string) (string, error)
-	ParseConfig(profile string) Credential
+	ParseConfig(profile string) (Credential, error)
+	GetSecretStore(name string) (SecretStore, error)
+	MigrateSecrets(profile string, storeName string) error
+	ListProfiles() ([]string, error)
+	ReadProfile(profile string) (Credential, error)
+	RemoveProfile(profile string) error
+	CopyProfile(profile string, newProfile string) error
+	RenameProfile(profile string, newProfile string) error
+	GetDefaultProfile() (string, error)
+	SetDefaultProfile(profile string) error
 }
 
+// Keys of the profile section that are kept in the secret store of the profile
+var secretKeys = []string{"api_key", "access_token"}
+
 func (conf *Configure) ReadFile(filePath string, fileType string) string {
 	//Look in config file
 	fullFilePath, err := commonUtils.GetAbsolutePath(filePath)
@@ -77,16 +105,15 @@ func (conf *Configure) ValidateUrl(apiEndpoint string) (*url.URL, error) {
 	return parsedURL, nil
 }
 
-func (conf *Configure) ReadCredentials(filePath string) Credential {
-	var profileConfig Credential
-	jsonContent := conf.ReadFile(filePath, "json")
-	jsonContent = strings.Replace(jsonContent, "key_id", "KeyId", -1)
-	jsonContent = strings.Replace(jsonContent, "api_key", "APIKey", -1)
-	err := json.Unmarshal([]byte(jsonContent), &profileConfig)
+func (conf *Configure) ReadCredentials(filePath string) (Credential, error) {
+	if filePath == "-" {
+		return ReadCredentialsFromStdin()
+	}
+	profileConfig, err := parseCredentials([]byte(conf.ReadFile(filePath, "json")))
 	if err != nil {
-		log.Fatalln("Cannot read json file: " + err.Error())
+		return profileConfig, errors.New("Cannot read json file: " + err.Error())
 	}
-	return profileConfig
+	return profileConfig, nil
 }
 
 func (conf *Configure) GetConfigPath() (string, error) {
@@ -108,19 +135,15 @@ func (conf *Configure) InitConfigFile() error {
 	}
 
 	if _, err := os.Stat(path.Dir(configPath)); os.IsNotExist(err) {
-		osErr := os.Mkdir(path.Join(path.Dir(configPath)), os.FileMode(0777))
+		osErr := os.Mkdir(path.Join(path.Dir(configPath)), os.FileMode(0700))
 		if osErr != nil {
-			return err
-		}
-		_, osErr = os.Create(configPath)
-		if osErr != nil {
-			return err
+			return osErr
 		}
 	}
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		_, osErr := os.Create(configPath)
+		osErr := ioutil.WriteFile(configPath, []byte{}, 0600)
 		if osErr != nil {
-			return err
+			return osErr
 		}
 	}
 	_, err = ini.Load(configPath)
@@ -128,7 +151,7 @@ func (conf *Configure) InitConfigFile() error {
 	return err
 }
 
-func (conf *Configure) UpdateConfigFile(profileConfig Credential) {
+func (conf *Configure) UpdateConfigFile(profileConfig Credential) error {
 	/*
 		Overwrite the config file with new credential
 
@@ -136,24 +159,357 @@ func (conf *Configure) UpdateConfigFile(profileConfig Credential) {
 			profileConfig: Credential object represents config of a profile
 			configPath: file path to config file
 	*/
+	if profileConfig.InMemory {
+		// credentials given through the environment or stdin are never persisted
+		return nil
+	}
+	err := conf.writeProfile(profileConfig)
+	if err != nil {
+		return &ConfigError{err}
+	}
+	return nil
+}
+
+// UpdateAccessToken saves the access token of a profile, leaving the rest of the profile as it is. The token isn't
+// saved if the secret store of the profile cannot be written without prompting for its passphrase, it's then only
+// good for the current run.
+func (conf *Configure) UpdateAccessToken(profileConfig Credential) error {
+	if profileConfig.InMemory {
+		return nil
+	}
+	configWriteLock.Lock()
+	defer configWriteLock.Unlock()
+	configPath, err := conf.GetConfigPath()
+	if err != nil {
+		return &ConfigError{errors.New("error occurred when getting config path: " + err.Error())}
+	}
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		return &ConfigError{errors.New("error occurred when loading config file: " + err.Error())}
+	}
+	sec, err := cfg.GetSection(profileConfig.Profile)
+	if err != nil {
+		return &ConfigError{errors.New("Profile '" + profileConfig.Profile + "' not found in config file")}
+	}
+	storeName := sec.Key("secret_store").String()
+	store, err := conf.GetSecretStore(storeName)
+	if err != nil {
+		return &ConfigError{err}
+	}
+	value := profileConfig.AccessToken
+	if store != nil {
+		if fileStore, ok := store.(*encryptedFileStore); ok && !fileStore.hasPassphrase() {
+			return nil
+		}
+		if err := store.Set(profileConfig.Profile, "access_token", profileConfig.AccessToken); err != nil {
+			return &ConfigError{errors.New("error occurred when saving access_token into the " + storeName + " secret store: " + err.Error())}
+		}
+		value = secretReference(storeName)
+	}
+	if sec.Key("access_token").String() == value {
+		return nil
+	}
+	sec.Key("access_token").SetValue(value)
+	if err := saveConfigFile(cfg, configPath); err != nil {
+		return &ConfigError{errors.New("error occurred when saving config file: " + err.Error())}
+	}
+	return nil
+}
+
+var configWriteLock sync.Mutex
+
+// writeProfile writes a profile into the config file, and its secrets into the secret store of the profile.
+// If the credential doesn't name a secret store, the one already configured for the profile is kept.
+func (conf *Configure) writeProfile(profileConfig Credential) error {
+	configWriteLock.Lock()
+	defer configWriteLock.Unlock()
+	configPath, err := conf.GetConfigPath()
+	if err != nil {
+		return errors.New("error occurred when getting config path: " + err.Error())
+	}
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		return errors.New("error occurred when loading config file: " + err.Error())
+	}
+	sec := cfg.Section(profileConfig.Profile)
+	if profileConfig.SecretStore == "" {
+		profileConfig.SecretStore = sec.Key("secret_store").String()
+	}
+	store, err := conf.GetSecretStore(profileConfig.SecretStore)
+	if err != nil {
+		return err
+	}
+	secrets := map[string]string{"api_key": profileConfig.APIKey, "access_token": profileConfig.AccessToken}
+	for _, key := range secretKeys {
+		value := secrets[key]
+		if store != nil {
+			if value == "" {
+				err = store.Delete(profileConfig.Profile, key)
+			} else {
+				err = store.Set(profileConfig.Profile, key, value)
+				value = secretReference(profileConfig.SecretStore)
+			}
+			if err != nil {
+				return errors.New("error occurred when saving " + key + " into the " + profileConfig.SecretStore + " secret store: " + err.Error())
+			}
+		}
+		sec.Key(key).SetValue(value)
+	}
+	sec.Key("key_id").SetValue(profileConfig.KeyId)
+	sec.Key("api_endpoint").SetValue(profileConfig.APIEndpoint)
+	sec.Key("use_shepherd").SetValue(profileConfig.UseShepherd)
+	sec.Key("min_shepherd_version").SetValue(profileConfig.MinShepherdVersion)
+	if profileConfig.SecretStore == "" {
+		sec.DeleteKey("secret_store")
+	} else {
+		sec.Key("secret_store").SetValue(profileConfig.SecretStore)
+	}
+	writeHTTPConfig(sec, profileConfig.HTTP)
+	err = saveConfigFile(cfg, configPath)
+	if err != nil {
+		return errors.New("error occurred when saving config file: " + err.Error())
+	}
+	return nil
+}
+
+// saveConfigFile writes to a temporary file first so readers never see a partially written config file
+func saveConfigFile(cfg *ini.File, configPath string) error {
+	tempPath := configPath + ".tmp"
+	err := cfg.SaveTo(tempPath)
+	if err != nil {
+		return err
+	}
+	// the config file may have been created by an older version of the client, readable by everyone
+	err = os.Chmod(tempPath, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempPath, configPath)
+}
+
+// resolveSecrets replaces the secret references of a profile read from the config file by the secrets themselves
+func (conf *Configure) resolveSecrets(profileConfig *Credential) error {
+	secrets := map[string]*string{"api_key": &profileConfig.APIKey, "access_token": &profileConfig.AccessToken}
+	for _, key := range secretKeys {
+		storeName, isReference := parseSecretReference(*secrets[key])
+		if !isReference {
+			continue
+		}
+		store, err := conf.GetSecretStore(storeName)
+		if err != nil {
+			return err
+		}
+		if store == nil {
+			return errors.New(key + " of profile " + profileConfig.Profile + " refers to the " + storeName + " secret store, which cannot hold secrets")
+		}
+		value, err := store.Get(profileConfig.Profile, key)
+		if err != nil {
+			return errors.New("Error occurred when reading " + key + " of profile " + profileConfig.Profile + " from the " + storeName + " secret store: " + err.Error())
+		}
+		*secrets[key] = value
+	}
+	return nil
+}
+
+// ListProfiles returns the names of the profiles in the config file
+func (conf *Configure) ListProfiles() ([]string, error) {
+	configPath, err := conf.GetConfigPath()
+	if err != nil {
+		return nil, errors.New("Error occurred when getting config path: " + err.Error())
+	}
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		return nil, errors.New("Error occurred when reading config file: " + err.Error())
+	}
+	profiles := make([]string, 0)
+	for _, name := range cfg.SectionStrings() {
+		if name != ini.DefaultSection {
+			profiles = append(profiles, name)
+		}
+	}
+	return profiles, nil
+}
+
+// GetDefaultProfile returns the profile used when none is given, which is kept outside of any profile section
+// of the config file. An empty string is returned if no default profile has been set.
+func (conf *Configure) GetDefaultProfile() (string, error) {
+	configPath, err := conf.GetConfigPath()
+	if err != nil {
+		return "", errors.New("Error occurred when getting config path: " + err.Error())
+	}
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		return "", nil
+	}
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		return "", errors.New("Error occurred when reading config file: " + err.Error())
+	}
+	return cfg.Section(ini.DefaultSection).Key("default_profile").String(), nil
+}
+
+// SetDefaultProfile sets the profile used when none is given, or unsets it if profile is empty
+func (conf *Configure) SetDefaultProfile(profile string) error {
+	configWriteLock.Lock()
+	defer configWriteLock.Unlock()
+	configPath, err := conf.GetConfigPath()
+	if err != nil {
+		return errors.New("Error occurred when getting config path: " + err.Error())
+	}
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		return errors.New("Error occurred when reading config file: " + err.Error())
+	}
+	if profile == "" {
+		cfg.Section(ini.DefaultSection).DeleteKey("default_profile")
+	} else {
+		if !cfg.HasSection(profile) {
+			return errors.New("Profile " + profile + " not in config file")
+		}
+		cfg.Section(ini.DefaultSection).Key("default_profile").SetValue(profile)
+	}
+	err = saveConfigFile(cfg, configPath)
+	if err != nil {
+		return errors.New("Error occurred when saving config file: " + err.Error())
+	}
+	return nil
+}
+
+// ReadProfile reads a profile and its secrets from the config file, without the overrides from the environment
+// and without validating it
+func (conf *Configure) ReadProfile(profile string) (Credential, error) {
+	profileConfig := Credential{Profile: profile}
+	configPath, err := conf.GetConfigPath()
+	if err != nil {
+		return profileConfig, errors.New("Error occurred when getting config path: " + err.Error())
+	}
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		return profileConfig, errors.New("Error occurred when reading config file: " + err.Error())
+	}
+	if !cfg.HasSection(profile) {
+		return profileConfig, errors.New("Profile " + profile + " not in config file")
+	}
+	sec := cfg.Section(profile)
+	profileConfig.KeyId = sec.Key("key_id").String()
+	profileConfig.APIKey = sec.Key("api_key").String()
+	profileConfig.AccessToken = sec.Key("access_token").String()
+	profileConfig.APIEndpoint = sec.Key("api_endpoint").String()
+	profileConfig.UseShepherd = sec.Key("use_shepherd").String()
+	profileConfig.MinShepherdVersion = sec.Key("min_shepherd_version").String()
+	profileConfig.SecretStore = sec.Key("secret_store").String()
+	profileConfig.HTTP, err = readHTTPConfig(sec)
+	if err != nil {
+		return profileConfig, err
+	}
+	err = conf.resolveSecrets(&profileConfig)
+	return profileConfig, err
+}
+
+// RemoveProfile removes a profile from the config file, and its secrets from its secret store
+func (conf *Configure) RemoveProfile(profile string) error {
+	configWriteLock.Lock()
+	defer configWriteLock.Unlock()
 	configPath, err := conf.GetConfigPath()
 	if err != nil {
-		log.Fatalln("error occurred when getting config path: " + err.Error())
+		return errors.New("Error occurred when getting config path: " + err.Error())
 	}
 	cfg, err := ini.Load(configPath)
 	if err != nil {
-		log.Fatalln("error occurred when loading config file: " + err.Error())
+		return errors.New("Error occurred when reading config file: " + err.Error())
+	}
+	if !cfg.HasSection(profile) {
+		return errors.New("Profile " + profile + " not in config file")
+	}
+	storeName := cfg.Section(profile).Key("secret_store").String()
+	store, err := conf.GetSecretStore(storeName)
+	if err != nil {
+		return err
+	}
+	if store != nil {
+		for _, key := range secretKeys {
+			err = store.Delete(profile, key)
+			if err != nil {
+				return errors.New("Error occurred when removing " + key + " from the " + storeName + " secret store: " + err.Error())
+			}
+		}
+	}
+	cfg.DeleteSection(profile)
+	if cfg.Section(ini.DefaultSection).Key("default_profile").String() == profile {
+		cfg.Section(ini.DefaultSection).DeleteKey("default_profile")
+	}
+	err = saveConfigFile(cfg, configPath)
+	if err != nil {
+		return errors.New("Error occurred when saving config file: " + err.Error())
+	}
+	return nil
+}
+
+// CopyProfile copies a profile, and its secrets, to a new profile in the same secret store
+func (conf *Configure) CopyProfile(profile string, newProfile string) error {
+	profiles, err := conf.ListProfiles()
+	if err != nil {
+		return err
+	}
+	for _, name := range profiles {
+		if name == newProfile {
+			return errors.New("Profile " + newProfile + " already exists")
+		}
 	}
-	cfg.Section(profileConfig.Profile).Key("key_id").SetValue(profileConfig.KeyId)
-	cfg.Section(profileConfig.Profile).Key("api_key").SetValue(profileConfig.APIKey)
-	cfg.Section(profileConfig.Profile).Key("access_token").SetValue(profileConfig.AccessToken)
-	cfg.Section(profileConfig.Profile).Key("api_endpoint").SetValue(profileConfig.APIEndpoint)
-	cfg.Section(profileConfig.Profile).Key("use_shepherd").SetValue(profileConfig.UseShepherd)
-	cfg.Section(profileConfig.Profile).Key("min_shepherd_version").SetValue(profileConfig.MinShepherdVersion)
-	err = cfg.SaveTo(configPath)
+	profileConfig, err := conf.ReadProfile(profile)
 	if err != nil {
-		log.Println("error occurred when saving config file: " + err.Error())
+		return err
 	}
+	profileConfig.Profile = newProfile
+	return conf.writeProfile(profileConfig)
+}
+
+// RenameProfile renames a profile, moving its secrets along with it
+func (conf *Configure) RenameProfile(profile string, newProfile string) error {
+	err := conf.CopyProfile(profile, newProfile)
+	if err != nil {
+		return err
+	}
+	defaultProfile, err := conf.GetDefaultProfile()
+	if err != nil {
+		return err
+	}
+	err = conf.RemoveProfile(profile)
+	if err != nil || defaultProfile != profile {
+		return err
+	}
+	return conf.SetDefaultProfile(newProfile)
+}
+
+// MigrateSecrets moves the API key and access token of a profile into another secret store. Only a reference
+// to the secret store is left in the config file, unless the secrets are moved to the plaintext store.
+func (conf *Configure) MigrateSecrets(profile string, storeName string) error {
+	if _, err := conf.GetSecretStore(storeName); err != nil {
+		return err
+	}
+	profileConfig, err := conf.ReadProfile(profile)
+	if err != nil {
+		return err
+	}
+	oldStoreName := profileConfig.SecretStore
+	profileConfig.SecretStore = storeName
+	err = conf.writeProfile(profileConfig)
+	if err != nil {
+		return err
+	}
+	if oldStoreName == storeName {
+		return nil
+	}
+	oldStore, err := conf.GetSecretStore(oldStoreName)
+	if err != nil || oldStore == nil {
+		return err
+	}
+	for _, key := range secretKeys {
+		err = oldStore.Delete(profile, key)
+		if err != nil {
+			return errors.New("Secrets have been migrated, but an error occurred when removing " + key + " from the " + oldStoreName + " secret store: " + err.Error())
+		}
+	}
+	return nil
 }
 
 func (conf *Configure) ParseKeyValue(str string, expr string) (string, error) {
@@ -168,7 +524,7 @@ func (conf *Configure) ParseKeyValue(str string, expr string) (string, error) {
 	return match[1], nil
 }
 
-func (conf *Configure) ParseConfig(profile string) Credential {
+func (conf *Configure) ParseConfig(profile string) (Credential, error) {
 	/*
 		Looking profile in config file. The config file is a text file located at ~/.gen3 directory. It can
 		contain more than 1 profile. If there is no profile found, the user is asked to run a command to
@@ -192,17 +548,30 @@ func (conf *Configure) ParseConfig(profile string) Credential {
 		use_shepherd=false
 		min_shepherd_version=
 
+		[profile3]
+		key_id=key_id_example_3
+		api_key=gen3-secret:keyring
+		access_token=gen3-secret:keyring
+		api_endpoint=http://localhost:8000
+		secret_store=keyring
+
+		The api_key and access_token of a profile with a secret_store are kept in that secret store (see
+		GetSecretStore), and only a reference to it is left in the config file.
+
+		A profile may also have the proxy, ca_cert_file, client_cert_file, client_key_file, connect_timeout,
+		response_header_timeout, idle_conn_timeout and max_idle_conns_per_host network settings, see HTTPConfig.
+
+		Any of the values can be overridden with the GEN3_API_KEY, GEN3_KEY_ID, GEN3_ACCESS_TOKEN and
+		GEN3_ENDPOINT environment variables, in which case the profile doesn't need to exist in the config file.
+		A credential with any value overridden through the environment (or stdin, with GEN3_API_KEY=-) is only
+		kept in memory.
+
 		Args:
 			profile: the specific profile in config file
 		Returns:
 			An instance of Credential
 	*/
 
-	homeDir, err := homedir.Dir()
-	if err != nil {
-		log.Fatalln("Error occurred when getting home directory: " + err.Error())
-	}
-	configPath := path.Join(homeDir + commonUtils.PathSeparator + ".gen3" + commonUtils.PathSeparator + "gen3_client_config.ini")
 	profileConfig := Credential{
 		Profile:     profile,
 		KeyId:       "",
@@ -210,42 +579,142 @@ func (conf *Configure) ParseConfig(profile string) Credential {
 		AccessToken: "",
 		APIEndpoint: "",
 	}
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		log.Println("No config file found in ~/.gen3/")
-		fmt.Println("Run configure command (with a profile if desired) to set up account credentials \n" +
-			"Example: ./gen3-client configure --profile=<profile-name> --cred=<path-to-credential/cred.json> --apiendpoint=https://data.mycommons.org")
-		return profileConfig
-	}
-
-	// If profile not in config file, prompt user to set up config first
-	cfg, err := ini.Load(configPath)
+	envConfig, hasEnvCredentials, err := credentialFromEnvironment()
 	if err != nil {
-		log.Fatalln("Error occurred when reading config file: " + err.Error())
+		return profileConfig, &ConfigError{err}
 	}
-	sec, err := cfg.GetSection(profile)
 
+	configPath, err := conf.GetConfigPath()
 	if err != nil {
-		log.Fatalln("Profile not in config file. Need to run \"gen3-client configure --profile=" + profile + " --cred=<path-to-credential/cred.json> --apiendpoint=<api_endpoint_url>\" first")
+		return profileConfig, &ConfigError{errors.New("Error occurred when getting home directory: " + err.Error())}
+	}
+	sec := ini.Empty().Section(profile)
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		if !hasEnvCredentials {
+			fmt.Fprintln(os.Stderr, "Run configure command (with a profile if desired) to set up account credentials \n"+
+				"Example: ./gen3-client configure --profile=<profile-name> --cred=<path-to-credential/cred.json> --apiendpoint=https://data.mycommons.org")
+			return profileConfig, &ConfigError{errors.New("No config file found in ~/.gen3/")}
+		}
+	} else {
+		cfg, err := ini.Load(configPath)
+		if err != nil {
+			return profileConfig, &ConfigError{errors.New("Error occurred when reading config file: " + err.Error())}
+		}
+		if cfg.HasSection(profile) {
+			sec = cfg.Section(profile)
+		} else if !hasEnvCredentials {
+			return profileConfig, &ConfigError{errors.New("Profile not in config file. Need to run \"gen3-client configure --profile=" + profile + " --cred=<path-to-credential/cred.json> --apiendpoint=<api_endpoint_url>\" first")}
+		}
 	}
+
 	// Read in API key, key ID and endpoint for given profile
 	profileConfig.KeyId = sec.Key("key_id").String()
-	if profileConfig.KeyId == "" {
-		log.Fatalln("key_id not found in profile.")
-	}
 	profileConfig.APIKey = sec.Key("api_key").String()
-	if profileConfig.APIKey == "" {
-		log.Fatalln("api_key not found in profile.")
-	}
 	profileConfig.AccessToken = sec.Key("access_token").String()
-	if profileConfig.AccessToken == "" {
-		log.Fatalln("access_token not found in profile.")
-	}
 	profileConfig.APIEndpoint = sec.Key("api_endpoint").String()
-	if profileConfig.APIEndpoint == "" {
-		log.Fatalln("api_endpoint not found in profile.")
-	}
 	// UseShepherd and MinShepherdVersion are optional
 	profileConfig.UseShepherd = sec.Key("use_shepherd").String()
 	profileConfig.MinShepherdVersion = sec.Key("min_shepherd_version").String()
-	return profileConfig
+	profileConfig.SecretStore = sec.Key("secret_store").String()
+	profileConfig.HTTP, err = readHTTPConfig(sec)
+	if err != nil {
+		return profileConfig, &ConfigError{err}
+	}
+
+	if hasEnvCredentials {
+		if envConfig.APIKey != "" {
+			profileConfig.KeyId = envConfig.KeyId
+			profileConfig.APIKey = envConfig.APIKey
+			// the access token in the config file belongs to another API key
+			profileConfig.AccessToken = ""
+		}
+		if envConfig.AccessToken != "" {
+			profileConfig.AccessToken = envConfig.AccessToken
+		}
+		profileConfig.InMemory = true
+	}
+	if envConfig.APIEndpoint != "" {
+		profileConfig.APIEndpoint = envConfig.APIEndpoint
+		// the profile must not be saved with an endpoint it doesn't have in the config file
+		profileConfig.InMemory = true
+	}
+	err = conf.resolveSecrets(&profileConfig)
+	if err != nil {
+		return profileConfig, &ConfigError{err}
+	}
+
+	if profileConfig.APIKey == "" && profileConfig.AccessToken == "" {
+		return profileConfig, &ConfigError{errors.New("Neither api_key nor access_token found in profile.")}
+	}
+	if profileConfig.APIKey != "" && profileConfig.KeyId == "" {
+		return profileConfig, &ConfigError{errors.New("key_id not found in profile.")}
+	}
+	if profileConfig.APIEndpoint == "" {
+		return profileConfig, &ConfigError{errors.New("api_endpoint not found in profile.")}
+	}
+	if profileConfig.APIKey != "" {
+		err = CheckAPIKeyExpiry(&profileConfig)
+	}
+	return profileConfig, err
+}
+
+// credentialFromEnvironment reads the credentials given through the GEN3_* environment variables, or through stdin
+// if GEN3_API_KEY is "-". It returns whether an API key or an access token has been given.
+func credentialFromEnvironment() (Credential, bool, error) {
+	profileConfig := Credential{
+		KeyId:       strings.TrimSpace(os.Getenv(EnvKeyID)),
+		APIKey:      strings.TrimSpace(os.Getenv(EnvAPIKey)),
+		AccessToken: strings.TrimSpace(os.Getenv(EnvAccessToken)),
+		APIEndpoint: strings.TrimSuffix(strings.TrimSpace(os.Getenv(EnvEndpoint)), "/"),
+	}
+	if profileConfig.APIKey == "-" {
+		stdinConfig, err := ReadCredentialsFromStdin()
+		if err != nil {
+			return profileConfig, false, err
+		}
+		profileConfig.KeyId = stdinConfig.KeyId
+		profileConfig.APIKey = stdinConfig.APIKey
+	} else if strings.HasPrefix(profileConfig.APIKey, "{") {
+		// the whole credential JSON file downloaded from the portal
+		jsonConfig, err := parseCredentials([]byte(profileConfig.APIKey))
+		if err != nil {
+			return profileConfig, false, errors.New("Cannot read credentials from " + EnvAPIKey + ": " + err.Error())
+		}
+		profileConfig.KeyId = jsonConfig.KeyId
+		profileConfig.APIKey = jsonConfig.APIKey
+	}
+	if profileConfig.APIKey != "" && profileConfig.KeyId == "" {
+		return profileConfig, false, errors.New(EnvKeyID + " must be set along with " + EnvAPIKey)
+	}
+	return profileConfig, profileConfig.APIKey != "" || profileConfig.AccessToken != "", nil
+}
+
+var stdinCredential Credential
+var stdinCredentialErr error
+var stdinCredentialOnce sync.Once
+
+// ReadCredentialsFromStdin reads a credential JSON file, as downloaded from the portal, from stdin.
+// Stdin is only read once, later calls return the same credentials.
+func ReadCredentialsFromStdin() (Credential, error) {
+	stdinCredentialOnce.Do(func() {
+		content, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			stdinCredentialErr = errors.New("Error occurred when reading credentials from stdin: " + err.Error())
+			return
+		}
+		stdinCredential, err = parseCredentials(content)
+		if err != nil {
+			stdinCredentialErr = errors.New("Cannot read credentials from stdin: " + err.Error())
+		}
+	})
+	return stdinCredential, stdinCredentialErr
+}
+
+func parseCredentials(content []byte) (Credential, error) {
+	var profileConfig Credential
+	jsonContent := strings.Replace(string(content), "\n", "", -1)
+	jsonContent = strings.Replace(jsonContent, "key_id", "KeyId", -1)
+	jsonContent = strings.Replace(jsonContent, "api_key", "APIKey", -1)
+	err := json.Unmarshal([]byte(jsonContent), &profileConfig)
+	return profileConfig, err
 }
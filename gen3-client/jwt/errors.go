@@ -0,0 +1,27 @@
+package jwt
+
+// AuthError is returned when the data commons rejects the credential of a profile, or when the API key has expired
+type AuthError struct {
+	Err error
+}
+
+func (e *AuthError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigError is returned when a profile is missing from the config file, or cannot be read or written
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
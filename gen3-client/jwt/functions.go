@@ -5,6 +5,7 @@ package jwt
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,6 +15,8 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/go-version"
 	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
@@ -22,28 +25,34 @@ import (
 type Functions struct {
 	Request RequestInterface
 	Config  ConfigureInterface
+	// Tokens keeps the access tokens in memory, a new TokenManager is used if it isn't set
+	Tokens *TokenManager
+
+	tokensOnce sync.Once
 }
 
 type FunctionInterface interface {
-	CheckPrivileges(profileConfig *Credential) (string, map[string]interface{}, error)
-	CheckForShepherdAPI(profileConfig *Credential) (bool, error)
-	GetResponse(profileConfig *Credential, endpointPostPrefix string, method string, contentType string, bodyBytes []byte) (string, *http.Response, error)
-	DoRequestWithSignedHeader(profileConfig *Credential, endpointPostPrefix string, contentType string, bodyBytes []byte) (JsonMessage, error)
+	CheckPrivileges(ctx context.Context, profileConfig *Credential) (string, map[string]interface{}, error)
+	CheckForShepherdAPI(ctx context.Context, profileConfig *Credential) (bool, error)
+	GetResponse(ctx context.Context, profileConfig *Credential, endpointPostPrefix string, method string, contentType string, bodyBytes []byte) (string, *http.Response, error)
+	DoRequestWithSignedHeader(ctx context.Context, profileConfig *Credential, endpointPostPrefix string, contentType string, bodyBytes []byte) (JsonMessage, error)
 	ParseFenceURLResponse(resp *http.Response) (JsonMessage, error)
 	GetHost(profileConfig *Credential) (*url.URL, error)
 }
 
 type Request struct {
+	// Transport makes the HTTP requests, http.DefaultTransport is used if it isn't set. See HTTPConfig.Transport.
+	Transport http.RoundTripper
 }
 
 type RequestInterface interface {
-	MakeARequest(method string, apiEndpoint string, accessToken string, contentType string, headers map[string]string, body *bytes.Buffer, noTimeout bool) (*http.Response, error)
-	RequestNewAccessToken(accessTokenEndpoint string, profileConfig *Credential) error
+	MakeARequest(ctx context.Context, method string, apiEndpoint string, accessToken string, contentType string, headers map[string]string, body *bytes.Buffer, noTimeout bool) (*http.Response, error)
+	RequestNewAccessToken(ctx context.Context, accessTokenEndpoint string, profileConfig *Credential) error
 }
 
-func (r *Request) MakeARequest(method string, apiEndpoint string, accessToken string, contentType string, headers map[string]string, body *bytes.Buffer, noTimeout bool) (*http.Response, error) {
+func (r *Request) MakeARequest(ctx context.Context, method string, apiEndpoint string, accessToken string, contentType string, headers map[string]string, body *bytes.Buffer, noTimeout bool) (*http.Response, error) {
 	/*
-		Make http request with header and body
+		Make http request with header and body, the request is aborted once ctx is done
 	*/
 	if headers == nil {
 		headers = make(map[string]string)
@@ -54,18 +63,16 @@ func (r *Request) MakeARequest(method string, apiEndpoint string, accessToken st
 	if contentType != "" {
 		headers["Content-Type"] = contentType
 	}
-	var client *http.Client
-	if noTimeout {
-		client = &http.Client{}
-	} else {
-		client = &http.Client{Timeout: commonUtils.DefaultTimeout}
+	client := &http.Client{Transport: r.Transport}
+	if !noTimeout {
+		client.Timeout = commonUtils.DefaultTimeout
 	}
 	var req *http.Request
 	var err error
 	if body == nil {
-		req, err = http.NewRequest(method, apiEndpoint, nil)
+		req, err = http.NewRequestWithContext(ctx, method, apiEndpoint, nil)
 	} else {
-		req, err = http.NewRequest(method, apiEndpoint, body)
+		req, err = http.NewRequestWithContext(ctx, method, apiEndpoint, body)
 	}
 
 	if err != nil {
@@ -81,7 +88,7 @@ func (r *Request) MakeARequest(method string, apiEndpoint string, accessToken st
 	return resp, nil
 }
 
-func (r *Request) RequestNewAccessToken(accessTokenEndpoint string, profileConfig *Credential) error {
+func (r *Request) RequestNewAccessToken(ctx context.Context, accessTokenEndpoint string, profileConfig *Credential) error {
 	/*
 		Request new access token to replace the expired one.
 
@@ -92,17 +99,25 @@ func (r *Request) RequestNewAccessToken(accessTokenEndpoint string, profileConfi
 			err: error
 
 	*/
-	body := bytes.NewBufferString("{\"api_key\": \"" + profileConfig.APIKey + "\"}")
-	resp, err := r.MakeARequest("POST", accessTokenEndpoint, "", "application/json", nil, body, false)
-	var m AccessTokenStruct
-	// parse resp error codes first for profile configuration verification
-	if resp != nil && resp.StatusCode != 200 {
-		return errors.New("Error occurred in RequestNewAccessToken with error code " + strconv.Itoa(resp.StatusCode) + ", check FENCE log for more details.")
+	if expiry := GetAPIKeyExpiry(profileConfig.APIKey); !expiry.IsZero() && time.Now().After(expiry) {
+		return CheckAPIKeyExpiry(profileConfig)
 	}
+	body := bytes.NewBufferString("{\"api_key\": \"" + profileConfig.APIKey + "\"}")
+	resp, err := r.MakeARequest(ctx, "POST", accessTokenEndpoint, "", "application/json", nil, body, false)
 	if err != nil {
 		return errors.New("Error occurred in RequestNewAccessToken: " + err.Error())
 	}
 	defer resp.Body.Close()
+	var m AccessTokenStruct
+	// parse resp error codes first for profile configuration verification
+	if resp.StatusCode != 200 {
+		err = errors.New("Error occurred in RequestNewAccessToken with error code " + strconv.Itoa(resp.StatusCode) + ", check FENCE log for more details.")
+		if resp.StatusCode == 400 || resp.StatusCode == 401 || resp.StatusCode == 403 {
+			// Fence rejects API keys that are invalid or have been revoked
+			return &AuthError{err}
+		}
+		return err
+	}
 
 	str := ResponseToString(resp)
 	err = DecodeJsonFromString(str, &m)
@@ -127,7 +142,7 @@ func (f *Functions) ParseFenceURLResponse(resp *http.Response) (JsonMessage, err
 	if !(resp.StatusCode == 200 || resp.StatusCode == 201) {
 		switch resp.StatusCode {
 		case 401:
-			return msg, errors.New("401 Unauthorized error has occurred! Something went wrong during authentication, please check your configuration and/or credentials")
+			return msg, &AuthError{errors.New("401 Unauthorized error has occurred! Something went wrong during authentication, please check your configuration and/or credentials")}
 		case 403:
 			return msg, errors.New("403 Forbidden error has occurred! You don't have permission to access the requested url \"" + resp.Request.URL.String() + "\"")
 		case 404:
@@ -153,7 +168,7 @@ func (f *Functions) ParseFenceURLResponse(resp *http.Response) (JsonMessage, err
 	return msg, nil
 }
 
-func (f *Functions) CheckForShepherdAPI(profileConfig *Credential) (bool, error) {
+func (f *Functions) CheckForShepherdAPI(ctx context.Context, profileConfig *Credential) (bool, error) {
 	// Check if Shepherd is enabled
 	if profileConfig.UseShepherd == "false" {
 		return false, nil
@@ -170,7 +185,7 @@ func (f *Functions) CheckForShepherdAPI(profileConfig *Credential) (bool, error)
 		minShepherdVersion = profileConfig.MinShepherdVersion
 	}
 
-	_, res, err := f.GetResponse(profileConfig, commonUtils.ShepherdVersionEndpoint, "GET", "", nil)
+	_, res, err := f.GetResponse(ctx, profileConfig, commonUtils.ShepherdVersionEndpoint, "GET", "", nil)
 	if err != nil {
 		return false, errors.New("Error occurred during generating HTTP request: " + err.Error())
 	}
@@ -201,7 +216,7 @@ func (f *Functions) CheckForShepherdAPI(profileConfig *Credential) (bool, error)
 	return false, fmt.Errorf("Shepherd is enabled, but %v does not have correct Shepherd version. (Need Shepherd version >=%v, got %v)", profileConfig.APIEndpoint, minVer, ver)
 }
 
-func (f *Functions) GetResponse(profileConfig *Credential, endpointPostPrefix string, method string, contentType string, bodyBytes []byte) (string, *http.Response, error) {
+func (f *Functions) GetResponse(ctx context.Context, profileConfig *Credential, endpointPostPrefix string, method string, contentType string, bodyBytes []byte) (string, *http.Response, error) {
 
 	var resp *http.Response
 	var err error
@@ -212,29 +227,24 @@ func (f *Functions) GetResponse(profileConfig *Credential, endpointPostPrefix st
 	host, _ := url.Parse(profileConfig.APIEndpoint)
 	prefixEndPoint := host.Scheme + "://" + host.Host
 	apiEndpoint := host.Scheme + "://" + host.Host + endpointPostPrefix
-	isExpiredToken := false
-	if profileConfig.AccessToken != "" {
-		resp, err = f.Request.MakeARequest(method, apiEndpoint, profileConfig.AccessToken, contentType, nil, bytes.NewBuffer(bodyBytes), false)
-		if err != nil {
-			return "", resp, fmt.Errorf("Error while requesting user access token at %v: %v", apiEndpoint, err)
-		}
-
-		// 401 code is general error code from FENCE. the error message is also not clear for the case
-		// that the token expired. Temporary solution: get new access token and make another attempt.
-		if resp != nil && resp.StatusCode == 401 {
-			isExpiredToken = true
-		} else {
-			return prefixEndPoint, resp, err
-		}
+	accessToken, err := f.tokenManager().GetAccessToken(ctx, f, profileConfig, prefixEndPoint, "")
+	if err != nil {
+		return prefixEndPoint, resp, err
 	}
-	if profileConfig.AccessToken == "" || isExpiredToken {
-		err := f.Request.RequestNewAccessToken(prefixEndPoint+commonUtils.FenceAccessTokenEndpoint, profileConfig)
+	resp, err = f.Request.MakeARequest(ctx, method, apiEndpoint, accessToken, contentType, nil, bytes.NewBuffer(bodyBytes), false)
+	if err != nil {
+		return prefixEndPoint, resp, fmt.Errorf("Error while requesting user access token at %v: %v", apiEndpoint, err)
+	}
+
+	// 401 code is general error code from FENCE. the error message is also not clear for the case
+	// that the token has been revoked or expired early. Get a new access token and make another attempt.
+	if resp != nil && resp.StatusCode == 401 && profileConfig.APIKey != "" {
+		resp.Body.Close()
+		accessToken, err = f.tokenManager().GetAccessToken(ctx, f, profileConfig, prefixEndPoint, accessToken)
 		if err != nil {
 			return prefixEndPoint, resp, err
 		}
-		f.Config.UpdateConfigFile(*profileConfig)
-
-		resp, err = f.Request.MakeARequest(method, apiEndpoint, profileConfig.AccessToken, contentType, nil, bytes.NewBuffer(bodyBytes), false)
+		resp, err = f.Request.MakeARequest(ctx, method, apiEndpoint, accessToken, contentType, nil, bytes.NewBuffer(bodyBytes), false)
 		if err != nil {
 			return prefixEndPoint, resp, err
 		}
@@ -243,6 +253,15 @@ func (f *Functions) GetResponse(profileConfig *Credential, endpointPostPrefix st
 	return prefixEndPoint, resp, nil
 }
 
+func (f *Functions) tokenManager() *TokenManager {
+	f.tokensOnce.Do(func() {
+		if f.Tokens == nil {
+			f.Tokens = NewTokenManager()
+		}
+	})
+	return f.Tokens
+}
+
 func (f *Functions) GetHost(profileConfig *Credential) (*url.URL, error) {
 	if profileConfig.APIEndpoint == "" {
 		return nil, errors.New("No APIEndpoint found in the configuration file! Please use \"./gen3-client configure\" to configure your credentials first")
@@ -251,7 +270,7 @@ func (f *Functions) GetHost(profileConfig *Credential) (*url.URL, error) {
 	return host, nil
 }
 
-func (f *Functions) DoRequestWithSignedHeader(profileConfig *Credential, endpointPostPrefix string, contentType string, bodyBytes []byte) (JsonMessage, error) {
+func (f *Functions) DoRequestWithSignedHeader(ctx context.Context, profileConfig *Credential, endpointPostPrefix string, contentType string, bodyBytes []byte) (JsonMessage, error) {
 	/*
 	   Do request with signed header. User may have more than one profile and use a profile to make a request
 	*/
@@ -263,7 +282,7 @@ func (f *Functions) DoRequestWithSignedHeader(profileConfig *Credential, endpoin
 		method = "POST"
 	}
 
-	_, resp, err := f.GetResponse(profileConfig, endpointPostPrefix, method, contentType, bodyBytes)
+	_, resp, err := f.GetResponse(ctx, profileConfig, endpointPostPrefix, method, contentType, bodyBytes)
 	if err != nil {
 		return msg, err
 	}
@@ -273,18 +292,21 @@ func (f *Functions) DoRequestWithSignedHeader(profileConfig *Credential, endpoin
 	return msg, err
 }
 
-func (f *Functions) CheckPrivileges(profileConfig *Credential) (string, map[string]interface{}, error) {
+func (f *Functions) CheckPrivileges(ctx context.Context, profileConfig *Credential) (string, map[string]interface{}, error) {
 	/*
 	   Return user privileges from specified profile
 	*/
 	var err error
 	var data map[string]interface{}
 
-	host, resp, err := f.GetResponse(profileConfig, commonUtils.FenceUserEndpoint, "GET", "", nil)
+	host, resp, err := f.GetResponse(ctx, profileConfig, commonUtils.FenceUserEndpoint, "GET", "", nil)
 	if err != nil {
-		return "", nil, errors.New("Error occurred when getting response from remote: " + err.Error())
+		return "", nil, fmt.Errorf("Error occurred when getting response from remote: %w", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == 401 || resp.StatusCode == 403 {
+		return "", nil, &AuthError{errors.New("Error occurred when getting user privileges: the data commons has rejected the credential with status code " + strconv.Itoa(resp.StatusCode))}
+	}
 
 	str := ResponseToString(resp)
 	err = json.Unmarshal([]byte(str), &data)
@@ -305,41 +327,44 @@ func (f *Functions) CheckPrivileges(profileConfig *Credential) (string, map[stri
 	return host, resourceAccess, err
 }
 
-func (f *Functions) DeleteRecord(profileConfig *Credential, guid string) (string, error) {
-	var err error
-	var msg string
-
-	hasShepherd, err := f.CheckForShepherdAPI(profileConfig)
+func (f *Functions) DeleteRecord(ctx context.Context, profileConfig *Credential, guid string) (string, error) {
+	hasShepherd, err := f.CheckForShepherdAPI(ctx, profileConfig)
 	if err != nil {
 		log.Printf("WARNING: Error while checking for Shepherd API: %v. Falling back to Fence to delete record.\n", err)
 	} else if hasShepherd {
 		endPointPostfix := commonUtils.ShepherdEndpoint + "/objects/" + guid
-		_, resp, err := f.GetResponse(profileConfig, endPointPostfix, "DELETE", "", nil)
+		_, resp, err := f.GetResponse(ctx, profileConfig, endPointPostfix, "DELETE", "", nil)
 		if err != nil {
 			return "", err
 		}
 		defer resp.Body.Close()
-		if resp.StatusCode == 204 {
-			msg = "Record with GUID " + guid + " has been deleted"
-		} else if resp.StatusCode == 500 {
-			err = errors.New("Internal server error occurred when deleting " + guid + "; could not delete stored files, or not able to delete INDEXD record")
-		}
-		return msg, err
+		return parseDeleteResponse(resp, guid)
 	}
 
 	endPointPostfix := commonUtils.FenceDataEndpoint + "/" + guid
 
-	_, resp, err := f.GetResponse(profileConfig, endPointPostfix, "DELETE", "", nil)
+	_, resp, err := f.GetResponse(ctx, profileConfig, endPointPostfix, "DELETE", "", nil)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == 204 {
-		msg = "Record with GUID " + guid + " has been deleted"
-	} else if resp.StatusCode == 500 {
-		err = errors.New("Internal server error occurred when deleting " + guid + "; could not delete stored files, or not able to delete INDEXD record")
-	}
+	return parseDeleteResponse(resp, guid)
+}
 
-	return msg, err
+func parseDeleteResponse(resp *http.Response, guid string) (string, error) {
+	switch resp.StatusCode {
+	case 200, 204:
+		return "Record with GUID " + guid + " has been deleted", nil
+	case 401:
+		return "", errors.New("401 Unauthorized error has occurred when deleting " + guid + "! Please check your configuration and/or credentials")
+	case 403:
+		return "", errors.New("403 Forbidden error has occurred! You don't have permission to delete " + guid)
+	case 404:
+		return "", errors.New("404 Not found error has occurred! No record can be found for " + guid)
+	case 500:
+		return "", errors.New("Internal server error occurred when deleting " + guid + "; could not delete stored files, or not able to delete INDEXD record")
+	default:
+		return "", errors.New("Unexpected response with status code " + strconv.Itoa(resp.StatusCode) + " when deleting " + guid)
+	}
 }
@@ -0,0 +1,291 @@
+package jwt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// Secret stores that can hold the API key and access token of a profile
+const (
+	// SecretStorePlaintext keeps the secrets in the config file itself, as older versions of the client did
+	SecretStorePlaintext = "plaintext"
+	// SecretStoreEncryptedFile keeps the secrets in a file encrypted with a passphrase
+	SecretStoreEncryptedFile = "encrypted-file"
+	// SecretStoreKeyring keeps the secrets in the OS keyring: Secret Service over D-Bus on Linux,
+	// Keychain on macOS and Credential Manager on Windows
+	SecretStoreKeyring = "keyring"
+)
+
+// EnvSecretPassphrase provides the passphrase of the encrypted secret file without prompting for it
+const EnvSecretPassphrase = "GEN3_SECRET_PASSPHRASE"
+
+// secretReferencePrefix marks a config file value that is a reference to a secret store instead of the secret itself
+const secretReferencePrefix = "gen3-secret:"
+
+const keyringService = "gen3-client"
+
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretStore stores the secrets of profiles outside of the config file
+type SecretStore interface {
+	Get(profile string, key string) (string, error)
+	Set(profile string, key string, value string) error
+	Delete(profile string, key string) error
+}
+
+func (conf *Configure) GetSecretStore(name string) (SecretStore, error) {
+	switch name {
+	case "", SecretStorePlaintext:
+		return nil, nil
+	case SecretStoreEncryptedFile:
+		configPath, err := conf.GetConfigPath()
+		if err != nil {
+			return nil, err
+		}
+		return getEncryptedFileStore(path.Join(path.Dir(configPath), "gen3_client_secrets.enc")), nil
+	case SecretStoreKeyring:
+		return &keyringStore{}, nil
+	}
+	return nil, fmt.Errorf("Unknown secret store %q, valid secret stores are %q, %q and %q", name, SecretStorePlaintext, SecretStoreEncryptedFile, SecretStoreKeyring)
+}
+
+func secretReference(storeName string) string {
+	return secretReferencePrefix + storeName
+}
+
+// parseSecretReference returns the name of the secret store a config file value refers to, if it is a reference
+func parseSecretReference(value string) (string, bool) {
+	if !strings.HasPrefix(value, secretReferencePrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(value, secretReferencePrefix), true
+}
+
+type keyringStore struct{}
+
+func (s *keyringStore) Get(profile string, key string) (string, error) {
+	value, err := keyring.Get(keyringService, profile+"/"+key)
+	if err == keyring.ErrNotFound {
+		return "", ErrSecretNotFound
+	}
+	return value, err
+}
+
+func (s *keyringStore) Set(profile string, key string, value string) error {
+	return keyring.Set(keyringService, profile+"/"+key, value)
+}
+
+func (s *keyringStore) Delete(profile string, key string) error {
+	err := keyring.Delete(keyringService, profile+"/"+key)
+	if err == keyring.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// encryptedSecretFile is the content of the encrypted secret file. The ciphertext is the JSON encoding of the
+// secrets by profile and key, encrypted with AES-256-GCM under a key derived from the passphrase with scrypt.
+type encryptedSecretFile struct {
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+type encryptedFileStore struct {
+	filePath   string
+	mu         sync.Mutex
+	passphrase []byte
+}
+
+var encryptedFileStores = make(map[string]*encryptedFileStore)
+var encryptedFileStoresLock sync.Mutex
+
+// getEncryptedFileStore returns the same store for a file throughout the process, so the passphrase is only asked for once
+func getEncryptedFileStore(filePath string) *encryptedFileStore {
+	encryptedFileStoresLock.Lock()
+	defer encryptedFileStoresLock.Unlock()
+	store, ok := encryptedFileStores[filePath]
+	if !ok {
+		store = &encryptedFileStore{filePath: filePath}
+		encryptedFileStores[filePath] = store
+	}
+	return store
+}
+
+func (s *encryptedFileStore) getPassphrase() ([]byte, error) {
+	if s.passphrase != nil {
+		return s.passphrase, nil
+	}
+	if passphrase := os.Getenv(EnvSecretPassphrase); passphrase != "" {
+		s.passphrase = []byte(passphrase)
+		return s.passphrase, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, errors.New("No passphrase for the encrypted secret file, set " + EnvSecretPassphrase + " or run the client in a terminal")
+	}
+	fmt.Fprint(os.Stderr, "Passphrase for "+s.filePath+": ")
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, errors.New("Error occurred when reading passphrase: " + err.Error())
+	}
+	if len(passphrase) == 0 {
+		return nil, errors.New("The passphrase of the encrypted secret file cannot be empty")
+	}
+	s.passphrase = passphrase
+	return s.passphrase, nil
+}
+
+// hasPassphrase tells if the store can be read and written without prompting for its passphrase
+func (s *encryptedFileStore) hasPassphrase() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.passphrase != nil || os.Getenv(EnvSecretPassphrase) != ""
+}
+
+func deriveSecretKey(passphrase []byte, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *encryptedFileStore) load() (map[string]map[string]string, error) {
+	secrets := make(map[string]map[string]string)
+	content, err := ioutil.ReadFile(s.filePath)
+	if os.IsNotExist(err) {
+		return secrets, nil
+	}
+	if err != nil {
+		return nil, errors.New("Error occurred when reading encrypted secret file: " + err.Error())
+	}
+	var file encryptedSecretFile
+	err = json.Unmarshal(content, &file)
+	if err != nil {
+		return nil, errors.New("Error occurred when parsing encrypted secret file: " + err.Error())
+	}
+	passphrase, err := s.getPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := deriveSecretKey(passphrase, file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return nil, errors.New("Invalid nonce in encrypted secret file " + s.filePath)
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		// don't ask again for a passphrase that doesn't decrypt the file
+		s.passphrase = nil
+		return nil, errors.New("Cannot decrypt " + s.filePath + ", check that the passphrase is correct")
+	}
+	err = json.Unmarshal(plaintext, &secrets)
+	if err != nil {
+		return nil, errors.New("Error occurred when parsing encrypted secret file: " + err.Error())
+	}
+	return secrets, nil
+}
+
+func (s *encryptedFileStore) save(secrets map[string]map[string]string) error {
+	passphrase, err := s.getPassphrase()
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	file := encryptedSecretFile{Salt: make([]byte, 16)}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+	aead, err := deriveSecretKey(passphrase, file.Salt)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Ciphertext = aead.Seal(nil, file.Nonce, plaintext, nil)
+	content, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	// write to a temporary file first so an interrupted write doesn't lose every stored secret
+	tempPath := s.filePath + ".tmp"
+	err = ioutil.WriteFile(tempPath, content, 0600)
+	if err != nil {
+		return errors.New("Error occurred when writing encrypted secret file: " + err.Error())
+	}
+	err = os.Rename(tempPath, s.filePath)
+	if err != nil {
+		return errors.New("Error occurred when writing encrypted secret file: " + err.Error())
+	}
+	return nil
+}
+
+func (s *encryptedFileStore) Get(profile string, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[profile][key]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+func (s *encryptedFileStore) Set(profile string, key string, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+	if secrets[profile] == nil {
+		secrets[profile] = make(map[string]string)
+	}
+	secrets[profile][key] = value
+	return s.save(secrets)
+}
+
+func (s *encryptedFileStore) Delete(profile string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[profile][key]; !ok {
+		return nil
+	}
+	delete(secrets[profile], key)
+	if len(secrets[profile]) == 0 {
+		delete(secrets, profile)
+	}
+	return s.save(secrets)
+}
@@ -0,0 +1,179 @@
+package jwt
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+)
+
+// TokenRefreshMargin is how long before its expiry an access token gets refreshed
+const TokenRefreshMargin = 5 * time.Minute
+
+// APIKeyExpiryWarningPeriod is how long before its expiry the client starts warning about an API key
+const APIKeyExpiryWarningPeriod = 14 * 24 * time.Hour
+
+// TokenManager keeps the access tokens of profiles in memory and refreshes them shortly before they expire.
+// Only one refresh per profile is in flight at a time, callers asking for a token meanwhile share its result.
+type TokenManager struct {
+	mu     sync.Mutex
+	tokens map[string]*tokenState
+}
+
+type tokenState struct {
+	token     string
+	refreshAt time.Time
+	refresh   *tokenRefresh
+}
+
+type tokenRefresh struct {
+	done  chan struct{}
+	token string
+	err   error
+	// interrupted is set if the refresh has failed because the context of the caller that made it was done
+	interrupted bool
+}
+
+func NewTokenManager() *TokenManager {
+	return &TokenManager{tokens: make(map[string]*tokenState)}
+}
+
+// GetAccessToken returns a valid access token for a profile, refreshing it first if it is about to expire.
+// A staleToken that has been rejected by the server is refreshed even if it hasn't expired yet.
+// The wait for a refresh is given up once ctx is done, and a refresh interrupted by the context of the caller
+// that made it is made again by the callers waiting for it.
+func (m *TokenManager) GetAccessToken(ctx context.Context, f *Functions, profileConfig *Credential, prefixEndPoint string, staleToken string) (string, error) {
+	key := profileConfig.Profile + "\x00" + profileConfig.APIEndpoint
+	m.mu.Lock()
+	state, ok := m.tokens[key]
+	if !ok {
+		state = &tokenState{token: profileConfig.AccessToken, refreshAt: tokenRefreshTime(profileConfig.AccessToken)}
+		m.tokens[key] = state
+	}
+	for state.refresh != nil {
+		refresh := state.refresh
+		m.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-refresh.done:
+		}
+		if !refresh.interrupted {
+			return refresh.token, refresh.err
+		}
+		m.mu.Lock()
+	}
+	if !state.needsRefresh(staleToken) || profileConfig.APIKey == "" {
+		// without an API key the token cannot be refreshed, let the server reject it
+		token := state.token
+		m.mu.Unlock()
+		return token, nil
+	}
+	refresh := &tokenRefresh{done: make(chan struct{})}
+	state.refresh = refresh
+	refreshedConfig := *profileConfig
+	refreshedConfig.AccessToken = state.token
+	m.mu.Unlock()
+
+	refresh.err = f.Request.RequestNewAccessToken(ctx, prefixEndPoint+commonUtils.FenceAccessTokenEndpoint, &refreshedConfig)
+	refresh.token = refreshedConfig.AccessToken
+	refresh.interrupted = refresh.err != nil && ctx.Err() != nil
+
+	m.mu.Lock()
+	state.refresh = nil
+	if refresh.err == nil {
+		state.token = refresh.token
+		state.refreshAt = tokenRefreshTime(refresh.token)
+	}
+	m.mu.Unlock()
+	close(refresh.done)
+
+	if refresh.err == nil {
+		// the refreshed token is still good for this run even if it cannot be saved
+		if err := f.Config.UpdateAccessToken(refreshedConfig); err != nil {
+			log.Println("Error occurred when saving the refreshed access token: " + err.Error())
+		}
+	}
+	return refresh.token, refresh.err
+}
+
+func (s *tokenState) needsRefresh(staleToken string) bool {
+	if s.token == "" || s.token == staleToken {
+		return true
+	}
+	return !s.refreshAt.IsZero() && time.Now().After(s.refreshAt)
+}
+
+// tokenRefreshTime returns when an access token should be refreshed according to its claims, or the zero time
+// if it cannot be decoded. Tokens that live shorter than twice the refresh margin are refreshed halfway through.
+func tokenRefreshTime(token string) time.Time {
+	claims, err := decodeTokenClaims(token)
+	if err != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	margin := TokenRefreshMargin
+	if claims.Iat != 0 && claims.Iat < claims.Exp {
+		if lifetime := time.Duration(claims.Exp-claims.Iat) * time.Second; lifetime < 2*margin {
+			margin = lifetime / 2
+		}
+	}
+	return time.Unix(claims.Exp, 0).Add(-margin)
+}
+
+// GetAPIKeyExpiry returns when an API key expires. API keys are JWTs issued by Fence, the zero time is returned
+// for a key that cannot be decoded.
+func GetAPIKeyExpiry(apiKey string) time.Time {
+	claims, err := decodeTokenClaims(apiKey)
+	if err != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
+
+// CheckAPIKeyExpiry returns an error if the API key of a profile has expired, and warns if it expires soon
+func CheckAPIKeyExpiry(profileConfig *Credential) error {
+	expiry := GetAPIKeyExpiry(profileConfig.APIKey)
+	if expiry.IsZero() {
+		return nil
+	}
+	remaining := time.Until(expiry)
+	if remaining <= 0 {
+		return &AuthError{fmt.Errorf("The API key of profile '%v' expired on %v. Download a new credential file from the data commons and run \"gen3-client configure --profile=%v --cred=<path-to-credential/cred.json> --apiendpoint=%v\"",
+			profileConfig.Profile, expiry.Format(time.RFC3339), profileConfig.Profile, profileConfig.APIEndpoint)}
+	}
+	if remaining < APIKeyExpiryWarningPeriod {
+		log.Printf("WARNING: The API key of profile '%v' expires in %d day(s), on %v. Download a new credential file from the data commons before then.\n",
+			profileConfig.Profile, int(remaining.Hours()/24), expiry.Format(time.RFC3339))
+	}
+	return nil
+}
+
+type tokenClaims struct {
+	Exp int64 `json:"exp"`
+	Iat int64 `json:"iat"`
+}
+
+// decodeTokenClaims reads the claims of a JWT without verifying its signature, which is the server's job
+func decodeTokenClaims(token string) (tokenClaims, error) {
+	var claims tokenClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, errors.New("access token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return claims, errors.New("Error occurred when decoding access token: " + err.Error())
+	}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return claims, errors.New("Error occurred when decoding access token: " + err.Error())
+	}
+	return claims, nil
+}
@@ -0,0 +1,226 @@
+package jwt
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"gopkg.in/ini.v1"
+)
+
+// HTTPConfig holds the network settings of a profile, used for the requests to the data commons as well as for the
+// presigned URL traffic to the object storage
+type HTTPConfig struct {
+	// Proxy is the URL of the proxy to send the requests through. The HTTPS_PROXY, HTTP_PROXY and NO_PROXY
+	// environment variables are used if it's empty.
+	Proxy string
+	// CACertFile is a PEM bundle of certificate authorities to trust, in addition to the ones of the system
+	CACertFile string
+	// ClientCertFile and ClientKeyFile are the PEM certificate and private key presented for mutual TLS
+	ClientCertFile string
+	ClientKeyFile  string
+	// ConnectTimeout limits how long a connection, including its TLS handshake, may take to be established.
+	// commonUtils.DefaultConnectTimeout is used if it's 0.
+	ConnectTimeout time.Duration
+	// ResponseHeaderTimeout limits how long the response headers of a request may take to arrive once the request has
+	// been sent, so that a stalled transfer to the object storage fails instead of hanging.
+	// commonUtils.DefaultResponseHeaderTimeout is used if it's 0.
+	ResponseHeaderTimeout time.Duration
+	// IdleConnTimeout is how long an idle keep-alive connection is kept open.
+	// commonUtils.DefaultIdleConnTimeout is used if it's 0.
+	IdleConnTimeout time.Duration
+	// MaxIdleConnsPerHost is the number of idle keep-alive connections kept open to each host.
+	// commonUtils.DefaultMaxIdleConnsPerHost is used if it's 0.
+	MaxIdleConnsPerHost int
+}
+
+// Keys of the network settings in the profile section of the config file
+const (
+	configKeyProxy                 = "proxy"
+	configKeyCACertFile            = "ca_cert_file"
+	configKeyClientCertFile        = "client_cert_file"
+	configKeyClientKeyFile         = "client_key_file"
+	configKeyConnectTimeout        = "connect_timeout"
+	configKeyResponseHeaderTimeout = "response_header_timeout"
+	configKeyIdleConnTimeout       = "idle_conn_timeout"
+	configKeyMaxIdleConnsPerHost   = "max_idle_conns_per_host"
+)
+
+// Validate checks that the settings can be used to build a transport, without reading the certificate files
+func (config HTTPConfig) Validate() error {
+	if config.Proxy != "" {
+		proxyURL, err := url.Parse(config.Proxy)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return errors.New("Invalid proxy URL \"" + config.Proxy + "\". A valid proxy URL looks like: http://proxy.example.org:3128")
+		}
+	}
+	if (config.ClientCertFile == "") != (config.ClientKeyFile == "") {
+		return errors.New("A client certificate and its private key must be given together")
+	}
+	if config.ConnectTimeout < 0 || config.ResponseHeaderTimeout < 0 || config.IdleConnTimeout < 0 {
+		return errors.New("Timeouts cannot be negative")
+	}
+	if config.MaxIdleConnsPerHost < 0 {
+		return errors.New("The number of idle connections per host cannot be negative")
+	}
+	return nil
+}
+
+var transports = make(map[HTTPConfig]*http.Transport)
+var transportsLock sync.Mutex
+
+// Transport returns the transport shared by every request made with these settings, so that their keep-alive
+// connections are pooled together
+func (config HTTPConfig) Transport() (*http.Transport, error) {
+	transportsLock.Lock()
+	defer transportsLock.Unlock()
+	if transport, ok := transports[config]; ok {
+		return transport, nil
+	}
+	transport, err := config.newTransport()
+	if err != nil {
+		return nil, err
+	}
+	transports[config] = transport
+	return transport, nil
+}
+
+func (config HTTPConfig) newTransport() (*http.Transport, error) {
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+	connectTimeout := config.ConnectTimeout
+	if connectTimeout == 0 {
+		connectTimeout = commonUtils.DefaultConnectTimeout
+	}
+	responseHeaderTimeout := config.ResponseHeaderTimeout
+	if responseHeaderTimeout == 0 {
+		responseHeaderTimeout = commonUtils.DefaultResponseHeaderTimeout
+	}
+	idleConnTimeout := config.IdleConnTimeout
+	if idleConnTimeout == 0 {
+		idleConnTimeout = commonUtils.DefaultIdleConnTimeout
+	}
+	maxIdleConnsPerHost := config.MaxIdleConnsPerHost
+	if maxIdleConnsPerHost == 0 {
+		maxIdleConnsPerHost = commonUtils.DefaultMaxIdleConnsPerHost
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if config.Proxy != "" {
+		proxyURL, _ := url.Parse(config.Proxy)
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.CACertFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := ioutil.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, errors.New("Error occurred when reading CA bundle \"" + config.CACertFile + "\": " + err.Error())
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("No PEM certificate found in CA bundle \"" + config.CACertFile + "\"")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, errors.New("Error occurred when loading client certificate \"" + config.ClientCertFile + "\": " + err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: responseHeaderTimeout,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          0, // no limit across hosts, the presigned URLs may point to many storage hosts
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		IdleConnTimeout:       idleConnTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}, nil
+}
+
+// readHTTPConfig reads the network settings from the profile section of the config file
+func readHTTPConfig(sec *ini.Section) (HTTPConfig, error) {
+	config := HTTPConfig{
+		Proxy:          sec.Key(configKeyProxy).String(),
+		CACertFile:     sec.Key(configKeyCACertFile).String(),
+		ClientCertFile: sec.Key(configKeyClientCertFile).String(),
+		ClientKeyFile:  sec.Key(configKeyClientKeyFile).String(),
+	}
+	var err error
+	if value := sec.Key(configKeyConnectTimeout).String(); value != "" {
+		config.ConnectTimeout, err = time.ParseDuration(value)
+		if err != nil {
+			return config, errors.New("Invalid " + configKeyConnectTimeout + " \"" + value + "\": " + err.Error())
+		}
+	}
+	if value := sec.Key(configKeyResponseHeaderTimeout).String(); value != "" {
+		config.ResponseHeaderTimeout, err = time.ParseDuration(value)
+		if err != nil {
+			return config, errors.New("Invalid " + configKeyResponseHeaderTimeout + " \"" + value + "\": " + err.Error())
+		}
+	}
+	if value := sec.Key(configKeyIdleConnTimeout).String(); value != "" {
+		config.IdleConnTimeout, err = time.ParseDuration(value)
+		if err != nil {
+			return config, errors.New("Invalid " + configKeyIdleConnTimeout + " \"" + value + "\": " + err.Error())
+		}
+	}
+	if value := sec.Key(configKeyMaxIdleConnsPerHost).String(); value != "" {
+		config.MaxIdleConnsPerHost, err = strconv.Atoi(value)
+		if err != nil {
+			return config, errors.New("Invalid " + configKeyMaxIdleConnsPerHost + " \"" + value + "\": " + err.Error())
+		}
+	}
+	return config, config.Validate()
+}
+
+// writeHTTPConfig writes the network settings into the profile section of the config file,
+// leaving out the ones left to their default
+func writeHTTPConfig(sec *ini.Section, config HTTPConfig) {
+	values := map[string]string{
+		configKeyProxy:          config.Proxy,
+		configKeyCACertFile:     config.CACertFile,
+		configKeyClientCertFile: config.ClientCertFile,
+		configKeyClientKeyFile:  config.ClientKeyFile,
+	}
+	if config.ConnectTimeout != 0 {
+		values[configKeyConnectTimeout] = config.ConnectTimeout.String()
+	}
+	if config.ResponseHeaderTimeout != 0 {
+		values[configKeyResponseHeaderTimeout] = config.ResponseHeaderTimeout.String()
+	}
+	if config.IdleConnTimeout != 0 {
+		values[configKeyIdleConnTimeout] = config.IdleConnTimeout.String()
+	}
+	if config.MaxIdleConnsPerHost != 0 {
+		values[configKeyMaxIdleConnsPerHost] = strconv.Itoa(config.MaxIdleConnsPerHost)
+	}
+	for _, key := range []string{configKeyProxy, configKeyCACertFile, configKeyClientCertFile, configKeyClientKeyFile, configKeyConnectTimeout, configKeyResponseHeaderTimeout, configKeyIdleConnTimeout, configKeyMaxIdleConnsPerHost} {
+		if values[key] == "" {
+			sec.DeleteKey(key)
+		} else {
+			sec.Key(key).SetValue(values[key])
+		}
+	}
+}
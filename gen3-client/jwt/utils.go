@@ -15,13 +15,14 @@ type AccessTokenStruct struct {
 }
 
 type JsonMessage struct {
-	URL          string   `json:"url"`
-	GUID         string   `json:"guid"`
-	UploadID     string   `json:"uploadId"`
-	PresignedURL string   `json:"presigned_url"`
-	FileName     string   `json:"file_name"`
-	URLs         []string `json:"urls"`
-	Size         int64    `json:"size"`
+	URL          string            `json:"url"`
+	GUID         string            `json:"guid"`
+	UploadID     string            `json:"uploadId"`
+	PresignedURL string            `json:"presigned_url"`
+	FileName     string            `json:"file_name"`
+	URLs         []string          `json:"urls"`
+	Size         int64             `json:"size"`
+	Hashes       map[string]string `json:"hashes"`
 }
 
 type DoRequest func(*http.Response) *http.Response
@@ -0,0 +1,28 @@
+package logs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+)
+
+// WriteDeleteLog writes the per-GUID results of a delete operation into a new log file and returns its path
+func WriteDeleteLog(profile string, results []commonUtils.DeleteResultObject) (string, error) {
+	deleteLogFilename := MainLogPath + profile + "_delete_log_" + time.Now().Format("20060102150405MST") + ".json"
+	if results == nil {
+		results = []commonUtils.DeleteResultObject{}
+	}
+	jsonData, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	err = ioutil.WriteFile(deleteLogFilename, jsonData, 0766)
+	if err != nil {
+		return "", err
+	}
+	log.Println("Local delete log file \"" + deleteLogFilename + "\" has been written")
+	return deleteLogFilename, nil
+}
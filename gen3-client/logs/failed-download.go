@@ -0,0 +1,159 @@
+package logs
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+)
+
+// failedDownloadLogSaveInterval limits how often the failed download log file is rewritten while files are being downloaded
+const failedDownloadLogSaveInterval = 5 * time.Second
+
+var failedDownloadLogFilename string
+var failedDownloadLogFileMap = make(map[string]commonUtils.DownloadRetryObject)
+
+// failedDownloadLogWritten tells if the failed download log file has been created by this run
+var failedDownloadLogWritten bool
+
+// failedDownloadLogDirty tells if the failed download log has changed since its file has been written
+var failedDownloadLogDirty bool
+var failedDownloadLogLastSaved time.Time
+var failedDownloadLogLock sync.Mutex
+
+// InitFailedDownloadLog prepares the failed download log of this run, the file itself is only created once a download has failed
+func InitFailedDownloadLog(profile string) {
+	failedDownloadLogFilename = MainLogPath + profile + "_failed_download_log_" + time.Now().Format("20060102150405MST") + ".json"
+	failedDownloadLogFileMap = make(map[string]commonUtils.DownloadRetryObject)
+	failedDownloadLogWritten = false
+	failedDownloadLogDirty = false
+	failedDownloadLogLastSaved = time.Time{}
+}
+
+func LoadFailedDownloadLogFile(filePath string) error {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return errors.New("Error occurred when reading from file \"" + filePath + "\": " + err.Error())
+	}
+	log.Println("Failed download log file \"" + filePath + "\" has been opened for read")
+
+	if len(data) > 0 {
+		var tempRetryObjectSlice []commonUtils.DownloadRetryObject
+		err = json.Unmarshal(data, &tempRetryObjectSlice)
+		if err != nil {
+			return errors.New("Error occurred when unmarshaling from JSON objects: " + err.Error())
+		}
+		for _, ro := range tempRetryObjectSlice {
+			failedDownloadLogFileMap[ro.DownloadPath+ro.Filename] = ro
+		}
+	}
+	return nil
+}
+
+func GetFailedDownloadLogMap() map[string]commonUtils.DownloadRetryObject {
+	return failedDownloadLogFileMap
+}
+
+// GetFailedDownloadLogFilename returns the path of the failed download log of this run
+func GetFailedDownloadLogFilename() string {
+	return failedDownloadLogFilename
+}
+
+func AddToFailedDownloadLog(guid string, downloadPath string, filename string, fileSize int64, lastError string, retryCount int, isMuted bool) {
+	failedDownloadLogLock.Lock()
+	defer failedDownloadLogLock.Unlock()
+	filePath := downloadPath + filename
+	failedDownloadLogFileMap[filePath] = commonUtils.DownloadRetryObject{GUID: guid, DownloadPath: downloadPath, Filename: filename, FileSize: fileSize, Error: lastError, RetryCount: retryCount}
+	RecordFileResult(filePath, FileResult{GUID: guid, Filename: filename, FilePath: filePath, Status: StatusFailed, Error: lastError})
+	if !isMuted {
+		log.Printf("Failed download entry added for %s\n", filePath)
+	}
+	saveFailedDownloadLog()
+}
+
+func DeleteFromFailedDownloadLog(downloadPath string, filename string, isMuted bool) {
+	failedDownloadLogLock.Lock()
+	defer failedDownloadLogLock.Unlock()
+	filePath := downloadPath + filename
+	if _, present := failedDownloadLogFileMap[filePath]; !present {
+		return
+	}
+	delete(failedDownloadLogFileMap, filePath)
+	if !isMuted {
+		log.Printf("Failed download entry deleted for %s\n", filePath)
+	}
+	saveFailedDownloadLog()
+}
+
+// saveFailedDownloadLog marks the failed download log as changed, and rewrites its file at most once per failedDownloadLogSaveInterval
+// so that the downloads of a large manifest don't rewrite it for every file. It must be called with the lock held.
+func saveFailedDownloadLog() {
+	failedDownloadLogDirty = true
+	if time.Since(failedDownloadLogLastSaved) < failedDownloadLogSaveInterval {
+		return
+	}
+	if err := writeToFailedDownloadLog(); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// FlushFailedDownloadLog writes the changes of the failed download log that haven't been written into its file yet
+func FlushFailedDownloadLog() {
+	failedDownloadLogLock.Lock()
+	defer failedDownloadLogLock.Unlock()
+	if !failedDownloadLogDirty {
+		return
+	}
+	if err := writeToFailedDownloadLog(); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// writeToFailedDownloadLog saves the failed download log to its file, replacing the file at once so that it's never left half-written.
+// Without InitFailedDownloadLog the failed download log is only kept in memory.
+func writeToFailedDownloadLog() error {
+	if failedDownloadLogFilename == "" {
+		return nil
+	}
+
+	tempSlice := make([]commonUtils.DownloadRetryObject, 0, len(failedDownloadLogFileMap))
+	for _, v := range failedDownloadLogFileMap {
+		tempSlice = append(tempSlice, v)
+	}
+	jsonData, err := json.MarshalIndent(tempSlice, "", "  ")
+	if err != nil {
+		return errors.New("Error occurred when marshaling to JSON objects: " + err.Error())
+	}
+	err = writeFileAtomically(failedDownloadLogFilename, jsonData)
+	if err != nil {
+		return errors.New("Error occurred when writing to file \"" + failedDownloadLogFilename + "\": " + err.Error())
+	}
+	failedDownloadLogDirty = false
+	failedDownloadLogLastSaved = time.Now()
+	if !failedDownloadLogWritten {
+		failedDownloadLogWritten = true
+		log.Println("Local failed download log file \"" + failedDownloadLogFilename + "\" has opened")
+	}
+	return nil
+}
+
+// CloseFailedDownloadLog writes the last changes of the failed download log of this run and closes it, if any download has failed
+func CloseFailedDownloadLog() error {
+	failedDownloadLogLock.Lock()
+	defer failedDownloadLogLock.Unlock()
+	if failedDownloadLogDirty {
+		if err := writeToFailedDownloadLog(); err != nil {
+			return err
+		}
+	}
+	if !failedDownloadLogWritten {
+		return nil
+	}
+	log.Println("Local failed download log file \"" + failedDownloadLogFilename + "\" has closed")
+	failedDownloadLogWritten = false
+	return nil
+}
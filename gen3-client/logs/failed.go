@@ -2,6 +2,7 @@ package logs
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"log"
 	"os"
@@ -12,36 +13,33 @@ import (
 )
 
 var failedLogFilename string
-var failedLogFileMap map[string]commonUtils.RetryObject
-var failedLogFile *os.File
+var failedLogFileMap = make(map[string]commonUtils.RetryObject)
 var failedLogLock sync.Mutex
 var err error
 
-func InitFailedLog(profile string) {
+func InitFailedLog(profile string) error {
 	failedLogFilename = MainLogPath + profile + "_failed_log_" + time.Now().Format("20060102150405MST") + ".json"
 
-	failedLogFile, err = os.OpenFile(failedLogFilename, os.O_RDWR|os.O_CREATE, 0766)
+	failedLogFile, err := os.OpenFile(failedLogFilename, os.O_RDWR|os.O_CREATE, 0766)
 	if err != nil {
-		failedLogFile.Close()
-		log.Fatal("Error occurred when opening file \"" + failedLogFilename + "\": " + err.Error())
+		return errors.New("Error occurred when opening file \"" + failedLogFilename + "\": " + err.Error())
 	}
+	failedLogFile.Close()
 	log.Println("Local failed log file \"" + failedLogFilename + "\" has opened")
 
 	failedLogFileMap = make(map[string]commonUtils.RetryObject)
+	return nil
 }
 
-func LoadFailedLogFile(filePath string) {
+func LoadFailedLogFile(filePath string) error {
 	file, err := os.OpenFile(filePath, os.O_RDONLY, 0766)
 	if err != nil {
-		file.Close()
-		failedLogFile.Close()
-		log.Fatal("Error occurred when opening file \"" + file.Name() + "\": " + err.Error())
+		return errors.New("Error occurred when opening file \"" + filePath + "\": " + err.Error())
 	}
 	fi, err := file.Stat()
 	if err != nil {
 		file.Close()
-		failedLogFile.Close()
-		log.Fatal("Error occurred when opening file \"" + file.Name() + "\": " + err.Error())
+		return errors.New("Error occurred when opening file \"" + file.Name() + "\": " + err.Error())
 	}
 	log.Println("Failed log file \"" + file.Name() + "\" has been opened for read")
 
@@ -50,21 +48,20 @@ func LoadFailedLogFile(filePath string) {
 		data, err := ioutil.ReadAll(file)
 		if err != nil {
 			file.Close()
-			failedLogFile.Close()
-			log.Fatal("Error occurred when reading from file \"" + file.Name() + "\": " + err.Error())
+			return errors.New("Error occurred when reading from file \"" + file.Name() + "\": " + err.Error())
 		}
 
 		err = json.Unmarshal(data, &tempRetryObjectSlice)
 		if err != nil {
 			file.Close()
-			failedLogFile.Close()
-			log.Fatal("Error occurred when unmarshaling from JSON objects: " + err.Error())
+			return errors.New("Error occurred when unmarshaling from JSON objects: " + err.Error())
 		}
 
 		for _, ro := range tempRetryObjectSlice {
 			failedLogFileMap[ro.FilePath] = ro
 		}
 	}
+	return nil
 }
 
 func IsFailedLogMapEmpty() bool {
@@ -75,14 +72,18 @@ func GetFailedLogMap() map[string]commonUtils.RetryObject {
 	return failedLogFileMap
 }
 
-func AddToFailedLog(filePath string, filename string, metadata commonUtils.FileMetadata, guid string, retryCount int, isMultipart bool, isMuted bool) {
+func AddToFailedLog(filePath string, filename string, metadata commonUtils.FileMetadata, guid string, lastError string, retryCount int, isMultipart bool, isMuted bool) {
 	failedLogLock.Lock()
 	defer failedLogLock.Unlock()
-	failedLogFileMap[filePath] = commonUtils.RetryObject{FilePath: filePath, Filename: filename, FileMetadata: metadata, GUID: guid, RetryCount: retryCount, Multipart: isMultipart}
+	failedLogFileMap[filePath] = commonUtils.RetryObject{FilePath: filePath, Filename: filename, FileMetadata: metadata, GUID: guid, Error: lastError, RetryCount: retryCount, Multipart: isMultipart}
+	// a file stays in the failed log until it has been uploaded, so this also marks the start of its upload
+	RecordFileResult(filePath, FileResult{GUID: guid, Filename: filename, FilePath: filePath, Status: StatusFailed, Error: lastError})
 	if !isMuted {
 		log.Printf("Failed file entry added for %s\n", filePath)
 	}
-	writeToFailedLog()
+	if err := writeToFailedLog(); err != nil {
+		log.Println(err.Error())
+	}
 }
 
 func DeleteFromFailedLog(filePath string, isMuted bool) {
@@ -92,10 +93,17 @@ func DeleteFromFailedLog(filePath string, isMuted bool) {
 	if !isMuted {
 		log.Printf("Failed file entry deleted for %s\n", filePath)
 	}
-	writeToFailedLog()
+	if err := writeToFailedLog(); err != nil {
+		log.Println(err.Error())
+	}
 }
 
-func writeToFailedLog() {
+// writeToFailedLog saves the failed log to its file, replacing the file at once so that it's never left half-written.
+// Without InitFailedLog the failed log is only kept in memory.
+func writeToFailedLog() error {
+	if failedLogFilename == "" {
+		return nil
+	}
 	var tempSlice []commonUtils.RetryObject
 	for _, v := range failedLogFileMap {
 		tempSlice = append(tempSlice, v)
@@ -105,23 +113,20 @@ func writeToFailedLog() {
 	}
 	jsonData, err := json.MarshalIndent(tempSlice, "", "  ")
 	if err != nil {
-		failedLogFile.Close()
-		log.Fatal("Error occurred when marshaling to JSON objects: " + err.Error())
+		return errors.New("Error occurred when marshaling to JSON objects: " + err.Error())
 	}
-	err = failedLogFile.Truncate(0)
+	err = writeFileAtomically(failedLogFilename, jsonData)
 	if err != nil {
-		failedLogFile.Close()
-		log.Fatal("Error occurred when truncating file \"" + failedLogFilename + "\": " + err.Error())
-	}
-	_, err = failedLogFile.WriteAt(jsonData, 0)
-	if err != nil {
-		failedLogFile.Close()
-		log.Fatal("Error occurred when writing to file \"" + failedLogFilename + "\": " + err.Error())
+		return errors.New("Error occurred when writing to file \"" + failedLogFilename + "\": " + err.Error())
 	}
+	return nil
 }
 
 func closeFailedLog() error {
+	if failedLogFilename == "" {
+		return nil
+	}
 	SetToMessageLog()
 	log.Println("Local failed log file \"" + failedLogFilename + "\" has closed")
-	return failedLogFile.Close()
+	return nil
 }
@@ -1,8 +1,11 @@
 package logs
 
 import (
+	"errors"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
@@ -10,17 +13,17 @@ import (
 
 var MainLogPath string
 
-func Init() {
+func Init() error {
 	homeDir, err := homedir.Dir()
 	if err != nil {
-		log.Fatalln("Error occurred when getting home directory: " + err.Error())
+		return errors.New("Error occurred when getting home directory: " + err.Error())
 	}
 
 	mainPath := homeDir + commonUtils.PathSeparator + ".gen3" + commonUtils.PathSeparator
 	if _, err := os.Stat(mainPath); os.IsNotExist(err) { // path to ~/.gen3/logs does not exist
 		err = os.Mkdir(mainPath, 0766)
 		if err != nil {
-			log.Fatal("Cannot create folder \"" + mainPath + "\"")
+			return errors.New("Cannot create folder \"" + mainPath + "\"")
 		}
 		log.Println("Created folder \"" + mainPath + "\"")
 	}
@@ -29,10 +32,11 @@ func Init() {
 	if _, err := os.Stat(MainLogPath); os.IsNotExist(err) { // path to ~/.gen3/logs does not exist
 		err = os.Mkdir(MainLogPath, 0766)
 		if err != nil {
-			log.Fatal("Cannot create folder \"" + MainLogPath + "\"")
+			return errors.New("Cannot create folder \"" + MainLogPath + "\"")
 		}
 		log.Println("Created folder \"" + MainLogPath + "\"")
 	}
+	return nil
 }
 
 func CloseAll() {
@@ -47,3 +51,23 @@ func CloseAll() {
 		}
 	}
 }
+
+// writeFileAtomically writes data to a temporary file first and then renames it, so the file is never left half-written
+func writeFileAtomically(filename string, data []byte) error {
+	tempFile, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tempFile.Write(data)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return err
+	}
+	return os.Rename(tempFile.Name(), filename)
+}
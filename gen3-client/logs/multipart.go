@@ -0,0 +1,156 @@
+package logs
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+)
+
+// multipartStateSaveInterval limits how often the state file is rewritten while parts are being uploaded
+const multipartStateSaveInterval = 5 * time.Second
+
+var multipartStateFilename string
+var multipartStateMap = make(map[string]commonUtils.MultipartStateObject)
+var multipartStateLock sync.Mutex
+var multipartStateLastSaved time.Time
+
+func InitMultipartStateLog(profile string) error {
+	multipartStateLock.Lock()
+	defer multipartStateLock.Unlock()
+	multipartStateFilename = MainLogPath + profile + "_multipart_state.json"
+	multipartStateMap = make(map[string]commonUtils.MultipartStateObject)
+
+	data, err := ioutil.ReadFile(multipartStateFilename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return errors.New("Error occurred when reading from file \"" + multipartStateFilename + "\": " + err.Error())
+		}
+		return nil
+	}
+	if len(data) > 0 {
+		err = json.Unmarshal(data, &multipartStateMap)
+		if err != nil {
+			return errors.New("Error occurred when unmarshaling from JSON objects: " + err.Error())
+		}
+	}
+	if len(multipartStateMap) > 0 {
+		log.Printf("Local multipart state file \"%s\" has been loaded with %d unfinished upload(s)\n", multipartStateFilename, len(multipartStateMap))
+	}
+	return nil
+}
+
+// GetMultipartState returns the recorded progress of the multipart upload of a file, if there is one
+func GetMultipartState(filePath string) (commonUtils.MultipartStateObject, bool) {
+	multipartStateLock.Lock()
+	defer multipartStateLock.Unlock()
+	state, present := multipartStateMap[filePath]
+	if !present {
+		return state, false
+	}
+	eTags := make(map[int]string, len(state.ETags))
+	for partNumber, eTag := range state.ETags {
+		eTags[partNumber] = eTag
+	}
+	state.ETags = eTags
+	return state, true
+}
+
+// GetMultipartStates returns the recorded progress of all the unfinished multipart uploads
+func GetMultipartStates() []commonUtils.MultipartStateObject {
+	multipartStateLock.Lock()
+	defer multipartStateLock.Unlock()
+	states := make([]commonUtils.MultipartStateObject, 0, len(multipartStateMap))
+	for _, state := range multipartStateMap {
+		eTags := make(map[int]string, len(state.ETags))
+		for partNumber, eTag := range state.ETags {
+			eTags[partNumber] = eTag
+		}
+		state.ETags = eTags
+		states = append(states, state)
+	}
+	return states
+}
+
+// ExistsInMultipartState checks whether the multipart upload of a file can be resumed
+func ExistsInMultipartState(filePath string) bool {
+	multipartStateLock.Lock()
+	defer multipartStateLock.Unlock()
+	_, present := multipartStateMap[filePath]
+	return present
+}
+
+// SaveMultipartState records a new or resumed multipart upload
+func SaveMultipartState(state commonUtils.MultipartStateObject) {
+	multipartStateLock.Lock()
+	defer multipartStateLock.Unlock()
+	if state.ETags == nil {
+		state.ETags = make(map[int]string)
+	}
+	multipartStateMap[state.FilePath] = state
+	if err := writeToMultipartStateLog(); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// AddPartToMultipartState records a finished part, the state file is rewritten at most once per multipartStateSaveInterval
+func AddPartToMultipartState(filePath string, partNumber int, eTag string) {
+	multipartStateLock.Lock()
+	defer multipartStateLock.Unlock()
+	state, present := multipartStateMap[filePath]
+	if !present {
+		return
+	}
+	state.ETags[partNumber] = eTag
+	if time.Since(multipartStateLastSaved) >= multipartStateSaveInterval {
+		if err := writeToMultipartStateLog(); err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
+
+// FlushMultipartState writes all recorded parts into the state file
+func FlushMultipartState() {
+	multipartStateLock.Lock()
+	defer multipartStateLock.Unlock()
+	if multipartStateMap != nil {
+		if err := writeToMultipartStateLog(); err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
+
+// DeleteFromMultipartState removes a finished or abandoned multipart upload
+func DeleteFromMultipartState(filePath string) {
+	multipartStateLock.Lock()
+	defer multipartStateLock.Unlock()
+	if _, present := multipartStateMap[filePath]; !present {
+		return
+	}
+	delete(multipartStateMap, filePath)
+	if err := writeToMultipartStateLog(); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// writeToMultipartStateLog saves the multipart upload state to its file. Without InitMultipartStateLog the state is only kept in memory.
+func writeToMultipartStateLog() error {
+	if multipartStateFilename == "" {
+		return nil
+	}
+	jsonData, err := json.MarshalIndent(multipartStateMap, "", "  ")
+	if err != nil {
+		return errors.New("Error occurred when marshaling to JSON objects: " + err.Error())
+	}
+	err = writeFileAtomically(multipartStateFilename, jsonData)
+	if err != nil {
+		return errors.New("Error occurred when writing to file \"" + multipartStateFilename + "\": " + err.Error())
+	}
+	multipartStateLastSaved = time.Now()
+	return nil
+}
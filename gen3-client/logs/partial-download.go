@@ -0,0 +1,126 @@
+package logs
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+)
+
+// partialDownloadStateSaveInterval limits how often the state file is rewritten while segments are being downloaded
+const partialDownloadStateSaveInterval = 5 * time.Second
+
+var partialDownloadStateFilename string
+var partialDownloadStateMap = make(map[string]commonUtils.PartialDownloadStateObject)
+var partialDownloadStateLock sync.Mutex
+var partialDownloadStateLastSaved time.Time
+
+// InitPartialDownloadStateLog loads the progress of the segmented downloads of a profile that have been left unfinished
+func InitPartialDownloadStateLog(profile string) error {
+	partialDownloadStateLock.Lock()
+	defer partialDownloadStateLock.Unlock()
+	partialDownloadStateFilename = MainLogPath + profile + "_partial_download_state.json"
+	partialDownloadStateMap = make(map[string]commonUtils.PartialDownloadStateObject)
+
+	data, err := ioutil.ReadFile(partialDownloadStateFilename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return errors.New("Error occurred when reading from file \"" + partialDownloadStateFilename + "\": " + err.Error())
+		}
+		return nil
+	}
+	if len(data) > 0 {
+		err = json.Unmarshal(data, &partialDownloadStateMap)
+		if err != nil {
+			return errors.New("Error occurred when unmarshaling from JSON objects: " + err.Error())
+		}
+	}
+	if len(partialDownloadStateMap) > 0 {
+		log.Printf("Local partial download state file \"%s\" has been loaded with %d unfinished download(s)\n", partialDownloadStateFilename, len(partialDownloadStateMap))
+	}
+	return nil
+}
+
+// GetPartialDownloadState returns the recorded progress of the segmented download of a file, if there is one
+func GetPartialDownloadState(filePath string) (commonUtils.PartialDownloadStateObject, bool) {
+	partialDownloadStateLock.Lock()
+	defer partialDownloadStateLock.Unlock()
+	state, present := partialDownloadStateMap[filePath]
+	if !present {
+		return state, false
+	}
+	state.Segments = append([]commonUtils.SegmentStateObject(nil), state.Segments...)
+	return state, true
+}
+
+// SavePartialDownloadState records a new or resumed segmented download
+func SavePartialDownloadState(state commonUtils.PartialDownloadStateObject) {
+	partialDownloadStateLock.Lock()
+	defer partialDownloadStateLock.Unlock()
+	state.Segments = append([]commonUtils.SegmentStateObject(nil), state.Segments...)
+	partialDownloadStateMap[state.FilePath] = state
+	if err := writeToPartialDownloadStateLog(); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// UpdatePartialDownloadSegment records the number of bytes of a segment that have been written to the partial file,
+// the state file is rewritten at most once per partialDownloadStateSaveInterval
+func UpdatePartialDownloadSegment(filePath string, segment int, written int64) {
+	partialDownloadStateLock.Lock()
+	defer partialDownloadStateLock.Unlock()
+	state, present := partialDownloadStateMap[filePath]
+	if !present || segment >= len(state.Segments) {
+		return
+	}
+	state.Segments[segment].Written = written
+	if time.Since(partialDownloadStateLastSaved) >= partialDownloadStateSaveInterval {
+		if err := writeToPartialDownloadStateLog(); err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
+
+// FlushPartialDownloadState writes the recorded progress of all segmented downloads into the state file
+func FlushPartialDownloadState() {
+	partialDownloadStateLock.Lock()
+	defer partialDownloadStateLock.Unlock()
+	if err := writeToPartialDownloadStateLog(); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// DeleteFromPartialDownloadState removes a completed or abandoned segmented download
+func DeleteFromPartialDownloadState(filePath string) {
+	partialDownloadStateLock.Lock()
+	defer partialDownloadStateLock.Unlock()
+	if _, present := partialDownloadStateMap[filePath]; !present {
+		return
+	}
+	delete(partialDownloadStateMap, filePath)
+	if err := writeToPartialDownloadStateLog(); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// writeToPartialDownloadStateLog saves the partial download state to its file. Without InitPartialDownloadStateLog the state is only kept in memory.
+func writeToPartialDownloadStateLog() error {
+	if partialDownloadStateFilename == "" {
+		return nil
+	}
+	jsonData, err := json.MarshalIndent(partialDownloadStateMap, "", "  ")
+	if err != nil {
+		return errors.New("Error occurred when marshaling to JSON objects: " + err.Error())
+	}
+	err = writeFileAtomically(partialDownloadStateFilename, jsonData)
+	if err != nil {
+		return errors.New("Error occurred when writing to file \"" + partialDownloadStateFilename + "\": " + err.Error())
+	}
+	partialDownloadStateLastSaved = time.Now()
+	return nil
+}
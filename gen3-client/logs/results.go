@@ -0,0 +1,161 @@
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Output formats of the global --output flag
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// Statuses of a file in the JSON output
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+)
+
+// FileResult is the outcome of uploading, downloading or deleting a file, as reported in the JSON output
+type FileResult struct {
+	GUID     string  `json:"guid,omitempty"`
+	Filename string  `json:"filename,omitempty"`
+	FilePath string  `json:"file_path,omitempty"`
+	Status   string  `json:"status"`
+	Bytes    int64   `json:"bytes"`
+	Duration float64 `json:"duration_seconds"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// CommandResult is the JSON document printed on stdout once a command that transfers or deletes files has finished
+type CommandResult struct {
+	Command   string       `json:"command"`
+	Profile   string       `json:"profile,omitempty"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Skipped   int          `json:"skipped"`
+	Files     []FileResult `json:"files"`
+}
+
+type fileResultEntry struct {
+	result  FileResult
+	started time.Time
+}
+
+var outputFormat = OutputText
+
+var fileResults = make(map[string]*fileResultEntry)
+var fileResultKeys []string
+var fileResultsLock sync.Mutex
+
+// SetOutputFormat selects text or JSON output. In JSON mode stdout only carries the JSON result,
+// see MessageOutput for where everything else is printed.
+func SetOutputFormat(format string) error {
+	switch format {
+	case OutputText, OutputJSON:
+	default:
+		return fmt.Errorf("Invalid output format %q, valid formats are %q and %q", format, OutputText, OutputJSON)
+	}
+	outputFormat = format
+	return nil
+}
+
+func IsJSONOutput() bool {
+	return outputFormat == OutputJSON
+}
+
+// MessageOutput returns where the messages meant for the user, other than the results, are printed:
+// stdout in text mode, and stderr in JSON mode so that they don't get mixed with the JSON result
+func MessageOutput() io.Writer {
+	if IsJSONOutput() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// PrintJSON writes a result as indented JSON on stdout
+func PrintJSON(result interface{}) error {
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(os.Stdout, string(jsonData))
+	return err
+}
+
+// StartFileResult marks when the transfer of a file has started, so its result can report how long it took.
+// A file that is retried keeps its first start time.
+func StartFileResult(key string) {
+	fileResultsLock.Lock()
+	defer fileResultsLock.Unlock()
+	getFileResultEntry(key)
+}
+
+// RecordFileResult records the outcome of a file, replacing any earlier outcome recorded under the same key.
+// A failure without an error message keeps the message of the failure recorded before it.
+func RecordFileResult(key string, result FileResult) {
+	fileResultsLock.Lock()
+	defer fileResultsLock.Unlock()
+	entry := getFileResultEntry(key)
+	if result.Status == StatusFailed && result.Error == "" && entry.result.Status == StatusFailed {
+		result.Error = entry.result.Error
+	}
+	if result.Status != StatusSkipped {
+		result.Duration = time.Since(entry.started).Seconds()
+	}
+	entry.result = result
+}
+
+func getFileResultEntry(key string) *fileResultEntry {
+	entry, ok := fileResults[key]
+	if !ok {
+		entry = &fileResultEntry{started: time.Now()}
+		fileResults[key] = entry
+		fileResultKeys = append(fileResultKeys, key)
+	}
+	return entry
+}
+
+// ResetFileResults forgets the recorded file results, so that the results of an operation don't include those of
+// the operations run before it in the same process
+func ResetFileResults() {
+	fileResultsLock.Lock()
+	defer fileResultsLock.Unlock()
+	fileResults = make(map[string]*fileResultEntry)
+	fileResultKeys = nil
+}
+
+// GetFileResults returns the recorded file results, in the order the files have first been seen
+func GetFileResults() []FileResult {
+	fileResultsLock.Lock()
+	defer fileResultsLock.Unlock()
+	results := make([]FileResult, 0, len(fileResultKeys))
+	for _, key := range fileResultKeys {
+		if entry := fileResults[key]; entry.result.Status != "" {
+			results = append(results, entry.result)
+		}
+	}
+	return results
+}
+
+// NewCommandResult summarizes the recorded file results of a command
+func NewCommandResult(command string, profile string) CommandResult {
+	result := CommandResult{Command: command, Profile: profile, Files: GetFileResults()}
+	for _, file := range result.Files {
+		switch file.Status {
+		case StatusSucceeded:
+			result.Succeeded++
+		case StatusFailed:
+			result.Failed++
+		case StatusSkipped:
+			result.Skipped++
+		}
+	}
+	return result
+}
@@ -16,8 +16,9 @@ func InitScoreBoard(maxRetryCount int) {
 	ScoreBoardLen = len(scoreBoard)
 }
 
+// PrintScoreBoard prints the submission results as a table, unless the results are printed as JSON
 func PrintScoreBoard() {
-	if scoreBoard != nil {
+	if scoreBoard != nil && !IsJSONOutput() {
 		sum := 0
 		fmt.Println("\n\nSubmission Results")
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 0, ' ', tabwriter.Debug)
@@ -2,42 +2,68 @@ package logs
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"sync"
 )
 
+// SucceededLogEntry records the GUID an uploaded file has been assigned, along with its size and checksums
+type SucceededLogEntry struct {
+	GUID   string            `json:"guid"`
+	Size   int64             `json:"size,omitempty"`
+	Hashes map[string]string `json:"hashes,omitempty"`
+}
+
+// UnmarshalJSON also accepts the legacy succeeded log format, in which an entry is only the GUID
+func (entry *SucceededLogEntry) UnmarshalJSON(data []byte) error {
+	var guid string
+	if err := json.Unmarshal(data, &guid); err == nil {
+		*entry = SucceededLogEntry{GUID: guid}
+		return nil
+	}
+	type succeededLogEntry SucceededLogEntry
+	var decoded succeededLogEntry
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*entry = SucceededLogEntry(decoded)
+	return nil
+}
+
 var succeededLogFilename string
-var succeededLogFileMap map[string]string
-var succeededLogFile *os.File
+var succeededLogFileMap = make(map[string]SucceededLogEntry)
 var succeededLogLock sync.Mutex
 
-func InitSucceededLog(profile string) {
+func InitSucceededLog(profile string) error {
 	succeededLogFilename = MainLogPath + profile + "_succeeded_log.json"
 
-	succeededLogFile, _ = os.OpenFile(succeededLogFilename, os.O_RDWR|os.O_CREATE, 0766)
+	succeededLogFile, err := os.OpenFile(succeededLogFilename, os.O_RDWR|os.O_CREATE, 0766)
+	if err != nil {
+		return errors.New("Error occurred when opening file \"" + succeededLogFilename + "\": " + err.Error())
+	}
+	defer succeededLogFile.Close()
 	fi, err := succeededLogFile.Stat()
 	if err != nil {
-		succeededLogFile.Close()
-		log.Fatal("Error occurred when opening file \"" + succeededLogFilename + "\": " + err.Error())
+		return errors.New("Error occurred when opening file \"" + succeededLogFilename + "\": " + err.Error())
 	}
 	log.Println("Local succeeded log file \"" + succeededLogFilename + "\" has opened")
 
-	succeededLogFileMap = make(map[string]string)
+	succeededLogFileMap = make(map[string]SucceededLogEntry)
 	if fi.Size() > 0 {
 		data, err := ioutil.ReadAll(succeededLogFile)
 		if err != nil {
-			succeededLogFile.Close()
-			log.Fatal("Error occurred when reading from file \"" + succeededLogFilename + "\": " + err.Error())
+			return errors.New("Error occurred when reading from file \"" + succeededLogFilename + "\": " + err.Error())
 		}
 
 		err = json.Unmarshal(data, &succeededLogFileMap)
 		if err != nil {
-			succeededLogFile.Close()
-			log.Fatal("Error occurred when unmarshaling from JSON objects: " + err.Error())
+			return errors.New("Error occurred when unmarshaling from JSON objects: " + err.Error())
 		}
 	}
+	return nil
 }
 
 func ExistsInSucceededLog(filePath string) bool {
@@ -45,32 +71,50 @@ func ExistsInSucceededLog(filePath string) bool {
 	return present
 }
 
-func WriteToSucceededLog(filePath string, guid string, isMuted bool) {
+// GetSucceededLogEntry returns the entry of a file that has already been uploaded
+func GetSucceededLogEntry(filePath string) (SucceededLogEntry, bool) {
 	succeededLogLock.Lock()
 	defer succeededLogLock.Unlock()
-	succeededLogFileMap[filePath] = guid
-	jsonData, err := json.MarshalIndent(succeededLogFileMap, "", "  ")
-	if err != nil {
-		succeededLogFile.Close()
-		log.Fatal("Error occurred when marshaling to JSON objects: " + err.Error())
+	entry, present := succeededLogFileMap[filePath]
+	return entry, present
+}
+
+func WriteToSucceededLog(filePath string, guid string, size int64, hashes map[string]string, isMuted bool) {
+	succeededLogLock.Lock()
+	defer succeededLogLock.Unlock()
+	succeededLogFileMap[filePath] = SucceededLogEntry{GUID: guid, Size: size, Hashes: hashes}
+	RecordFileResult(filePath, FileResult{GUID: guid, Filename: filepath.Base(filePath), FilePath: filePath, Status: StatusSucceeded, Bytes: size})
+	if err := writeToSucceededLog(); err != nil {
+		log.Println(err.Error())
+		return
 	}
-	err = succeededLogFile.Truncate(0)
-	if err != nil {
-		failedLogFile.Close()
-		log.Fatal("Error occurred when truncating file \"" + failedLogFilename + "\": " + err.Error())
+	if !isMuted {
+		log.Println("Local succeeded log file updated")
+	}
+}
+
+// writeToSucceededLog saves the succeeded log to its file, replacing the file at once so that it's never left half-written.
+// Without InitSucceededLog the succeeded log is only kept in memory.
+func writeToSucceededLog() error {
+	if succeededLogFilename == "" {
+		return nil
 	}
-	_, err = succeededLogFile.WriteAt(jsonData, 0)
+	jsonData, err := json.MarshalIndent(succeededLogFileMap, "", "  ")
 	if err != nil {
-		succeededLogFile.Close()
-		log.Fatal("Error occurred when writing to file \"" + succeededLogFilename + "\": " + err.Error())
+		return errors.New("Error occurred when marshaling to JSON objects: " + err.Error())
 	}
-	if !isMuted {
-		log.Println("Local succeeded log file updated")
+	err = writeFileAtomically(succeededLogFilename, jsonData)
+	if err != nil {
+		return errors.New("Error occurred when writing to file \"" + succeededLogFilename + "\": " + err.Error())
 	}
+	return nil
 }
 
 func closeSucceededLog() error {
+	if succeededLogFilename == "" {
+		return nil
+	}
 	SetToMessageLog()
 	log.Println("Local succeeded log file \"" + succeededLogFilename + "\" has closed")
-	return succeededLogFile.Close()
+	return nil
 }
@@ -1,6 +1,7 @@
 package logs
 
 import (
+	"errors"
 	"io"
 	"log"
 	"os"
@@ -11,18 +12,18 @@ var messageLogFilename string
 var messageLogFile *os.File
 var multiWriter io.Writer
 
-func InitMessageLog(profile string) {
+func InitMessageLog(profile string) error {
 	var err error
 	messageLogFilename = MainLogPath + profile + "_message_log_" + time.Now().Format("20060102150405MST") + ".log"
 
 	messageLogFile, err = os.OpenFile(messageLogFilename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0766)
 	if err != nil {
-		messageLogFile.Close()
-		log.Fatalln("Error occurred when opening file \"" + messageLogFilename + "\": " + err.Error())
+		return errors.New("Error occurred when opening file \"" + messageLogFilename + "\": " + err.Error())
 	}
 	multiWriter = io.MultiWriter(os.Stderr, messageLogFile)
 	log.SetOutput(messageLogFile)
 	log.Println("Local message log file \"" + messageLogFilename + "\" has opened")
+	return nil
 }
 
 func SetToMessageLog() {
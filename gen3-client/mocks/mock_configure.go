@@ -34,6 +34,18 @@ func (m *MockConfigureInterface) EXPECT() *MockConfigureInterfaceMockRecorder {
 	return m.recorder
 }
 
+// CopyProfile mocks base method
+func (m *MockConfigureInterface) CopyProfile(arg0, arg1 string) error {
+	ret := m.ctrl.Call(m, "CopyProfile", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CopyProfile indicates an expected call of CopyProfile
+func (mr *MockConfigureInterfaceMockRecorder) CopyProfile(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CopyProfile", reflect.TypeOf((*MockConfigureInterface)(nil).CopyProfile), arg0, arg1)
+}
+
 // GetConfigPath mocks base method
 func (m *MockConfigureInterface) GetConfigPath() (string, error) {
 	ret := m.ctrl.Call(m, "GetConfigPath")
@@ -47,11 +59,63 @@ func (mr *MockConfigureInterfaceMockRecorder) GetConfigPath() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConfigPath", reflect.TypeOf((*MockConfigureInterface)(nil).GetConfigPath))
 }
 
+// GetDefaultProfile mocks base method
+func (m *MockConfigureInterface) GetDefaultProfile() (string, error) {
+	ret := m.ctrl.Call(m, "GetDefaultProfile")
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDefaultProfile indicates an expected call of GetDefaultProfile
+func (mr *MockConfigureInterfaceMockRecorder) GetDefaultProfile() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDefaultProfile", reflect.TypeOf((*MockConfigureInterface)(nil).GetDefaultProfile))
+}
+
+// GetSecretStore mocks base method
+func (m *MockConfigureInterface) GetSecretStore(arg0 string) (jwt.SecretStore, error) {
+	ret := m.ctrl.Call(m, "GetSecretStore", arg0)
+	ret0, _ := ret[0].(jwt.SecretStore)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSecretStore indicates an expected call of GetSecretStore
+func (mr *MockConfigureInterfaceMockRecorder) GetSecretStore(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSecretStore", reflect.TypeOf((*MockConfigureInterface)(nil).GetSecretStore), arg0)
+}
+
+// ListProfiles mocks base method
+func (m *MockConfigureInterface) ListProfiles() ([]string, error) {
+	ret := m.ctrl.Call(m, "ListProfiles")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListProfiles indicates an expected call of ListProfiles
+func (mr *MockConfigureInterfaceMockRecorder) ListProfiles() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListProfiles", reflect.TypeOf((*MockConfigureInterface)(nil).ListProfiles))
+}
+
+// MigrateSecrets mocks base method
+func (m *MockConfigureInterface) MigrateSecrets(arg0, arg1 string) error {
+	ret := m.ctrl.Call(m, "MigrateSecrets", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MigrateSecrets indicates an expected call of MigrateSecrets
+func (mr *MockConfigureInterfaceMockRecorder) MigrateSecrets(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MigrateSecrets", reflect.TypeOf((*MockConfigureInterface)(nil).MigrateSecrets), arg0, arg1)
+}
+
 // ParseConfig mocks base method
-func (m *MockConfigureInterface) ParseConfig(arg0 string) jwt.Credential {
+func (m *MockConfigureInterface) ParseConfig(arg0 string) (jwt.Credential, error) {
 	ret := m.ctrl.Call(m, "ParseConfig", arg0)
 	ret0, _ := ret[0].(jwt.Credential)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // ParseConfig indicates an expected call of ParseConfig
@@ -84,9 +148,72 @@ func (mr *MockConfigureInterfaceMockRecorder) ReadFile(arg0, arg1 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadFile", reflect.TypeOf((*MockConfigureInterface)(nil).ReadFile), arg0, arg1)
 }
 
+// ReadProfile mocks base method
+func (m *MockConfigureInterface) ReadProfile(arg0 string) (jwt.Credential, error) {
+	ret := m.ctrl.Call(m, "ReadProfile", arg0)
+	ret0, _ := ret[0].(jwt.Credential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadProfile indicates an expected call of ReadProfile
+func (mr *MockConfigureInterfaceMockRecorder) ReadProfile(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadProfile", reflect.TypeOf((*MockConfigureInterface)(nil).ReadProfile), arg0)
+}
+
+// RemoveProfile mocks base method
+func (m *MockConfigureInterface) RemoveProfile(arg0 string) error {
+	ret := m.ctrl.Call(m, "RemoveProfile", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveProfile indicates an expected call of RemoveProfile
+func (mr *MockConfigureInterfaceMockRecorder) RemoveProfile(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveProfile", reflect.TypeOf((*MockConfigureInterface)(nil).RemoveProfile), arg0)
+}
+
+// RenameProfile mocks base method
+func (m *MockConfigureInterface) RenameProfile(arg0, arg1 string) error {
+	ret := m.ctrl.Call(m, "RenameProfile", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RenameProfile indicates an expected call of RenameProfile
+func (mr *MockConfigureInterfaceMockRecorder) RenameProfile(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RenameProfile", reflect.TypeOf((*MockConfigureInterface)(nil).RenameProfile), arg0, arg1)
+}
+
+// SetDefaultProfile mocks base method
+func (m *MockConfigureInterface) SetDefaultProfile(arg0 string) error {
+	ret := m.ctrl.Call(m, "SetDefaultProfile", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetDefaultProfile indicates an expected call of SetDefaultProfile
+func (mr *MockConfigureInterfaceMockRecorder) SetDefaultProfile(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDefaultProfile", reflect.TypeOf((*MockConfigureInterface)(nil).SetDefaultProfile), arg0)
+}
+
+// UpdateAccessToken mocks base method
+func (m *MockConfigureInterface) UpdateAccessToken(arg0 jwt.Credential) error {
+	ret := m.ctrl.Call(m, "UpdateAccessToken", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAccessToken indicates an expected call of UpdateAccessToken
+func (mr *MockConfigureInterfaceMockRecorder) UpdateAccessToken(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccessToken", reflect.TypeOf((*MockConfigureInterface)(nil).UpdateAccessToken), arg0)
+}
+
 // UpdateConfigFile mocks base method
-func (m *MockConfigureInterface) UpdateConfigFile(arg0 jwt.Credential) {
-	m.ctrl.Call(m, "UpdateConfigFile", arg0)
+func (m *MockConfigureInterface) UpdateConfigFile(arg0 jwt.Credential) error {
+	ret := m.ctrl.Call(m, "UpdateConfigFile", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
 // UpdateConfigFile indicates an expected call of UpdateConfigFile
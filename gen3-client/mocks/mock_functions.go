@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
 	jwt "github.com/uc-cdis/gen3-client/gen3-client/jwt"
 	http "net/http"
@@ -36,21 +37,21 @@ func (m *MockFunctionInterface) EXPECT() *MockFunctionInterfaceMockRecorder {
 }
 
 // CheckForShepherdAPI mocks base method
-func (m *MockFunctionInterface) CheckForShepherdAPI(arg0 *jwt.Credential) (bool, error) {
-	ret := m.ctrl.Call(m, "CheckForShepherdAPI", arg0)
+func (m *MockFunctionInterface) CheckForShepherdAPI(arg0 context.Context, arg1 *jwt.Credential) (bool, error) {
+	ret := m.ctrl.Call(m, "CheckForShepherdAPI", arg0, arg1)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CheckForShepherdAPI indicates an expected call of CheckForShepherdAPI
-func (mr *MockFunctionInterfaceMockRecorder) CheckForShepherdAPI(arg0 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckForShepherdAPI", reflect.TypeOf((*MockFunctionInterface)(nil).CheckForShepherdAPI), arg0)
+func (mr *MockFunctionInterfaceMockRecorder) CheckForShepherdAPI(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckForShepherdAPI", reflect.TypeOf((*MockFunctionInterface)(nil).CheckForShepherdAPI), arg0, arg1)
 }
 
 // CheckPrivileges mocks base method
-func (m *MockFunctionInterface) CheckPrivileges(arg0 *jwt.Credential) (string, map[string]interface{}, error) {
-	ret := m.ctrl.Call(m, "CheckPrivileges", arg0)
+func (m *MockFunctionInterface) CheckPrivileges(arg0 context.Context, arg1 *jwt.Credential) (string, map[string]interface{}, error) {
+	ret := m.ctrl.Call(m, "CheckPrivileges", arg0, arg1)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(map[string]interface{})
 	ret2, _ := ret[2].(error)
@@ -58,21 +59,21 @@ func (m *MockFunctionInterface) CheckPrivileges(arg0 *jwt.Credential) (string, m
 }
 
 // CheckPrivileges indicates an expected call of CheckPrivileges
-func (mr *MockFunctionInterfaceMockRecorder) CheckPrivileges(arg0 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckPrivileges", reflect.TypeOf((*MockFunctionInterface)(nil).CheckPrivileges), arg0)
+func (mr *MockFunctionInterfaceMockRecorder) CheckPrivileges(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckPrivileges", reflect.TypeOf((*MockFunctionInterface)(nil).CheckPrivileges), arg0, arg1)
 }
 
 // DoRequestWithSignedHeader mocks base method
-func (m *MockFunctionInterface) DoRequestWithSignedHeader(arg0 *jwt.Credential, arg1, arg2 string, arg3 []byte) (jwt.JsonMessage, error) {
-	ret := m.ctrl.Call(m, "DoRequestWithSignedHeader", arg0, arg1, arg2, arg3)
+func (m *MockFunctionInterface) DoRequestWithSignedHeader(arg0 context.Context, arg1 *jwt.Credential, arg2, arg3 string, arg4 []byte) (jwt.JsonMessage, error) {
+	ret := m.ctrl.Call(m, "DoRequestWithSignedHeader", arg0, arg1, arg2, arg3, arg4)
 	ret0, _ := ret[0].(jwt.JsonMessage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DoRequestWithSignedHeader indicates an expected call of DoRequestWithSignedHeader
-func (mr *MockFunctionInterfaceMockRecorder) DoRequestWithSignedHeader(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoRequestWithSignedHeader", reflect.TypeOf((*MockFunctionInterface)(nil).DoRequestWithSignedHeader), arg0, arg1, arg2, arg3)
+func (mr *MockFunctionInterfaceMockRecorder) DoRequestWithSignedHeader(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoRequestWithSignedHeader", reflect.TypeOf((*MockFunctionInterface)(nil).DoRequestWithSignedHeader), arg0, arg1, arg2, arg3, arg4)
 }
 
 // GetHost mocks base method
@@ -89,8 +90,8 @@ func (mr *MockFunctionInterfaceMockRecorder) GetHost(arg0 interface{}) *gomock.C
 }
 
 // GetResponse mocks base method
-func (m *MockFunctionInterface) GetResponse(arg0 *jwt.Credential, arg1, arg2, arg3 string, arg4 []byte) (string, *http.Response, error) {
-	ret := m.ctrl.Call(m, "GetResponse", arg0, arg1, arg2, arg3, arg4)
+func (m *MockFunctionInterface) GetResponse(arg0 context.Context, arg1 *jwt.Credential, arg2, arg3, arg4 string, arg5 []byte) (string, *http.Response, error) {
+	ret := m.ctrl.Call(m, "GetResponse", arg0, arg1, arg2, arg3, arg4, arg5)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(*http.Response)
 	ret2, _ := ret[2].(error)
@@ -98,8 +99,8 @@ func (m *MockFunctionInterface) GetResponse(arg0 *jwt.Credential, arg1, arg2, ar
 }
 
 // GetResponse indicates an expected call of GetResponse
-func (mr *MockFunctionInterfaceMockRecorder) GetResponse(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetResponse", reflect.TypeOf((*MockFunctionInterface)(nil).GetResponse), arg0, arg1, arg2, arg3, arg4)
+func (mr *MockFunctionInterfaceMockRecorder) GetResponse(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetResponse", reflect.TypeOf((*MockFunctionInterface)(nil).GetResponse), arg0, arg1, arg2, arg3, arg4, arg5)
 }
 
 // ParseFenceURLResponse mocks base method
@@ -1,11 +1,12 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/uc-cdis/gen3-client/gen3-client/g3cmd (interfaces: Gen3Interface)
+// Source: github.com/uc-cdis/gen3-client/gen3-client/gen3 (interfaces: Gen3Interface)
 
 // Package mocks is a generated GoMock package.
 package mocks
 
 import (
 	bytes "bytes"
+	context "context"
 	gomock "github.com/golang/mock/gomock"
 	jwt "github.com/uc-cdis/gen3-client/gen3-client/jwt"
 	http "net/http"
@@ -37,21 +38,21 @@ func (m *MockGen3Interface) EXPECT() *MockGen3InterfaceMockRecorder {
 }
 
 // CheckForShepherdAPI mocks base method
-func (m *MockGen3Interface) CheckForShepherdAPI(arg0 *jwt.Credential) (bool, error) {
-	ret := m.ctrl.Call(m, "CheckForShepherdAPI", arg0)
+func (m *MockGen3Interface) CheckForShepherdAPI(arg0 context.Context, arg1 *jwt.Credential) (bool, error) {
+	ret := m.ctrl.Call(m, "CheckForShepherdAPI", arg0, arg1)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CheckForShepherdAPI indicates an expected call of CheckForShepherdAPI
-func (mr *MockGen3InterfaceMockRecorder) CheckForShepherdAPI(arg0 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckForShepherdAPI", reflect.TypeOf((*MockGen3Interface)(nil).CheckForShepherdAPI), arg0)
+func (mr *MockGen3InterfaceMockRecorder) CheckForShepherdAPI(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckForShepherdAPI", reflect.TypeOf((*MockGen3Interface)(nil).CheckForShepherdAPI), arg0, arg1)
 }
 
 // CheckPrivileges mocks base method
-func (m *MockGen3Interface) CheckPrivileges(arg0 *jwt.Credential) (string, map[string]interface{}, error) {
-	ret := m.ctrl.Call(m, "CheckPrivileges", arg0)
+func (m *MockGen3Interface) CheckPrivileges(arg0 context.Context, arg1 *jwt.Credential) (string, map[string]interface{}, error) {
+	ret := m.ctrl.Call(m, "CheckPrivileges", arg0, arg1)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(map[string]interface{})
 	ret2, _ := ret[2].(error)
@@ -59,21 +60,34 @@ func (m *MockGen3Interface) CheckPrivileges(arg0 *jwt.Credential) (string, map[s
 }
 
 // CheckPrivileges indicates an expected call of CheckPrivileges
-func (mr *MockGen3InterfaceMockRecorder) CheckPrivileges(arg0 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckPrivileges", reflect.TypeOf((*MockGen3Interface)(nil).CheckPrivileges), arg0)
+func (mr *MockGen3InterfaceMockRecorder) CheckPrivileges(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckPrivileges", reflect.TypeOf((*MockGen3Interface)(nil).CheckPrivileges), arg0, arg1)
+}
+
+// DeleteRecord mocks base method
+func (m *MockGen3Interface) DeleteRecord(arg0 context.Context, arg1 *jwt.Credential, arg2 string) (string, error) {
+	ret := m.ctrl.Call(m, "DeleteRecord", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteRecord indicates an expected call of DeleteRecord
+func (mr *MockGen3InterfaceMockRecorder) DeleteRecord(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRecord", reflect.TypeOf((*MockGen3Interface)(nil).DeleteRecord), arg0, arg1, arg2)
 }
 
 // DoRequestWithSignedHeader mocks base method
-func (m *MockGen3Interface) DoRequestWithSignedHeader(arg0 *jwt.Credential, arg1, arg2 string, arg3 []byte) (jwt.JsonMessage, error) {
-	ret := m.ctrl.Call(m, "DoRequestWithSignedHeader", arg0, arg1, arg2, arg3)
+func (m *MockGen3Interface) DoRequestWithSignedHeader(arg0 context.Context, arg1 *jwt.Credential, arg2, arg3 string, arg4 []byte) (jwt.JsonMessage, error) {
+	ret := m.ctrl.Call(m, "DoRequestWithSignedHeader", arg0, arg1, arg2, arg3, arg4)
 	ret0, _ := ret[0].(jwt.JsonMessage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DoRequestWithSignedHeader indicates an expected call of DoRequestWithSignedHeader
-func (mr *MockGen3InterfaceMockRecorder) DoRequestWithSignedHeader(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoRequestWithSignedHeader", reflect.TypeOf((*MockGen3Interface)(nil).DoRequestWithSignedHeader), arg0, arg1, arg2, arg3)
+func (mr *MockGen3InterfaceMockRecorder) DoRequestWithSignedHeader(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoRequestWithSignedHeader", reflect.TypeOf((*MockGen3Interface)(nil).DoRequestWithSignedHeader), arg0, arg1, arg2, arg3, arg4)
 }
 
 // GetHost mocks base method
@@ -90,8 +104,8 @@ func (mr *MockGen3InterfaceMockRecorder) GetHost(arg0 interface{}) *gomock.Call
 }
 
 // GetResponse mocks base method
-func (m *MockGen3Interface) GetResponse(arg0 *jwt.Credential, arg1, arg2, arg3 string, arg4 []byte) (string, *http.Response, error) {
-	ret := m.ctrl.Call(m, "GetResponse", arg0, arg1, arg2, arg3, arg4)
+func (m *MockGen3Interface) GetResponse(arg0 context.Context, arg1 *jwt.Credential, arg2, arg3, arg4 string, arg5 []byte) (string, *http.Response, error) {
+	ret := m.ctrl.Call(m, "GetResponse", arg0, arg1, arg2, arg3, arg4, arg5)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(*http.Response)
 	ret2, _ := ret[2].(error)
@@ -99,19 +113,19 @@ func (m *MockGen3Interface) GetResponse(arg0 *jwt.Credential, arg1, arg2, arg3 s
 }
 
 // GetResponse indicates an expected call of GetResponse
-func (mr *MockGen3InterfaceMockRecorder) GetResponse(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetResponse", reflect.TypeOf((*MockGen3Interface)(nil).GetResponse), arg0, arg1, arg2, arg3, arg4)
+func (mr *MockGen3InterfaceMockRecorder) GetResponse(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetResponse", reflect.TypeOf((*MockGen3Interface)(nil).GetResponse), arg0, arg1, arg2, arg3, arg4, arg5)
 }
 
 // MakeARequest mocks base method
-func (m *MockGen3Interface) MakeARequest(arg0, arg1, arg2, arg3 string, arg4 map[string]string, arg5 *bytes.Buffer, arg6 bool) (*http.Response, error) {
-	ret := m.ctrl.Call(m, "MakeARequest", arg0, arg1, arg2, arg3, arg4, arg5, arg6)
+func (m *MockGen3Interface) MakeARequest(arg0 context.Context, arg1, arg2, arg3, arg4 string, arg5 map[string]string, arg6 *bytes.Buffer, arg7 bool) (*http.Response, error) {
+	ret := m.ctrl.Call(m, "MakeARequest", arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7)
 	ret0, _ := ret[0].(*http.Response)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // MakeARequest indicates an expected call of MakeARequest
-func (mr *MockGen3InterfaceMockRecorder) MakeARequest(arg0, arg1, arg2, arg3, arg4, arg5, arg6 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeARequest", reflect.TypeOf((*MockGen3Interface)(nil).MakeARequest), arg0, arg1, arg2, arg3, arg4, arg5, arg6)
+func (mr *MockGen3InterfaceMockRecorder) MakeARequest(arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeARequest", reflect.TypeOf((*MockGen3Interface)(nil).MakeARequest), arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7)
 }
@@ -6,6 +6,7 @@ package mocks
 
 import (
 	bytes "bytes"
+	context "context"
 	gomock "github.com/golang/mock/gomock"
 	jwt "github.com/uc-cdis/gen3-client/gen3-client/jwt"
 	http "net/http"
@@ -36,26 +37,26 @@ func (m *MockRequestInterface) EXPECT() *MockRequestInterfaceMockRecorder {
 }
 
 // MakeARequest mocks base method
-func (m *MockRequestInterface) MakeARequest(arg0, arg1, arg2, arg3 string, arg4 map[string]string, arg5 *bytes.Buffer, arg6 bool) (*http.Response, error) {
-	ret := m.ctrl.Call(m, "MakeARequest", arg0, arg1, arg2, arg3, arg4, arg5, arg6)
+func (m *MockRequestInterface) MakeARequest(arg0 context.Context, arg1, arg2, arg3, arg4 string, arg5 map[string]string, arg6 *bytes.Buffer, arg7 bool) (*http.Response, error) {
+	ret := m.ctrl.Call(m, "MakeARequest", arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7)
 	ret0, _ := ret[0].(*http.Response)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // MakeARequest indicates an expected call of MakeARequest
-func (mr *MockRequestInterfaceMockRecorder) MakeARequest(arg0, arg1, arg2, arg3, arg4, arg5, arg6 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeARequest", reflect.TypeOf((*MockRequestInterface)(nil).MakeARequest), arg0, arg1, arg2, arg3, arg4, arg5, arg6)
+func (mr *MockRequestInterfaceMockRecorder) MakeARequest(arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeARequest", reflect.TypeOf((*MockRequestInterface)(nil).MakeARequest), arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7)
 }
 
 // RequestNewAccessToken mocks base method
-func (m *MockRequestInterface) RequestNewAccessToken(arg0 string, arg1 *jwt.Credential) error {
-	ret := m.ctrl.Call(m, "RequestNewAccessToken", arg0, arg1)
+func (m *MockRequestInterface) RequestNewAccessToken(arg0 context.Context, arg1 string, arg2 *jwt.Credential) error {
+	ret := m.ctrl.Call(m, "RequestNewAccessToken", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // RequestNewAccessToken indicates an expected call of RequestNewAccessToken
-func (mr *MockRequestInterfaceMockRecorder) RequestNewAccessToken(arg0, arg1 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestNewAccessToken", reflect.TypeOf((*MockRequestInterface)(nil).RequestNewAccessToken), arg0, arg1)
+func (mr *MockRequestInterfaceMockRecorder) RequestNewAccessToken(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestNewAccessToken", reflect.TypeOf((*MockRequestInterface)(nil).RequestNewAccessToken), arg0, arg1, arg2)
 }
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
+	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
+	"github.com/uc-cdis/gen3-client/gen3-client/mocks"
+)
+
+// Stat gets the file record of an object with the credential of the client
+func TestClientStat(t *testing.T) {
+	testGUID := "000000-0000000-0000000-000000"
+	testCredential := jwt.Credential{Profile: "test-profile", APIEndpoint: "https://example.org"}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+	mockGen3Interface.
+		EXPECT().
+		CheckForShepherdAPI(gomock.Any(), &testCredential).
+		Return(false, nil)
+	mockGen3Interface.
+		EXPECT().
+		DoRequestWithSignedHeader(gomock.Any(), &testCredential, commonUtils.IndexdIndexEndpoint+"/"+testGUID, "", nil).
+		Return(jwt.JsonMessage{FileName: "test-file", Size: 120, Hashes: map[string]string{"md5": "abc"}}, nil)
+
+	client := gen3.NewClient(testCredential, mockGen3Interface)
+	record, err := client.Stat(context.Background(), testGUID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if record.FileName != "test-file" || record.Size != 120 || record.Hashes["md5"] != "abc" {
+		t.Errorf("Got unexpected file record %+v", record)
+	}
+}
+
+// A cancelled context stops the client before any request is made
+func TestClientCancelledContext(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+	client := gen3.NewClient(jwt.Credential{}, mockGen3Interface)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := client.Stat(ctx, "000000-0000000-0000000-000000"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Stat to return context.Canceled, got %v", err)
+	}
+	if _, _, err := client.Privileges(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Privileges to return context.Canceled, got %v", err)
+	}
+	results, err := client.Delete(ctx, []gen3.ManifestObject{{ObjectID: "000000-0000000-0000000-000000"}}, 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Delete to return context.Canceled, got %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no record to be deleted, got %d result(s)", len(results))
+	}
+}
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
+)
+
+// Expect no more transfers than the limit to be in flight, and a cancelled wait to give up
+func TestConcurrencyControllerAcquire(t *testing.T) {
+	controller := gen3.NewConcurrencyController(2, 8)
+	if controller.Limit() != 2 {
+		t.Fatalf("Wanted the limit to start at the lower bound of 2, got %d", controller.Limit())
+	}
+	for i := 0; i < 2; i++ {
+		if err := controller.Acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := controller.Acquire(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Wanted a third transfer to wait beyond the limit, got %v", err)
+	}
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- controller.Acquire(context.Background())
+	}()
+	controller.Release()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wanted a waiting transfer to start once another one has been released")
+	}
+}
+
+// Expect the limit to grow while the transfers are held back by it, and to back off on throttling and errors
+func TestConcurrencyControllerAdjust(t *testing.T) {
+	controller := gen3.NewConcurrencyController(1, 8)
+	controller.Window = 20 * time.Millisecond
+	controller.Report(nil) // starts the first window
+
+	// throughput grows with each window, so every raise pays off
+	inFlight := 0
+	bytes := 1000
+	for _, want := range []int{2, 4, 8, 8} {
+		for inFlight < controller.Limit() {
+			if err := controller.Acquire(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			inFlight++
+		}
+		time.Sleep(25 * time.Millisecond)
+		controller.Add(bytes)
+		bytes *= 4
+		if got := controller.Limit(); got != want {
+			t.Fatalf("Wanted the limit to be raised to %d, got %d", want, got)
+		}
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	controller.Report(errors.New("Upload request got a non-200 response with status code 503"))
+	if got := controller.Limit(); got != 4 {
+		t.Errorf("Wanted the limit to be halved to 4 after a 503 response, got %d", got)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	controller.Report(errors.New("Error occurred during upload: connection reset by peer"))
+	if got := controller.Limit(); got != 3 {
+		t.Errorf("Wanted the limit to drop to 3 after errors, got %d", got)
+	}
+
+	for i := 0; i < 8; i++ {
+		time.Sleep(25 * time.Millisecond)
+		controller.Report(errors.New("HTTP status code for response: 429"))
+	}
+	if got := controller.Limit(); got != 1 {
+		t.Errorf("Wanted the limit to stay at the lower bound of 1 under throttling, got %d", got)
+	}
+}
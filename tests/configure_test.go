@@ -0,0 +1,378 @@
+package tests
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
+	"github.com/zalando/go-keyring"
+)
+
+// useTempHomeDir points the home directory, and so the config file, to an empty temporary directory
+func useTempHomeDir(t *testing.T) string {
+	tempDir, err := ioutil.TempDir("", "gen3-client-home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(tempDir)
+		homedir.DisableCache = false
+		homedir.Reset()
+	})
+	homedir.DisableCache = true
+	t.Setenv("HOME", tempDir)
+	t.Setenv("USERPROFILE", tempDir)
+	for _, env := range []string{jwt.EnvAPIKey, jwt.EnvKeyID, jwt.EnvAccessToken, jwt.EnvEndpoint} {
+		t.Setenv(env, "")
+	}
+	return tempDir
+}
+
+// Expect credentials to be read from the environment without any config file, and never to be written to it.
+func TestParseConfig_environment(t *testing.T) {
+	homeDir := useTempHomeDir(t)
+	t.Setenv(jwt.EnvAPIKey, "env_api_key")
+	t.Setenv(jwt.EnvKeyID, "env_key_id")
+	t.Setenv(jwt.EnvEndpoint, "https://data.mycommons.org/")
+
+	conf := jwt.Configure{}
+	profileConfig, err := conf.ParseConfig("ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profileConfig.APIKey != "env_api_key" || profileConfig.KeyId != "env_key_id" || profileConfig.APIEndpoint != "https://data.mycommons.org" {
+		t.Errorf("Wanted credentials to be read from the environment, got %v", profileConfig)
+	}
+	if !profileConfig.InMemory {
+		t.Error("Wanted credentials from the environment to be kept in memory only")
+	}
+
+	conf.UpdateConfigFile(profileConfig)
+	if _, err := os.Stat(filepath.Join(homeDir, ".gen3", "gen3_client_config.ini")); !os.IsNotExist(err) {
+		t.Error("Wanted no config file to be written for credentials from the environment")
+	}
+}
+
+// Expect an error instead of an exit when the profile is missing and no credentials are in the environment.
+func TestParseConfig_missingProfile(t *testing.T) {
+	homeDir := useTempHomeDir(t)
+	err := os.Mkdir(filepath.Join(homeDir, ".gen3"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := "[other]\nkey_id=key_id\napi_key=api_key\naccess_token=access_token\napi_endpoint=https://data.mycommons.org\n"
+	err = ioutil.WriteFile(filepath.Join(homeDir, ".gen3", "gen3_client_config.ini"), []byte(config), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := jwt.Configure{}
+	_, err = conf.ParseConfig("missing")
+	var configError *jwt.ConfigError
+	if !errors.As(err, &configError) {
+		t.Errorf("Wanted a config error for a profile missing from the config file, got %v", err)
+	}
+
+	// an access token alone in the environment is enough, with the endpoint of the profile in the config file
+	t.Setenv(jwt.EnvAccessToken, "env_access_token")
+	profileConfig, err := conf.ParseConfig("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profileConfig.AccessToken != "env_access_token" || profileConfig.APIKey != "api_key" || profileConfig.APIEndpoint != "https://data.mycommons.org" {
+		t.Errorf("Wanted the access token from the environment to override the profile, got %v", profileConfig)
+	}
+}
+
+// Expect a profile whose endpoint alone is overridden through the environment never to be written back to the config file.
+func TestParseConfig_environmentEndpoint(t *testing.T) {
+	homeDir := useTempHomeDir(t)
+	err := os.Mkdir(filepath.Join(homeDir, ".gen3"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(homeDir, ".gen3", "gen3_client_config.ini")
+	config := "[test]\nkey_id=key_id\napi_key=api_key\naccess_token=access_token\napi_endpoint=https://data.mycommons.org\n"
+	err = ioutil.WriteFile(configPath, []byte(config), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(jwt.EnvEndpoint, "https://staging.mycommons.org")
+
+	conf := jwt.Configure{}
+	profileConfig, err := conf.ParseConfig("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profileConfig.APIEndpoint != "https://staging.mycommons.org" || !profileConfig.InMemory {
+		t.Errorf("Wanted the endpoint from the environment to be kept in memory only, got %v", profileConfig)
+	}
+
+	profileConfig.AccessToken = "new_access_token"
+	conf.UpdateConfigFile(profileConfig)
+	content, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != config {
+		t.Errorf("Wanted the config file to be left as is, got %s", content)
+	}
+}
+
+// Expect secrets migrated out of the config file to be replaced by references, and to be read back from the secret store.
+func TestMigrateSecrets(t *testing.T) {
+	homeDir := useTempHomeDir(t)
+	t.Setenv(jwt.EnvSecretPassphrase, "passphrase")
+	keyring.MockInit()
+	conf := jwt.Configure{}
+	err := conf.InitConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(homeDir, ".gen3", "gen3_client_config.ini")
+	conf.UpdateConfigFile(jwt.Credential{Profile: "test", KeyId: "key_id", APIKey: "api_key", AccessToken: "access_token", APIEndpoint: "https://data.mycommons.org"})
+
+	for _, store := range []string{jwt.SecretStoreEncryptedFile, jwt.SecretStoreKeyring, jwt.SecretStorePlaintext} {
+		err = conf.MigrateSecrets("test", store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadFile(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hasSecrets := strings.Contains(string(content), "= api_key") && strings.Contains(string(content), "= access_token")
+		if store == jwt.SecretStorePlaintext && !hasSecrets {
+			t.Errorf("Wanted secrets in the config file after migrating to %v, got %v", store, string(content))
+		} else if store != jwt.SecretStorePlaintext && hasSecrets {
+			t.Errorf("Wanted no secrets in the config file after migrating to %v, got %v", store, string(content))
+		}
+
+		profileConfig, err := conf.ParseConfig("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if profileConfig.APIKey != "api_key" || profileConfig.AccessToken != "access_token" || profileConfig.SecretStore != store {
+			t.Errorf("Wanted secrets to be read from the %v secret store, got %v", store, profileConfig)
+		}
+	}
+
+	err = conf.MigrateSecrets("test", jwt.SecretStoreEncryptedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := conf.GetSecretStore(jwt.SecretStoreEncryptedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedPath := filepath.Join(homeDir, ".gen3", "gen3_client_secrets.enc")
+	content, err := ioutil.ReadFile(encryptedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "access_token") {
+		t.Error("Wanted the secret file to be encrypted")
+	}
+	if _, err := store.Get("test", "missing"); err != jwt.ErrSecretNotFound {
+		t.Errorf("Wanted ErrSecretNotFound for a missing secret, got %v", err)
+	}
+}
+
+// Expect a refreshed access token to be saved without rewriting the rest of the profile, and not to be saved into an
+// encrypted secret file whose passphrase would have to be prompted for
+func TestUpdateAccessToken(t *testing.T) {
+	homeDir := useTempHomeDir(t)
+	t.Setenv(jwt.EnvSecretPassphrase, "")
+	conf := jwt.Configure{}
+	if err := conf.InitConfigFile(); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(homeDir, ".gen3", "gen3_client_config.ini")
+	config := `[plain]
+key_id       = key_id
+api_key      = api_key
+access_token = old_token
+api_endpoint = https://data.mycommons.org
+custom_key   = kept
+
+[encrypted]
+key_id       = key_id
+api_key      = gen3-secret:encrypted-file
+access_token = gen3-secret:encrypted-file
+api_endpoint = https://data.mycommons.org
+secret_store = encrypted-file
+`
+	if err := ioutil.WriteFile(configPath, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conf.UpdateAccessToken(jwt.Credential{Profile: "plain", APIKey: "other_api_key", AccessToken: "new_token"}); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "new_token") || !strings.Contains(string(content), "= api_key") || !strings.Contains(string(content), "custom_key") {
+		t.Errorf("Wanted only the access token of the profile to be updated, got %v", string(content))
+	}
+
+	if err := conf.UpdateAccessToken(jwt.Credential{Profile: "encrypted", AccessToken: "new_token"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(homeDir, ".gen3", "gen3_client_secrets.enc")); !os.IsNotExist(err) {
+		t.Errorf("Wanted the access token not to be saved without the passphrase of the encrypted secret file, got %v", err)
+	}
+	if err := conf.UpdateAccessToken(jwt.Credential{Profile: "missing", AccessToken: "new_token"}); err == nil {
+		t.Error("Wanted an error for a profile that isn't in the config file")
+	}
+}
+
+// Expect a profile with an expired API key to be rejected with a clear error, and one expiring soon to be accepted.
+func TestParseConfig_apiKeyExpiry(t *testing.T) {
+	useTempHomeDir(t)
+	t.Setenv(jwt.EnvKeyID, "env_key_id")
+	t.Setenv(jwt.EnvEndpoint, "https://data.mycommons.org")
+	conf := jwt.Configure{}
+
+	expiry := time.Now().Add(-time.Hour)
+	t.Setenv(jwt.EnvAPIKey, makeTestToken(expiry))
+	_, err := conf.ParseConfig("ci")
+	var authError *jwt.AuthError
+	if !errors.As(err, &authError) || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("Wanted an auth error for an expired API key, got %v", err)
+	}
+
+	expiry = time.Now().Add(24 * time.Hour)
+	t.Setenv(jwt.EnvAPIKey, makeTestToken(expiry))
+	profileConfig, err := conf.ParseConfig("ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jwt.GetAPIKeyExpiry(profileConfig.APIKey).Unix() != expiry.Unix() {
+		t.Errorf("Wanted the API key to expire at %v, got %v", expiry, jwt.GetAPIKeyExpiry(profileConfig.APIKey))
+	}
+	if !jwt.GetAPIKeyExpiry("not a jwt").IsZero() {
+		t.Error("Wanted no expiry for an API key that isn't a JWT")
+	}
+}
+
+// Expect copied and renamed profiles to keep their secrets, and removed profiles to lose them.
+func TestProfileManagement(t *testing.T) {
+	useTempHomeDir(t)
+	t.Setenv(jwt.EnvSecretPassphrase, "passphrase")
+	conf := jwt.Configure{}
+	err := conf.InitConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.UpdateConfigFile(jwt.Credential{Profile: "test", KeyId: "key_id", APIKey: "api_key", AccessToken: "access_token", APIEndpoint: "https://data.mycommons.org", SecretStore: jwt.SecretStoreEncryptedFile})
+
+	if err := conf.CopyProfile("test", "copy"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.CopyProfile("test", "copy"); err == nil {
+		t.Error("Wanted an error when copying to an existing profile")
+	}
+	if err := conf.RenameProfile("copy", "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	profiles, err := conf.ListProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(profiles, ",") != "test,renamed" {
+		t.Errorf("Wanted profiles test and renamed, got %v", profiles)
+	}
+	profileConfig, err := conf.ReadProfile("renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profileConfig.APIKey != "api_key" || profileConfig.SecretStore != jwt.SecretStoreEncryptedFile {
+		t.Errorf("Wanted the renamed profile to keep its secrets, got %v", profileConfig)
+	}
+
+	if err := conf.RemoveProfile("renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conf.ReadProfile("renamed"); err == nil {
+		t.Error("Wanted an error when reading a removed profile")
+	}
+	store, err := conf.GetSecretStore(jwt.SecretStoreEncryptedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("renamed", "api_key"); err != jwt.ErrSecretNotFound {
+		t.Errorf("Wanted the secrets of the removed profile to be removed, got %v", err)
+	}
+	if _, err := store.Get("test", "api_key"); err != nil {
+		t.Errorf("Wanted the secrets of the other profile to be kept, got %v", err)
+	}
+}
+
+// Expect a profile not to be removed by a rename whose copy couldn't be saved.
+func TestRenameProfile_saveError(t *testing.T) {
+	homeDir := useTempHomeDir(t)
+	conf := jwt.Configure{}
+	err := conf.InitConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.UpdateConfigFile(jwt.Credential{Profile: "test", KeyId: "key_id", APIKey: "api_key", APIEndpoint: "https://data.mycommons.org"})
+
+	// the config file is saved through a temporary file, which can't be written over a directory
+	err = os.Mkdir(filepath.Join(homeDir, ".gen3", "gen3_client_config.ini.tmp"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.RenameProfile("test", "renamed"); err == nil {
+		t.Error("Wanted an error when the renamed profile cannot be saved")
+	}
+	profileConfig, err := conf.ReadProfile("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profileConfig.APIKey != "api_key" {
+		t.Errorf("Wanted the profile to keep its secrets, got %v", profileConfig)
+	}
+}
+
+// Expect the default profile to follow its profile when renamed, and to be unset when it is removed.
+func TestDefaultProfile(t *testing.T) {
+	useTempHomeDir(t)
+	conf := jwt.Configure{}
+	err := conf.InitConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.UpdateConfigFile(jwt.Credential{Profile: "test", KeyId: "key_id", APIKey: "api_key", APIEndpoint: "https://data.mycommons.org"})
+
+	if err := conf.SetDefaultProfile("missing"); err == nil {
+		t.Error("Wanted an error when setting a missing profile as default")
+	}
+	if err := conf.SetDefaultProfile("test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.RenameProfile("test", "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if defaultProfile, _ := conf.GetDefaultProfile(); defaultProfile != "renamed" {
+		t.Errorf("Wanted the default profile to be renamed, got %v", defaultProfile)
+	}
+	profiles, _ := conf.ListProfiles()
+	if len(profiles) != 1 || profiles[0] != "renamed" {
+		t.Errorf("Wanted the default profile setting not to be listed as a profile, got %v", profiles)
+	}
+	if err := conf.RemoveProfile("renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if defaultProfile, _ := conf.GetDefaultProfile(); defaultProfile != "" {
+		t.Errorf("Wanted no default profile after removing it, got %v", defaultProfile)
+	}
+}
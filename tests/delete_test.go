@@ -0,0 +1,216 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/g3cmd"
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
+	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+	"github.com/uc-cdis/gen3-client/gen3-client/mocks"
+)
+
+// deleteTestSetup points the logs to a temporary directory, answers the confirmation prompt with answer,
+// and captures what is printed on stdout until the returned function is called
+func deleteTestSetup(t *testing.T, answer string) (string, func() string) {
+	tempDir, err := ioutil.TempDir("", "gen3-client-delete")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs.MainLogPath = tempDir + commonUtils.PathSeparator
+	logs.ResetFileResults()
+
+	stdin, err := os.Create(filepath.Join(tempDir, "stdin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stdin.WriteString(answer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stdin.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := os.Create(filepath.Join(tempDir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	originalStdin, originalStdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdin, stdout
+
+	return tempDir, func() string {
+		os.Stdin, os.Stdout = originalStdin, originalStdout
+		stdin.Close()
+		stdout.Close()
+		printed, err := ioutil.ReadFile(stdout.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(printed)
+	}
+}
+
+// getFileResult returns the result recorded for a GUID
+func getFileResult(guid string) (logs.FileResult, bool) {
+	for _, result := range logs.GetFileResults() {
+		if result.GUID == guid {
+			return result, true
+		}
+	}
+	return logs.FileResult{}, false
+}
+
+// Expect a dry run to list the records that would be deleted, leaving out empty and repeated GUIDs, without deleting any
+func TestDeleteRecords_dryRun(t *testing.T) {
+	tempDir, restore := deleteTestSetup(t, "")
+	defer os.RemoveAll(tempDir)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+	client := gen3.NewClient(jwt.Credential{Profile: "test-profile", APIEndpoint: "https://data.mycommons.org"}, mockGen3Interface)
+	objects := []gen3.ManifestObject{
+		{ObjectID: "dry-run-guid-1", Filename: "first.txt"},
+		{ObjectID: ""},
+		{ObjectID: "dry-run-guid-2"},
+		{ObjectID: "dry-run-guid-1", Filename: "first.txt"},
+	}
+
+	err := g3cmd.DeleteRecords(context.Background(), client, objects, g3cmd.DeleteOptions{NumParallel: 2, DryRun: true})
+	printed := restore()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !strings.Contains(printed, "The following 2 record(s) and their stored files will be deleted from \"https://data.mycommons.org\"") {
+		t.Errorf("Wanted the 2 distinct records to be announced, got %q", printed)
+	}
+	if strings.Count(printed, "dry-run-guid-1 (first.txt)") != 1 || strings.Count(printed, "dry-run-guid-2") != 1 {
+		t.Errorf("Wanted each record to be listed once, got %q", printed)
+	}
+	for _, guid := range []string{"dry-run-guid-1", "dry-run-guid-2"} {
+		if result, present := getFileResult(guid); !present || result.Status != logs.StatusSkipped {
+			t.Errorf("Wanted record %s to be reported as skipped, got %+v", guid, result)
+		}
+	}
+	if deleteLogs, _ := filepath.Glob(filepath.Join(tempDir, "*_delete_log_*.json")); len(deleteLogs) != 0 {
+		t.Errorf("Wanted no delete log for a dry run, got %v", deleteLogs)
+	}
+}
+
+// Expect no record to be deleted when the user declines the confirmation prompt
+func TestDeleteRecords_declined(t *testing.T) {
+	tempDir, restore := deleteTestSetup(t, "n\n")
+	defer os.RemoveAll(tempDir)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+	client := gen3.NewClient(jwt.Credential{Profile: "test-profile"}, mockGen3Interface)
+
+	err := g3cmd.DeleteRecords(context.Background(), client, []gen3.ManifestObject{{ObjectID: "declined-guid"}}, g3cmd.DeleteOptions{NumParallel: 1})
+	restore()
+	if err != g3cmd.ErrUserAbort {
+		t.Errorf("Wanted the deletion to be aborted by the user, got %v", err)
+	}
+	if _, present := getFileResult("declined-guid"); present {
+		t.Error("Wanted no result for a record that hasn't been deleted")
+	}
+}
+
+// Expect the confirmed records to be deleted in parallel up to NumParallel at a time,
+// and the outcome of each GUID to be written to the delete log
+func TestDeleteRecords_confirmed(t *testing.T) {
+	tempDir, restore := deleteTestSetup(t, "y\n")
+	defer os.RemoveAll(tempDir)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+	var inFlight, maxInFlight int32
+	var lock sync.Mutex
+	deletedGUIDs := make(map[string]int)
+	mockGen3Interface.
+		EXPECT().
+		DeleteRecord(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, profileConfig *jwt.Credential, guid string) (string, error) {
+			current := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			lock.Lock()
+			deletedGUIDs[guid]++
+			if current > maxInFlight {
+				maxInFlight = current
+			}
+			lock.Unlock()
+			time.Sleep(50 * time.Millisecond)
+			if guid == "confirmed-guid-missing" {
+				return "", errors.New("404 Not found error has occurred! No record can be found for " + guid)
+			}
+			return "Record with GUID " + guid + " has been deleted", nil
+		}).
+		Times(6)
+	client := gen3.NewClient(jwt.Credential{Profile: "test-profile"}, mockGen3Interface)
+	objects := []gen3.ManifestObject{
+		{ObjectID: "confirmed-guid-1", Filename: "first.txt"},
+		{ObjectID: "confirmed-guid-2"},
+		{ObjectID: "confirmed-guid-missing"},
+		{ObjectID: "confirmed-guid-3"},
+		{ObjectID: "confirmed-guid-4"},
+		{ObjectID: "confirmed-guid-5"},
+		{ObjectID: "confirmed-guid-1", Filename: "first.txt"},
+	}
+
+	err := g3cmd.DeleteRecords(context.Background(), client, objects, g3cmd.DeleteOptions{NumParallel: 3})
+	restore()
+	var partialFailureError *gen3.PartialFailureError
+	if !errors.As(err, &partialFailureError) || partialFailureError.Failed != 1 || partialFailureError.Total != 6 {
+		t.Fatalf("Wanted 1 of 6 records to have failed, got %v", err)
+	}
+	if maxInFlight < 2 || maxInFlight > 3 {
+		t.Errorf("Wanted the records to be deleted in parallel, at most 3 at a time, got %d at a time", maxInFlight)
+	}
+	for guid, count := range deletedGUIDs {
+		if count != 1 {
+			t.Errorf("Wanted record %s to be deleted once, got %d", guid, count)
+		}
+	}
+
+	deleteLogs, _ := filepath.Glob(filepath.Join(tempDir, "test-profile_delete_log_*.json"))
+	if len(deleteLogs) != 1 {
+		t.Fatalf("Wanted a delete log, got %v", deleteLogs)
+	}
+	deleteLog, err := ioutil.ReadFile(deleteLogs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []commonUtils.DeleteResultObject
+	if err := json.Unmarshal(deleteLog, &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 6 {
+		t.Fatalf("Wanted the delete log to report each of the 6 records, got %+v", results)
+	}
+	for i, result := range results {
+		if result.GUID != objects[i].ObjectID {
+			t.Errorf("Wanted the delete log to follow the order of the manifest, got %s at %d", result.GUID, i)
+		}
+		wantDeleted := result.GUID != "confirmed-guid-missing"
+		if result.Deleted != wantDeleted || result.Message == "" {
+			t.Errorf("Wanted record %s to be reported with deleted=%t and a message, got %+v", result.GUID, wantDeleted, result)
+		}
+	}
+	if results[0].Filename != "first.txt" {
+		t.Errorf("Wanted the file name of the record to be reported, got %+v", results[0])
+	}
+}
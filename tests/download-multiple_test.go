@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -9,7 +10,7 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
-	g3cmd "github.com/uc-cdis/gen3-client/gen3-client/g3cmd"
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
 	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
 	"github.com/uc-cdis/gen3-client/gen3-client/mocks"
 )
@@ -47,16 +48,16 @@ func Test_askGen3ForFileInfo_withShepherd(t *testing.T) {
 	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
 	mockGen3Interface.
 		EXPECT().
-		CheckForShepherdAPI(gomock.AssignableToTypeOf(testProfileConfig)).
+		CheckForShepherdAPI(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig)).
 		Return(true, nil)
 	mockGen3Interface.
 		EXPECT().
-		GetResponse(gomock.AssignableToTypeOf(testProfileConfig), commonUtils.ShepherdEndpoint+"/objects/"+testGUID, "GET", "", nil).
+		GetResponse(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig), commonUtils.ShepherdEndpoint+"/objects/"+testGUID, "GET", "", nil).
 		Return("", &testResponse, nil)
 	// ----------
 
 	// Expect AskGen3ForFileInfo to return the correct filename and filesize from shepherd.
-	fileName, fileSize := g3cmd.AskGen3ForFileInfo(mockGen3Interface, testGUID, "", "", "original", true, &[]g3cmd.RenamedOrSkippedFileInfo{})
+	fileName, fileSize, _ := gen3.NewClient(jwt.Credential{}, mockGen3Interface).AskGen3ForFileInfo(context.Background(), testGUID, "", "", "original", true, &[]gen3.RenamedOrSkippedFileInfo{})
 	if fileName != testFileName {
 		t.Errorf("Wanted filename %v, got %v", testFileName, fileName)
 	}
@@ -81,18 +82,18 @@ func Test_askGen3ForFileInfo_withShepherd_shepherdError(t *testing.T) {
 	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
 	mockGen3Interface.
 		EXPECT().
-		CheckForShepherdAPI(gomock.AssignableToTypeOf(testProfileConfig)).
+		CheckForShepherdAPI(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig)).
 		Return(true, nil)
 	mockGen3Interface.
 		EXPECT().
-		GetResponse(gomock.AssignableToTypeOf(testProfileConfig), commonUtils.ShepherdEndpoint+"/objects/"+testGUID, "GET", "", nil).
+		GetResponse(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig), commonUtils.ShepherdEndpoint+"/objects/"+testGUID, "GET", "", nil).
 		Return("", nil, fmt.Errorf("Error getting metadata from Shepherd"))
 	// ----------
 
 	// Expect AskGen3ForFileInfo to add this file's GUID to the renamedOrSkippedFiles array.
-	skipped := []g3cmd.RenamedOrSkippedFileInfo{}
-	fileName, _ := g3cmd.AskGen3ForFileInfo(mockGen3Interface, testGUID, "", "", "original", true, &skipped)
-	expected := g3cmd.RenamedOrSkippedFileInfo{GUID: testGUID, OldFilename: "N/A", NewFilename: testGUID}
+	skipped := []gen3.RenamedOrSkippedFileInfo{}
+	fileName, _, _ := gen3.NewClient(jwt.Credential{}, mockGen3Interface).AskGen3ForFileInfo(context.Background(), testGUID, "", "", "original", true, &skipped)
+	expected := gen3.RenamedOrSkippedFileInfo{GUID: testGUID, OldFilename: "N/A", NewFilename: testGUID}
 	if skipped[0] != expected {
 		t.Errorf("Wanted skipped files list to contain %v, got %v", expected, skipped)
 	}
@@ -118,16 +119,16 @@ func Test_askGen3ForFileInfo_noShepherd(t *testing.T) {
 	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
 	mockGen3Interface.
 		EXPECT().
-		CheckForShepherdAPI(gomock.AssignableToTypeOf(testProfileConfig)).
+		CheckForShepherdAPI(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig)).
 		Return(false, nil)
 	mockGen3Interface.
 		EXPECT().
-		DoRequestWithSignedHeader(gomock.AssignableToTypeOf(testProfileConfig), commonUtils.IndexdIndexEndpoint+"/"+testGUID, "", nil).
+		DoRequestWithSignedHeader(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig), commonUtils.IndexdIndexEndpoint+"/"+testGUID, "", nil).
 		Return(jwt.JsonMessage{FileName: testFileName, Size: testFileSize}, nil)
 	// ----------
 
 	// Expect AskGen3ForFileInfo to return the correct filename and filesize from indexd.
-	fileName, fileSize := g3cmd.AskGen3ForFileInfo(mockGen3Interface, testGUID, "", "", "original", true, &[]g3cmd.RenamedOrSkippedFileInfo{})
+	fileName, fileSize, _ := gen3.NewClient(jwt.Credential{}, mockGen3Interface).AskGen3ForFileInfo(context.Background(), testGUID, "", "", "original", true, &[]gen3.RenamedOrSkippedFileInfo{})
 	if fileName != testFileName {
 		t.Errorf("Wanted filename %v, got %v", testFileName, fileName)
 	}
@@ -152,18 +153,18 @@ func Test_askGen3ForFileInfo_noShepherd_indexdError(t *testing.T) {
 	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
 	mockGen3Interface.
 		EXPECT().
-		CheckForShepherdAPI(gomock.AssignableToTypeOf(testProfileConfig)).
+		CheckForShepherdAPI(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig)).
 		Return(false, nil)
 	mockGen3Interface.
 		EXPECT().
-		DoRequestWithSignedHeader(gomock.AssignableToTypeOf(testProfileConfig), commonUtils.IndexdIndexEndpoint+"/"+testGUID, "", nil).
+		DoRequestWithSignedHeader(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig), commonUtils.IndexdIndexEndpoint+"/"+testGUID, "", nil).
 		Return(jwt.JsonMessage{}, fmt.Errorf("Error downloading file from Indexd"))
 	// ----------
 
 	// Expect AskGen3ForFileInfo to add this file's GUID to the renamedOrSkippedFiles array.
-	skipped := []g3cmd.RenamedOrSkippedFileInfo{}
-	fileName, _ := g3cmd.AskGen3ForFileInfo(mockGen3Interface, testGUID, "", "", "original", true, &skipped)
-	expected := g3cmd.RenamedOrSkippedFileInfo{GUID: testGUID, OldFilename: "N/A", NewFilename: testGUID}
+	skipped := []gen3.RenamedOrSkippedFileInfo{}
+	fileName, _, _ := gen3.NewClient(jwt.Credential{}, mockGen3Interface).AskGen3ForFileInfo(context.Background(), testGUID, "", "", "original", true, &skipped)
+	expected := gen3.RenamedOrSkippedFileInfo{GUID: testGUID, OldFilename: "N/A", NewFilename: testGUID}
 	if skipped[0] != expected {
 		t.Errorf("Wanted skipped files list to contain %v, got %v", expected, skipped)
 	}
@@ -172,3 +173,110 @@ func Test_askGen3ForFileInfo_noShepherd_indexdError(t *testing.T) {
 		t.Errorf("Wanted filename %v, got %v", testGUID, fileName)
 	}
 }
+
+// Expect AskGen3ForFileInfo to return the hashes from the Indexd record so
+// that downloaded files can be verified.
+func Test_askGen3ForFileInfo_noShepherd_hashes(t *testing.T) {
+	// -- SETUP --
+	testGUID := "000000-0000000-0000000-000000"
+	testProfileConfig := &jwt.Credential{
+		Profile: "test-profile",
+	}
+	testHashes := map[string]string{"md5": "d41d8cd98f00b204e9800998ecf8427e"}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+	mockGen3Interface.
+		EXPECT().
+		CheckForShepherdAPI(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig)).
+		Return(false, nil)
+	mockGen3Interface.
+		EXPECT().
+		DoRequestWithSignedHeader(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig), commonUtils.IndexdIndexEndpoint+"/"+testGUID, "", nil).
+		Return(jwt.JsonMessage{FileName: "test-file", Size: 0, Hashes: testHashes}, nil)
+	// ----------
+
+	_, _, hashes := gen3.NewClient(jwt.Credential{}, mockGen3Interface).AskGen3ForFileInfo(context.Background(), testGUID, "", "", "guid", false, &[]gen3.RenamedOrSkippedFileInfo{})
+	if hashes["md5"] != testHashes["md5"] {
+		t.Errorf("Wanted md5 hash %v, got %v", testHashes["md5"], hashes["md5"])
+	}
+
+	// The md5 of empty content should verify against the record, and any other content should not.
+	hashWriter := commonUtils.NewHashWriter(commonUtils.VerifiableHashAlgorithms(hashes)...)
+	if err := commonUtils.VerifyHashes(hashes, hashWriter.Sums()); err != nil {
+		t.Errorf("Wanted checksum of empty content to match, got error %v", err)
+	}
+	hashWriter.Write([]byte("corrupted")) // nolint: errcheck
+	if err := commonUtils.VerifyHashes(hashes, hashWriter.Sums()); err == nil {
+		t.Error("Wanted checksum mismatch error for corrupted content, got nil")
+	}
+}
+
+// Expect the file records of multiple GUIDs to be retrieved with a single bulk request to Indexd.
+func TestGetFileRecords(t *testing.T) {
+	// -- SETUP --
+	testGUIDs := []string{"000000-0000000-0000000-000000", "111111-1111111-1111111-111111"}
+	testProfileConfig := &jwt.Credential{
+		Profile: "test-profile",
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Mock the bulk request to Indexd, in which only the first GUID is found.
+	bulkResponseBody := fmt.Sprintf(`[{
+		"did": "%v",
+		"file_name": "test-file",
+		"size": 120,
+		"urls": ["s3://bucket/test-file"],
+		"hashes": {"md5": "d41d8cd98f00b204e9800998ecf8427e"}
+	}]`, testGUIDs[0])
+	mockBulkResponse := http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(strings.NewReader(bulkResponseBody)),
+	}
+	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+	mockGen3Interface.
+		EXPECT().
+		GetResponse(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig), commonUtils.IndexdBulkDocumentsEndpoint, "POST", "application/json", []byte(`["000000-0000000-0000000-000000","111111-1111111-1111111-111111"]`)).
+		Return("", &mockBulkResponse, nil)
+	// ----------
+
+	records, err := gen3.NewClient(jwt.Credential{}, mockGen3Interface).GetFileRecords(context.Background(), testGUIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Wanted 1 file record, got %d", len(records))
+	}
+	record := records[testGUIDs[0]]
+	if record.FileName != "test-file" || record.Size != 120 || record.Hashes["md5"] != "d41d8cd98f00b204e9800998ecf8427e" {
+		t.Errorf("Wanted file name, size and hashes to be read from the bulk response, got %v", record)
+	}
+}
+
+// If the bulk request fails, expect an error so the file records can be looked up one GUID at a time instead.
+func TestGetFileRecords_bulkError(t *testing.T) {
+	// -- SETUP --
+	testProfileConfig := &jwt.Credential{
+		Profile: "test-profile",
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockErrorResponse := http.Response{
+		StatusCode: 404,
+		Body:       ioutil.NopCloser(strings.NewReader("Not Found")),
+	}
+	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+	mockGen3Interface.
+		EXPECT().
+		GetResponse(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig), commonUtils.IndexdBulkDocumentsEndpoint, "POST", "application/json", gomock.Any()).
+		Return("", &mockErrorResponse, nil)
+	// ----------
+
+	_, err := gen3.NewClient(jwt.Credential{}, mockGen3Interface).GetFileRecords(context.Background(), []string{"000000-0000000-0000000-000000"})
+	if err == nil {
+		t.Error("Wanted an error for a failed bulk request")
+	}
+}
@@ -0,0 +1,397 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
+	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+	"github.com/uc-cdis/gen3-client/gen3-client/mocks"
+)
+
+// maxBulkLookupSize is the largest number of GUIDs the download pipeline looks up in a single bulk request
+const maxBulkLookupSize = 200
+
+// pipelineTestGUIDs returns count GUIDs of the same length
+func pipelineTestGUIDs(count int) []string {
+	guids := make([]string, count)
+	for i := range guids {
+		guids[i] = fmt.Sprintf("pipeline-guid-%06d", i)
+	}
+	return guids
+}
+
+// pipelineTestSetup creates a download directory, and a storage server that serves "content of <guid>" for each GUID.
+// The requests to the storage wait for handler, if it isn't nil, to return before being answered.
+func pipelineTestSetup(t *testing.T, handler func(guid string)) (string, *httptest.Server) {
+	tempDir, err := ioutil.TempDir("", "gen3-client-download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs.MainLogPath = tempDir + commonUtils.PathSeparator
+	logs.ResetFileResults()
+	logs.InitFailedDownloadLog("test-profile")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		guid := strings.TrimPrefix(r.URL.Path, "/")
+		if handler != nil {
+			handler(guid)
+		}
+		fmt.Fprint(w, "content of "+guid)
+	}))
+	return tempDir, server
+}
+
+// expectDownloadURLs makes the mock hand out the URL of each GUID on the storage server, and make real requests to it
+func expectDownloadURLs(t *testing.T, mockGen3Interface *mocks.MockGen3Interface, serverURL string) {
+	mockGen3Interface.
+		EXPECT().
+		CheckForShepherdAPI(gomock.Any(), gomock.Any()).
+		Return(false, nil).
+		AnyTimes()
+	mockGen3Interface.
+		EXPECT().
+		DoRequestWithSignedHeader(gomock.Any(), gomock.Any(), gomock.Any(), "", nil).
+		DoAndReturn(func(ctx context.Context, profileConfig *jwt.Credential, endpointPostPrefix string, contentType string, bodyBytes []byte) (jwt.JsonMessage, error) {
+			if !strings.HasPrefix(endpointPostPrefix, commonUtils.FenceDataDownloadEndpoint+"/") {
+				t.Errorf("Unexpected request to %s", endpointPostPrefix)
+				return jwt.JsonMessage{}, fmt.Errorf("unexpected request to %s", endpointPostPrefix)
+			}
+			return jwt.JsonMessage{URL: serverURL + "/" + strings.TrimPrefix(endpointPostPrefix, commonUtils.FenceDataDownloadEndpoint+"/")}, nil
+		}).
+		AnyTimes()
+	mockGen3Interface.
+		EXPECT().
+		MakeARequest(gomock.Any(), gomock.Any(), gomock.Any(), "", "", gomock.Any(), nil, gomock.Any()).
+		DoAndReturn((&jwt.Request{}).MakeARequest).
+		AnyTimes()
+}
+
+// bulkLookupResponse answers a bulk lookup of the GUIDs of bodyBytes with a record named "<guid>.txt" for each of them
+func bulkLookupResponse(bodyBytes []byte) (*http.Response, []string, error) {
+	var guids []string
+	if err := json.Unmarshal(bodyBytes, &guids); err != nil {
+		return nil, nil, err
+	}
+	records := make([]map[string]interface{}, 0, len(guids))
+	for _, guid := range guids {
+		records = append(records, map[string]interface{}{"did": guid, "file_name": guid + ".txt", "size": len("content of " + guid)})
+	}
+	recordsBytes, _ := json.Marshal(records)
+	return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(string(recordsBytes)))}, guids, nil
+}
+
+// Expect the file records of a manifest without file info to be looked up in bulk, in batches of up to 200 GUIDs,
+// each GUID being looked up once
+func TestDownload_batchedLookup(t *testing.T) {
+	tempDir, server := pipelineTestSetup(t, nil)
+	defer os.RemoveAll(tempDir)
+	defer server.Close()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+	expectDownloadURLs(t, mockGen3Interface, server.URL)
+	var lock sync.Mutex
+	lookedUp := make(map[string]int)
+	batches := 0
+	mockGen3Interface.
+		EXPECT().
+		GetResponse(gomock.Any(), gomock.Any(), commonUtils.IndexdBulkDocumentsEndpoint, "POST", "application/json", gomock.Any()).
+		DoAndReturn(func(ctx context.Context, profileConfig *jwt.Credential, endpointPostPrefix string, method string, contentType string, bodyBytes []byte) (string, *http.Response, error) {
+			resp, guids, err := bulkLookupResponse(bodyBytes)
+			if err != nil {
+				t.Error(err)
+				return "", nil, err
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			batches++
+			if len(guids) > maxBulkLookupSize {
+				t.Errorf("Wanted at most %d GUIDs per bulk lookup, got %d", maxBulkLookupSize, len(guids))
+			}
+			for _, guid := range guids {
+				lookedUp[guid]++
+			}
+			return "", resp, nil
+		}).
+		AnyTimes()
+
+	guids := pipelineTestGUIDs(450)
+	manifestReader, err := gen3.NewManifestReader(strings.NewReader(strings.Join(guids, "\n")), gen3.ManifestFormatGUIDList, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := gen3.NewClient(jwt.Credential{}, mockGen3Interface)
+	options := gen3.DownloadOptions{DownloadPath: tempDir, FilenameFormat: "original", NumParallel: 4, Segments: 1}
+	if err := client.Download(context.Background(), manifestReader, options); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if batches < 3 {
+		t.Errorf("Wanted 450 GUIDs to take at least 3 bulk lookups, got %d", batches)
+	}
+	for _, guid := range guids {
+		if lookedUp[guid] != 1 {
+			t.Errorf("Wanted %s to be looked up once, got %d", guid, lookedUp[guid])
+		}
+		downloaded, err := ioutil.ReadFile(tempDir + commonUtils.PathSeparator + guid + ".txt")
+		if err != nil || string(downloaded) != "content of "+guid {
+			t.Errorf("Wanted %s to be downloaded under the file name of its record, got %q, %v", guid, downloaded, err)
+		}
+	}
+}
+
+// Expect the file records to be looked up one GUID at a time once the bulk lookup has failed,
+// without the bulk lookup being tried for every batch
+func TestDownload_bulkLookupFallback(t *testing.T) {
+	tempDir, server := pipelineTestSetup(t, nil)
+	defer os.RemoveAll(tempDir)
+	defer server.Close()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+	guids := pipelineTestGUIDs(1000)
+	// the bulk lookups already in flight when the first one fails may fail too, one per lookup worker at most
+	mockGen3Interface.
+		EXPECT().
+		GetResponse(gomock.Any(), gomock.Any(), commonUtils.IndexdBulkDocumentsEndpoint, "POST", "application/json", gomock.Any()).
+		DoAndReturn(func(ctx context.Context, profileConfig *jwt.Credential, endpointPostPrefix string, method string, contentType string, bodyBytes []byte) (string, *http.Response, error) {
+			return "", &http.Response{StatusCode: 404, Body: ioutil.NopCloser(strings.NewReader("Not Found"))}, nil
+		}).
+		MinTimes(1).
+		MaxTimes(4)
+	for _, guid := range guids {
+		mockGen3Interface.
+			EXPECT().
+			DoRequestWithSignedHeader(gomock.Any(), gomock.Any(), commonUtils.IndexdIndexEndpoint+"/"+guid, "", nil).
+			Return(jwt.JsonMessage{FileName: guid + ".txt", Size: int64(len("content of " + guid))}, nil)
+	}
+	expectDownloadURLs(t, mockGen3Interface, server.URL)
+
+	manifestReader, err := gen3.NewManifestReader(strings.NewReader(strings.Join(guids, "\n")), gen3.ManifestFormatGUIDList, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := gen3.NewClient(jwt.Credential{}, mockGen3Interface)
+	options := gen3.DownloadOptions{DownloadPath: tempDir, FilenameFormat: "original", NumParallel: 4, Segments: 1}
+	if err := client.Download(context.Background(), manifestReader, options); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, guid := range guids {
+		if _, err := os.Stat(tempDir + commonUtils.PathSeparator + guid + ".txt"); err != nil {
+			t.Errorf("Wanted %s to be downloaded under the file name of its record, got %v", guid, err)
+		}
+	}
+}
+
+// countingReader counts the bytes read from a manifest
+type countingReader struct {
+	reader io.Reader
+	read   int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.reader.Read(p)
+	atomic.AddInt64(&cr.read, int64(n))
+	return n, err
+}
+
+// Expect the downloads to start before the whole manifest has been read, and the manifest to be read only so far
+// ahead of the downloads, as the stages of the pipeline are connected by bounded channels
+func TestDownload_boundedReadAhead(t *testing.T) {
+	firstRequest := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	tempDir, server := pipelineTestSetup(t, func(guid string) {
+		once.Do(func() { close(firstRequest) })
+		<-release
+	})
+	defer os.RemoveAll(tempDir)
+	defer server.Close()
+	defer server.CloseClientConnections()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+	expectDownloadURLs(t, mockGen3Interface, server.URL)
+	mockGen3Interface.
+		EXPECT().
+		GetResponse(gomock.Any(), gomock.Any(), commonUtils.IndexdBulkDocumentsEndpoint, "POST", "application/json", gomock.Any()).
+		DoAndReturn(func(ctx context.Context, profileConfig *jwt.Credential, endpointPostPrefix string, method string, contentType string, bodyBytes []byte) (string, *http.Response, error) {
+			resp, _, err := bulkLookupResponse(bodyBytes)
+			return "", resp, err
+		}).
+		AnyTimes()
+
+	guids := pipelineTestGUIDs(20000)
+	lineLength := int64(len(guids[0]) + 1)
+	manifest := &countingReader{reader: strings.NewReader(strings.Join(guids, "\n"))}
+	manifestReader, err := gen3.NewManifestReader(manifest, gen3.ManifestFormatGUIDList, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := gen3.NewClient(jwt.Credential{}, mockGen3Interface)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		options := gen3.DownloadOptions{DownloadPath: tempDir, FilenameFormat: "guid", NumParallel: 2, Segments: 1}
+		done <- client.Download(ctx, manifestReader, options)
+	}()
+
+	select {
+	case <-firstRequest:
+	case err := <-done:
+		t.Fatalf("Wanted the download to wait for the storage, got %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Wanted the first file to be downloaded before the manifest has been read")
+	}
+	// let the pipeline fill up while the downloads are stalled
+	time.Sleep(500 * time.Millisecond)
+	readObjects := atomic.LoadInt64(&manifest.read) / lineLength
+	if readObjects >= int64(len(guids))/2 {
+		t.Errorf("Wanted the manifest to be read only so far ahead of the stalled downloads, %d of %d objects have been read", readObjects, len(guids))
+	}
+
+	cancel()
+	close(release)
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Wanted the download to be cancelled, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Wanted the cancelled download to return")
+	}
+}
+
+// Expect the other files to keep being downloaded while the download of a large file is in progress,
+// as each transfer worker picks up the next file as soon as it's done with its own
+func TestDownload_noBatchBarrier(t *testing.T) {
+	guids := pipelineTestGUIDs(12)
+	othersRequested := make(chan struct{})
+	release := make(chan struct{})
+	var lock sync.Mutex
+	requested := 0
+	tempDir, server := pipelineTestSetup(t, func(guid string) {
+		if guid == guids[0] {
+			<-release
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		requested++
+		if requested == len(guids)-1 {
+			close(othersRequested)
+		}
+	})
+	defer os.RemoveAll(tempDir)
+	defer server.Close()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+	expectDownloadURLs(t, mockGen3Interface, server.URL)
+	mockGen3Interface.
+		EXPECT().
+		GetResponse(gomock.Any(), gomock.Any(), commonUtils.IndexdBulkDocumentsEndpoint, "POST", "application/json", gomock.Any()).
+		DoAndReturn(func(ctx context.Context, profileConfig *jwt.Credential, endpointPostPrefix string, method string, contentType string, bodyBytes []byte) (string, *http.Response, error) {
+			resp, _, err := bulkLookupResponse(bodyBytes)
+			return "", resp, err
+		}).
+		AnyTimes()
+
+	manifestReader, err := gen3.NewManifestReader(strings.NewReader(strings.Join(guids, "\n")), gen3.ManifestFormatGUIDList, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := gen3.NewClient(jwt.Credential{}, mockGen3Interface)
+	done := make(chan error)
+	go func() {
+		options := gen3.DownloadOptions{DownloadPath: tempDir, FilenameFormat: "original", NumParallel: 2, Segments: 1}
+		done <- client.Download(context.Background(), manifestReader, options)
+	}()
+
+	select {
+	case <-othersRequested:
+	case <-time.After(10 * time.Second):
+		t.Error("Wanted the other files to be downloaded while the first one is stalled")
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, guid := range guids {
+		downloaded, err := ioutil.ReadFile(tempDir + commonUtils.PathSeparator + guid + ".txt")
+		if err != nil || string(downloaded) != "content of "+guid {
+			t.Errorf("Wanted %s to be downloaded, got %q, %v", guid, downloaded, err)
+		}
+	}
+}
+
+// Expect a downloaded file whose checksum doesn't match the md5 of its Indexd record to be recorded in the failed download
+// log, and to be deleted only if DeleteCorrupted is set
+func TestDownload_corruptedFile(t *testing.T) {
+	for _, deleteCorrupted := range []bool{false, true} {
+		tempDir, server := pipelineTestSetup(t, nil)
+		defer os.RemoveAll(tempDir)
+		defer server.Close()
+
+		mockCtrl := gomock.NewController(t)
+		mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+		expectDownloadURLs(t, mockGen3Interface, server.URL)
+		guid := "corrupted-guid-000001"
+		mockGen3Interface.
+			EXPECT().
+			GetResponse(gomock.Any(), gomock.Any(), commonUtils.IndexdBulkDocumentsEndpoint, "POST", "application/json", gomock.Any()).
+			DoAndReturn(func(ctx context.Context, profileConfig *jwt.Credential, endpointPostPrefix string, method string, contentType string, bodyBytes []byte) (string, *http.Response, error) {
+				// the md5 of the record is the one of an empty file, not the one of the served content
+				records := fmt.Sprintf(`[{"did": %q, "file_name": %q, "size": %d, "hashes": {"md5": "d41d8cd98f00b204e9800998ecf8427e"}}]`, guid, guid+".txt", len("content of "+guid))
+				return "", &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(records))}, nil
+			}).
+			AnyTimes()
+
+		manifestReader, err := gen3.NewManifestReader(strings.NewReader(guid), gen3.ManifestFormatGUIDList, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := gen3.NewClient(jwt.Credential{}, mockGen3Interface)
+		options := gen3.DownloadOptions{DownloadPath: tempDir, FilenameFormat: "original", NumParallel: 1, Segments: 1, VerifyChecksum: true, DeleteCorrupted: deleteCorrupted}
+		if err := client.Download(context.Background(), manifestReader, options); err == nil {
+			t.Error("Wanted an error for the corrupted file, got nil")
+		}
+
+		failed := false
+		for _, ro := range logs.GetFailedDownloadLogMap() {
+			if ro.GUID == guid && strings.Contains(ro.Error, "Checksum verification failed") {
+				failed = true
+			}
+		}
+		if !failed {
+			t.Errorf("Wanted the corrupted file to be recorded as failed in the failed download log, got %v", logs.GetFailedDownloadLogMap())
+		}
+		_, err = os.Stat(tempDir + commonUtils.PathSeparator + guid + ".txt")
+		if deleteCorrupted && !os.IsNotExist(err) {
+			t.Errorf("Wanted the corrupted file to be deleted with DeleteCorrupted, got %v", err)
+		}
+		if !deleteCorrupted && err != nil {
+			t.Errorf("Wanted the corrupted file to be kept without DeleteCorrupted, got %v", err)
+		}
+		mockCtrl.Finish()
+	}
+}
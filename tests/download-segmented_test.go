@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
+	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+	"github.com/uc-cdis/gen3-client/gen3-client/mocks"
+)
+
+const segmentedTestGUID = "000000-0000000-0000000-000000"
+
+// newSegmentedDownloadClient returns a client that gets serverURL as the presigned URL of segmentedTestGUID from Fence,
+// and makes its requests to the storage with a real http client
+func newSegmentedDownloadClient(mockCtrl *gomock.Controller, serverURL string) *gen3.Client {
+	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+	mockGen3Interface.
+		EXPECT().
+		CheckForShepherdAPI(gomock.Any(), gomock.Any()).
+		Return(false, nil).
+		AnyTimes()
+	mockGen3Interface.
+		EXPECT().
+		DoRequestWithSignedHeader(gomock.Any(), gomock.Any(), commonUtils.FenceDataDownloadEndpoint+"/"+segmentedTestGUID, "", nil).
+		Return(jwt.JsonMessage{URL: serverURL}, nil).
+		AnyTimes()
+	mockGen3Interface.
+		EXPECT().
+		MakeARequest(gomock.Any(), gomock.Any(), serverURL, "", "", gomock.Any(), nil, gomock.Any()).
+		DoAndReturn((&jwt.Request{}).MakeARequest).
+		AnyTimes()
+	return gen3.NewClient(jwt.Credential{}, mockGen3Interface)
+}
+
+// segmentedTestSetup creates a download directory and a failed download log entry for a 40MB object
+func segmentedTestSetup(t *testing.T) (string, []byte, map[string]commonUtils.DownloadRetryObject) {
+	tempDir, err := ioutil.TempDir("", "gen3-client-download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs.MainLogPath = tempDir + commonUtils.PathSeparator
+	logs.ResetFileResults()
+	logs.InitFailedDownloadLog("test-profile")
+	downloadPath := tempDir + commonUtils.PathSeparator
+	content := bytes.Repeat([]byte("0123456789abcdef"), 40*int(gen3.MB)/16)
+	failedDownloadLogMap := map[string]commonUtils.DownloadRetryObject{
+		downloadPath + "large.bam": {GUID: segmentedTestGUID, DownloadPath: downloadPath, Filename: "large.bam", FileSize: int64(len(content))},
+	}
+	return downloadPath, content, failedDownloadLogMap
+}
+
+// Expect a large object to be downloaded in segments into a partial file, which is renamed once all segments have completed
+func TestDownloadSegments(t *testing.T) {
+	downloadPath, content, failedDownloadLogMap := segmentedTestSetup(t)
+	defer os.RemoveAll(downloadPath)
+
+	var rangedRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Range"), "bytes=") && r.Header.Get("Range") != "bytes=0-0" {
+			atomic.AddInt32(&rangedRequests, 1)
+		}
+		http.ServeContent(w, r, "large.bam", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	client := newSegmentedDownloadClient(mockCtrl, server.URL)
+	err := client.RetryDownload(context.Background(), failedDownloadLogMap, gen3.DownloadOptions{NumParallel: 1, Segments: 4})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if rangedRequests := atomic.LoadInt32(&rangedRequests); rangedRequests != 2 {
+		t.Errorf("Wanted the 40MB object to be downloaded in 2 segments of at least 16MB, got %d ranged requests", rangedRequests)
+	}
+	downloaded, err := ioutil.ReadFile(downloadPath + "large.bam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, content) {
+		t.Error("Wanted the segments to be reassembled into the content of the object")
+	}
+	if _, err := os.Stat(downloadPath + "large.bam.partial"); !os.IsNotExist(err) {
+		t.Errorf("Wanted the partial file to be gone once renamed, got %v", err)
+	}
+}
+
+// Expect an interrupted segmented download to keep its partial file and the progress of its segments,
+// and a later download to fetch only the bytes of each segment that are still missing
+func TestDownloadSegments_resumeInterrupted(t *testing.T) {
+	downloadPath, content, failedDownloadLogMap := segmentedTestSetup(t)
+	defer os.RemoveAll(downloadPath)
+	if err := logs.InitPartialDownloadStateLog("test-profile"); err != nil {
+		t.Fatal(err)
+	}
+	filePath := downloadPath + "large.bam"
+	const written = 1024 * 1024
+
+	// the storage sends the first MB of each segment, and then stalls until the download is cancelled
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, end int
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err != nil || end == 0 {
+			http.ServeContent(w, r, "large.bam", time.Time{}, bytes.NewReader(content))
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+		w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(content[start : start+written]) //nolint:errcheck
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	client := newSegmentedDownloadClient(mockCtrl, server.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer cancel()
+		for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if state, present := logs.GetPartialDownloadState(filePath); present && state.Segments[0].Written == written && state.Segments[1].Written == written {
+				return
+			}
+		}
+		t.Error("Wanted the progress of the segments to be recorded")
+	}()
+	err := client.RetryDownload(ctx, failedDownloadLogMap, gen3.DownloadOptions{NumParallel: 1, Segments: 4})
+	server.Close()
+	if err != context.Canceled {
+		t.Fatalf("Wanted the download to be cancelled, got %v", err)
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Errorf("Wanted no \"large.bam\" to be left behind by the interrupted download, got %v", err)
+	}
+	if _, err := os.Stat(filePath + ".partial"); err != nil {
+		t.Errorf("Wanted the partial file to be kept for a retry, got %v", err)
+	}
+	if _, present := logs.GetFailedDownloadLogMap()[filePath]; !present {
+		t.Error("Wanted the interrupted download to be kept in the failed download log")
+	}
+
+	// the progress of the segments is read back from the state file, like a later run would
+	if err := logs.InitPartialDownloadStateLog("test-profile"); err != nil {
+		t.Fatal(err)
+	}
+	var lock sync.Mutex
+	ranges := make([]string, 0)
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "bytes=0-0" {
+			lock.Lock()
+			ranges = append(ranges, r.Header.Get("Range"))
+			lock.Unlock()
+		}
+		http.ServeContent(w, r, "large.bam", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+	client = newSegmentedDownloadClient(mockCtrl, server.URL)
+	err = client.RetryDownload(context.Background(), failedDownloadLogMap, gen3.DownloadOptions{NumParallel: 1, Segments: 4})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	segmentSize := len(content) / 2
+	sort.Strings(ranges)
+	wantRanges := []string{fmt.Sprintf("bytes=%d-%d", written, segmentSize-1), fmt.Sprintf("bytes=%d-%d", segmentSize+written, len(content)-1)}
+	if !reflect.DeepEqual(ranges, wantRanges) {
+		t.Errorf("Wanted only the missing bytes of each segment to be downloaded with %v, got %v", wantRanges, ranges)
+	}
+	downloaded, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, content) {
+		t.Error("Wanted the resumed segments to be reassembled into the content of the object")
+	}
+	if _, err := os.Stat(filePath + ".partial"); !os.IsNotExist(err) {
+		t.Errorf("Wanted the partial file to be gone once renamed, got %v", err)
+	}
+	if _, present := logs.GetPartialDownloadState(filePath); present {
+		t.Error("Wanted the progress of the completed download to be forgotten")
+	}
+}
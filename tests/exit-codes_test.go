@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/g3cmd"
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
+	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
+)
+
+// Expect each kind of error returned by a command to map to its documented exit code, also when wrapped.
+func TestExitCode(t *testing.T) {
+	testCases := []struct {
+		err  error
+		want int
+	}{
+		{nil, g3cmd.ExitSuccess},
+		{errors.New("some error"), g3cmd.ExitError},
+		{&gen3.PartialFailureError{Failed: 1, Total: 3}, g3cmd.ExitPartialFailure},
+		{&jwt.AuthError{Err: errors.New("401")}, g3cmd.ExitAuthFailure},
+		{fmt.Errorf("Fatal authentication error: %w", &jwt.AuthError{Err: errors.New("401")}), g3cmd.ExitAuthFailure},
+		{&jwt.ConfigError{Err: errors.New("missing profile")}, g3cmd.ExitConfigError},
+		{g3cmd.ErrUserAbort, g3cmd.ExitUserAbort},
+		{context.Canceled, g3cmd.ExitInterrupted},
+		{fmt.Errorf("Error occurred when uploading: %w", context.Canceled), g3cmd.ExitInterrupted},
+	}
+	for _, testCase := range testCases {
+		if got := g3cmd.ExitCode(testCase.err); got != testCase.want {
+			t.Errorf("Wanted exit code %d for error %v, got %d", testCase.want, testCase.err, got)
+		}
+	}
+}
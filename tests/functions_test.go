@@ -2,12 +2,18 @@ package tests
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
@@ -24,7 +30,7 @@ func TestDoRequestWithSignedHeaderNoProfile(t *testing.T) {
 
 	profileConfig := jwt.Credential{KeyId: "", APIKey: "", AccessToken: "", APIEndpoint: ""}
 
-	_, err := testFunction.DoRequestWithSignedHeader(&profileConfig, "/user/data/download/test_uuid", "", nil)
+	_, err := testFunction.DoRequestWithSignedHeader(context.Background(), &profileConfig, "/user/data/download/test_uuid", "", nil)
 
 	if err == nil {
 		t.Fail()
@@ -45,9 +51,9 @@ func TestDoRequestWithSignedHeaderGoodToken(t *testing.T) {
 		StatusCode: 200,
 	}
 
-	mockRequest.EXPECT().MakeARequest("GET", "http://www.test.com/user/data/download/test_uuid", "non_expired_token", "", gomock.Any(), gomock.Any(), false).Return(mockedResp, nil).Times(1)
+	mockRequest.EXPECT().MakeARequest(gomock.Any(), "GET", "http://www.test.com/user/data/download/test_uuid", "non_expired_token", "", gomock.Any(), gomock.Any(), false).Return(mockedResp, nil).Times(1)
 
-	_, err := testFunction.DoRequestWithSignedHeader(&profileConfig, "/user/data/download/test_uuid", "", nil)
+	_, err := testFunction.DoRequestWithSignedHeader(context.Background(), &profileConfig, "/user/data/download/test_uuid", "", nil)
 
 	if err != nil {
 		t.Fail()
@@ -69,11 +75,11 @@ func TestDoRequestWithSignedHeaderCreateNewToken(t *testing.T) {
 		StatusCode: 200,
 	}
 
-	mockConfig.EXPECT().UpdateConfigFile(profileConfig).Times(1)
-	mockRequest.EXPECT().RequestNewAccessToken("http://www.test.com/user/credentials/api/access_token", &profileConfig).Return(nil).Times(1)
-	mockRequest.EXPECT().MakeARequest(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), false).Return(mockedResp, nil).Times(1)
+	mockConfig.EXPECT().UpdateAccessToken(profileConfig).Times(1)
+	mockRequest.EXPECT().RequestNewAccessToken(gomock.Any(), "http://www.test.com/user/credentials/api/access_token", &profileConfig).Return(nil).Times(1)
+	mockRequest.EXPECT().MakeARequest(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), false).Return(mockedResp, nil).Times(1)
 
-	_, err := testFunction.DoRequestWithSignedHeader(&profileConfig, "/user/data/download/test_uuid", "", nil)
+	_, err := testFunction.DoRequestWithSignedHeader(context.Background(), &profileConfig, "/user/data/download/test_uuid", "", nil)
 
 	if err != nil {
 		t.Fail()
@@ -95,11 +101,11 @@ func TestDoRequestWithSignedHeaderRefreshToken(t *testing.T) {
 		StatusCode: 401,
 	}
 
-	mockConfig.EXPECT().UpdateConfigFile(profileConfig).Times(1)
-	mockRequest.EXPECT().RequestNewAccessToken("http://www.test.com/user/credentials/api/access_token", &profileConfig).Return(nil).Times(1)
-	mockRequest.EXPECT().MakeARequest(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), false).Return(mockedResp, nil).Times(2)
+	mockConfig.EXPECT().UpdateAccessToken(profileConfig).Times(1)
+	mockRequest.EXPECT().RequestNewAccessToken(gomock.Any(), "http://www.test.com/user/credentials/api/access_token", &profileConfig).Return(nil).Times(1)
+	mockRequest.EXPECT().MakeARequest(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), false).Return(mockedResp, nil).Times(2)
 
-	_, err := testFunction.DoRequestWithSignedHeader(&profileConfig, "/user/data/download/test_uuid", "", nil)
+	_, err := testFunction.DoRequestWithSignedHeader(context.Background(), &profileConfig, "/user/data/download/test_uuid", "", nil)
 
 	if err != nil && !strings.Contains(err.Error(), "401") {
 		t.Fail()
@@ -107,6 +113,146 @@ func TestDoRequestWithSignedHeaderRefreshToken(t *testing.T) {
 
 }
 
+// makeTestToken returns an unsigned JWT that expires at the given time
+func makeTestToken(exp time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("{\"exp\": %d}", exp.Unix())))
+	return "eyJhbGciOiJub25lIn0." + payload + ".signature"
+}
+
+// Expect a token about to expire to be refreshed once before the requests, shared by concurrent callers.
+func TestDoRequestWithSignedHeaderProactiveRefresh(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockConfig := mocks.NewMockConfigureInterface(mockCtrl)
+	mockRequest := mocks.NewMockRequestInterface(mockCtrl)
+	testFunction := &jwt.Functions{Config: mockConfig, Request: mockRequest}
+
+	expiringToken := makeTestToken(time.Now().Add(time.Minute))
+	newToken := makeTestToken(time.Now().Add(time.Hour))
+	profileConfig := jwt.Credential{Profile: "test", APIKey: "fake_api_key", AccessToken: expiringToken, APIEndpoint: "http://www.test.com"}
+
+	mockRequest.EXPECT().RequestNewAccessToken(gomock.Any(), "http://www.test.com/user/credentials/api/access_token", gomock.Any()).DoAndReturn(
+		func(ctx context.Context, accessTokenEndpoint string, profileConfig *jwt.Credential) error {
+			time.Sleep(10 * time.Millisecond)
+			profileConfig.AccessToken = newToken
+			return nil
+		}).Times(1)
+	mockConfig.EXPECT().UpdateAccessToken(gomock.Any()).Do(func(profileConfig jwt.Credential) {
+		if profileConfig.AccessToken != newToken {
+			t.Errorf("Wanted the refreshed token to be saved, got %v", profileConfig.AccessToken)
+		}
+	}).Times(1)
+	mockRequest.EXPECT().MakeARequest(gomock.Any(), "GET", "http://www.test.com/user/data/download/test_uuid", newToken, "", gomock.Any(), gomock.Any(), false).DoAndReturn(
+		func(ctx context.Context, method string, apiEndpoint string, accessToken string, contentType string, headers map[string]string, body *bytes.Buffer, noTimeout bool) (*http.Response, error) {
+			return &http.Response{Body: ioutil.NopCloser(bytes.NewBufferString("{\"url\": \"http://www.test.com/test_uuid\"}")), StatusCode: 200}, nil
+		}).Times(5)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := testFunction.DoRequestWithSignedHeader(context.Background(), &profileConfig, "/user/data/download/test_uuid", "", nil)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Expect a refresh interrupted by the context of the caller that made it to be made again by a caller waiting for it,
+// instead of failing that caller with the interruption
+func TestGetAccessTokenInterruptedRefresh(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockConfig := mocks.NewMockConfigureInterface(mockCtrl)
+	mockRequest := mocks.NewMockRequestInterface(mockCtrl)
+	testFunction := &jwt.Functions{Config: mockConfig, Request: mockRequest}
+	newToken := makeTestToken(time.Now().Add(time.Hour))
+	profileConfig := jwt.Credential{Profile: "test", APIKey: "fake_api_key", AccessToken: "", APIEndpoint: "http://www.test.com"}
+
+	started := make(chan struct{})
+	gomock.InOrder(
+		mockRequest.EXPECT().RequestNewAccessToken(gomock.Any(), "http://www.test.com/user/credentials/api/access_token", gomock.Any()).DoAndReturn(
+			func(ctx context.Context, accessTokenEndpoint string, profileConfig *jwt.Credential) error {
+				close(started)
+				<-ctx.Done()
+				return errors.New("Error occurred in RequestNewAccessToken: " + ctx.Err().Error())
+			}),
+		mockRequest.EXPECT().RequestNewAccessToken(gomock.Any(), "http://www.test.com/user/credentials/api/access_token", gomock.Any()).DoAndReturn(
+			func(ctx context.Context, accessTokenEndpoint string, profileConfig *jwt.Credential) error {
+				profileConfig.AccessToken = newToken
+				return nil
+			}),
+	)
+	mockConfig.EXPECT().UpdateAccessToken(gomock.Any()).Return(nil)
+
+	tokenManager := jwt.NewTokenManager()
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error)
+	go func() {
+		_, err := tokenManager.GetAccessToken(ctx, testFunction, &profileConfig, "http://www.test.com", "")
+		leaderErr <- err
+	}()
+	<-started
+	waiterToken := make(chan string)
+	go func() {
+		token, err := tokenManager.GetAccessToken(context.Background(), testFunction, &profileConfig, "http://www.test.com", "")
+		if err != nil {
+			t.Errorf("Wanted the waiting caller to refresh the token again, got %v", err)
+		}
+		waiterToken <- token
+	}()
+	time.Sleep(50 * time.Millisecond) // lets the second caller wait for the refresh in progress
+	cancel()
+
+	if err := <-leaderErr; err == nil {
+		t.Error("Wanted the interrupted refresh to fail for the caller that made it")
+	}
+	if token := <-waiterToken; token != newToken {
+		t.Errorf("Wanted the refreshed token, got %v", token)
+	}
+}
+
+// roundTripperFunc lets a function serve the requests of an http client
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// closeRecorder records whether a response body has been closed
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (cr *closeRecorder) Close() error {
+	cr.closed = true
+	return nil
+}
+
+// Expect an API key rejected by Fence to give an auth error, and the response of the rejection to be closed.
+func TestRequestNewAccessTokenRejected(t *testing.T) {
+	body := &closeRecorder{Reader: strings.NewReader("{\"error\": \"invalid api key\"}")}
+	request := &jwt.Request{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 401, Body: body, Request: req}, nil
+	})}
+	profileConfig := jwt.Credential{Profile: "test", KeyId: "fake_key_id", APIKey: "fake_api_key", APIEndpoint: "http://www.test.com"}
+
+	err := request.RequestNewAccessToken(context.Background(), "http://www.test.com/user/credentials/api/access_token", &profileConfig)
+	var authError *jwt.AuthError
+	if !errors.As(err, &authError) {
+		t.Errorf("Wanted an auth error for a rejected API key, got %v", err)
+	}
+	if !body.closed {
+		t.Error("Wanted the response of the rejected request to be closed")
+	}
+}
+
 func TestCheckPrivilegesNoProfile(t *testing.T) {
 
 	mockCtrl := gomock.NewController(t)
@@ -117,7 +263,7 @@ func TestCheckPrivilegesNoProfile(t *testing.T) {
 
 	profileConfig := jwt.Credential{KeyId: "", APIKey: "", AccessToken: "", APIEndpoint: ""}
 
-	_, _, err := testFunction.CheckPrivileges(&profileConfig)
+	_, _, err := testFunction.CheckPrivileges(context.Background(), &profileConfig)
 
 	if err == nil {
 		t.Errorf("Expected an error on missing credentials in configuration, but not received")
@@ -139,9 +285,9 @@ func TestCheckPrivilegesNoAccess(t *testing.T) {
 		StatusCode: 200,
 	}
 
-	mockRequest.EXPECT().MakeARequest("GET", "http://www.test.com/user/user", "non_expired_token", "", gomock.Any(), gomock.Any(), false).Return(mockedResp, nil).Times(1)
+	mockRequest.EXPECT().MakeARequest(gomock.Any(), "GET", "http://www.test.com/user/user", "non_expired_token", "", gomock.Any(), gomock.Any(), false).Return(mockedResp, nil).Times(1)
 
-	_, receivedAccess, err := testFunction.CheckPrivileges(&profileConfig)
+	_, receivedAccess, err := testFunction.CheckPrivileges(context.Background(), &profileConfig)
 
 	expectedAccess := make(map[string]interface{})
 
@@ -175,9 +321,9 @@ func TestCheckPrivilegesGrantedAccess(t *testing.T) {
 		StatusCode: 200,
 	}
 
-	mockRequest.EXPECT().MakeARequest("GET", "http://www.test.com/user/user", "non_expired_token", "", gomock.Any(), gomock.Any(), false).Return(mockedResp, nil).Times(1)
+	mockRequest.EXPECT().MakeARequest(gomock.Any(), "GET", "http://www.test.com/user/user", "non_expired_token", "", gomock.Any(), gomock.Any(), false).Return(mockedResp, nil).Times(1)
 
-	_, expectedAccess, err := testFunction.CheckPrivileges(&profileConfig)
+	_, expectedAccess, err := testFunction.CheckPrivileges(context.Background(), &profileConfig)
 
 	receivedAccess := make(map[string]interface{})
 	receivedAccess["test_project"] = []interface{}{
@@ -229,9 +375,9 @@ func TestCheckPrivilegesGrantedAccessAuthz(t *testing.T) {
 		StatusCode: 200,
 	}
 
-	mockRequest.EXPECT().MakeARequest("GET", "http://www.test.com/user/user", "non_expired_token", "", gomock.Any(), gomock.Any(), false).Return(mockedResp, nil).Times(1)
+	mockRequest.EXPECT().MakeARequest(gomock.Any(), "GET", "http://www.test.com/user/user", "non_expired_token", "", gomock.Any(), gomock.Any(), false).Return(mockedResp, nil).Times(1)
 
-	_, expectedAccess, err := testFunction.CheckPrivileges(&profileConfig)
+	_, expectedAccess, err := testFunction.CheckPrivileges(context.Background(), &profileConfig)
 
 	receivedAccess := make(map[string]interface{})
 	receivedAccess["test_project"] = []map[string]interface{}{
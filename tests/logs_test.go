@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+)
+
+// Expect succeeded log entries to be read from both the legacy format, where an
+// entry is only the GUID, and the current format with size and checksums.
+func TestSucceededLogEntry_unmarshal(t *testing.T) {
+	data := []byte(`{
+		"/data/legacy.bam": "000000-0000000-0000000-000000",
+		"/data/current.bam": {"guid": "111111-1111111-1111111-111111", "size": 120, "hashes": {"md5": "d41d8cd98f00b204e9800998ecf8427e"}}
+	}`)
+	entries := make(map[string]logs.SucceededLogEntry)
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if entries["/data/legacy.bam"].GUID != "000000-0000000-0000000-000000" {
+		t.Errorf("Wanted legacy entry GUID to be read, got %v", entries["/data/legacy.bam"])
+	}
+	current := entries["/data/current.bam"]
+	if current.GUID != "111111-1111111-1111111-111111" || current.Size != 120 || current.Hashes["md5"] != "d41d8cd98f00b204e9800998ecf8427e" {
+		t.Errorf("Wanted current entry to be read with size and hashes, got %v", current)
+	}
+}
+
+// Expect the uploaded parts of a multipart upload to survive a restart of the
+// client, so that the upload can be resumed.
+func TestMultipartState_resume(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gen3-client-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	logs.MainLogPath = tempDir + commonUtils.PathSeparator
+
+	filePath := "/data/large.bam"
+	logs.InitMultipartStateLog("test-profile")
+	logs.SaveMultipartState(commonUtils.MultipartStateObject{FilePath: filePath, GUID: "000000-0000000-0000000-000000", UploadID: "test-upload-id", ChunkSize: 5 * 1024 * 1024})
+	logs.AddPartToMultipartState(filePath, 1, "etag-1")
+	logs.AddPartToMultipartState(filePath, 2, "etag-2")
+	logs.FlushMultipartState()
+
+	// simulate a restart by reloading the state file
+	logs.InitMultipartStateLog("test-profile")
+	state, present := logs.GetMultipartState(filePath)
+	if !present {
+		t.Fatal("Wanted the multipart upload to be resumable after a restart")
+	}
+	if state.UploadID != "test-upload-id" || state.ETags[1] != "etag-1" || state.ETags[2] != "etag-2" {
+		t.Errorf("Wanted upload ID and ETags of finished parts to be restored, got %v", state)
+	}
+
+	logs.DeleteFromMultipartState(filePath)
+	logs.InitMultipartStateLog("test-profile")
+	if logs.ExistsInMultipartState(filePath) {
+		t.Error("Wanted the multipart upload to be removed from the state file")
+	}
+}
+
+// Expect failed downloads to be written to the failed download log, and to be read back from it.
+func TestFailedDownloadLog_reload(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gen3-client-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	logs.MainLogPath = tempDir + commonUtils.PathSeparator
+
+	logs.InitFailedDownloadLog("test-profile")
+	if _, err := os.Stat(logs.GetFailedDownloadLogFilename()); !os.IsNotExist(err) {
+		t.Error("Wanted no failed download log file to be created before any download has failed")
+	}
+	logs.AddToFailedDownloadLog("000000-0000000-0000000-000000", "/data/", "test.cram", 1024, "io.Copy error: unexpected EOF", 5, true)
+	logs.AddToFailedDownloadLog("111111-1111111-1111111-111111", "/data/", "other.cram", 2048, "io.Copy error: unexpected EOF", 5, true)
+	logs.DeleteFromFailedDownloadLog("/data/", "other.cram", true)
+	failedDownloadLogFilename := logs.GetFailedDownloadLogFilename()
+	err = logs.CloseFailedDownloadLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs.InitFailedDownloadLog("test-profile")
+	logs.LoadFailedDownloadLogFile(failedDownloadLogFilename)
+	failedDownloads := logs.GetFailedDownloadLogMap()
+	if len(failedDownloads) != 1 {
+		t.Fatalf("Wanted 1 failed download in log, got %d", len(failedDownloads))
+	}
+	ro := failedDownloads["/data/test.cram"]
+	if ro.GUID != "000000-0000000-0000000-000000" || ro.FileSize != 1024 || ro.Error != "io.Copy error: unexpected EOF" {
+		t.Errorf("Wanted GUID, expected size and last error to be restored, got %v", ro)
+	}
+}
+
+// Expect the failed download log file not to be rewritten for every failed download, and to have all of them once flushed.
+func TestFailedDownloadLog_flush(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gen3-client-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	logs.MainLogPath = tempDir + commonUtils.PathSeparator
+
+	logs.InitFailedDownloadLog("test-profile")
+	for i := 0; i < 1000; i++ {
+		logs.AddToFailedDownloadLog(fmt.Sprintf("guid-%04d", i), "/data/", fmt.Sprintf("file-%04d.cram", i), 1024, "Interrupted before the download has started", 0, true)
+	}
+	readFailedDownloads := func() []commonUtils.DownloadRetryObject {
+		data, err := ioutil.ReadFile(logs.GetFailedDownloadLogFilename())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var failedDownloads []commonUtils.DownloadRetryObject
+		if err := json.Unmarshal(data, &failedDownloads); err != nil {
+			t.Fatal(err)
+		}
+		return failedDownloads
+	}
+	if failedDownloads := readFailedDownloads(); len(failedDownloads) != 1 {
+		t.Errorf("Wanted the file to be written for the first failed download only, got %d failed downloads in it", len(failedDownloads))
+	}
+
+	logs.FlushFailedDownloadLog()
+	if failedDownloads := readFailedDownloads(); len(failedDownloads) != 1000 {
+		t.Errorf("Wanted the 1000 failed downloads in the flushed file, got %d", len(failedDownloads))
+	}
+	logs.DeleteFromFailedDownloadLog("/data/", "file-0000.cram", true)
+	if err := logs.CloseFailedDownloadLog(); err != nil {
+		t.Fatal(err)
+	}
+	if failedDownloads := readFailedDownloads(); len(failedDownloads) != 999 {
+		t.Errorf("Wanted the last change to be written when the log is closed, got %d failed downloads", len(failedDownloads))
+	}
+}
+
+// Expect a file that failed and then succeeded to be reported once, as succeeded,
+// and a failure without a message to keep the message of the failure before it.
+func TestRecordFileResult(t *testing.T) {
+	retried := "/data/results/retried.bam"
+	failed := "/data/results/failed.bam"
+	logs.StartFileResult(retried)
+	logs.RecordFileResult(retried, logs.FileResult{FilePath: retried, Status: logs.StatusFailed, Error: "connection reset"})
+	logs.RecordFileResult(failed, logs.FileResult{FilePath: failed, Status: logs.StatusFailed, Error: "file open error"})
+	logs.RecordFileResult(failed, logs.FileResult{FilePath: failed, Status: logs.StatusFailed})
+	logs.RecordFileResult(retried, logs.FileResult{GUID: "000000-0000000-0000000-000000", FilePath: retried, Status: logs.StatusSucceeded, Bytes: 120})
+
+	results := make(map[string]logs.FileResult)
+	var order []string
+	for _, result := range logs.GetFileResults() {
+		if result.FilePath == retried || result.FilePath == failed {
+			results[result.FilePath] = result
+			order = append(order, result.FilePath)
+		}
+	}
+	if len(order) != 2 || order[0] != retried || order[1] != failed {
+		t.Fatalf("Wanted one result per file in the order the files were first seen, got %v", order)
+	}
+	if result := results[retried]; result.Status != logs.StatusSucceeded || result.GUID != "000000-0000000-0000000-000000" || result.Bytes != 120 || result.Error != "" {
+		t.Errorf("Wanted the retried file to be reported as succeeded, got %v", result)
+	}
+	if result := results[failed]; result.Status != logs.StatusFailed || result.Error != "file open error" {
+		t.Errorf("Wanted the failed file to keep its error message, got %v", result)
+	}
+}
+
+// Expect the JSON output mode to send the messages to stderr without touching os.Stdout
+func TestSetOutputFormat(t *testing.T) {
+	stdout := os.Stdout
+	defer logs.SetOutputFormat(logs.OutputText)
+
+	if err := logs.SetOutputFormat("yaml"); err == nil {
+		t.Error("Wanted an error for an unknown output format")
+	}
+	if err := logs.SetOutputFormat(logs.OutputJSON); err != nil {
+		t.Fatal(err)
+	}
+	if os.Stdout != stdout {
+		t.Error("Wanted os.Stdout to be left as is in JSON mode")
+	}
+	if logs.MessageOutput() != os.Stderr {
+		t.Error("Wanted the messages to be printed on stderr in JSON mode")
+	}
+	if err := logs.SetOutputFormat(logs.OutputText); err != nil {
+		t.Fatal(err)
+	}
+	if logs.MessageOutput() != os.Stdout {
+		t.Error("Wanted the messages to be printed on stdout in text mode")
+	}
+}
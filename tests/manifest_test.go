@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
+)
+
+func readAllManifestObjects(t *testing.T, manifest string, format string, columns map[string]string) []gen3.ManifestObject {
+	manifestReader, err := gen3.NewManifestReader(strings.NewReader(manifest), format, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := make([]gen3.ManifestObject, 0)
+	for {
+		obj, err := manifestReader.Next()
+		if err == io.EOF {
+			return objects
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, obj)
+	}
+}
+
+// Expect the same objects to be read from manifests of every supported format,
+// with the format either given explicitly or detected from the content.
+func TestManifestReader_formats(t *testing.T) {
+	expected := []gen3.ManifestObject{
+		{ObjectID: "000000-0000000-0000000-000000", Filename: "a.cram", Filesize: 1024, MD5: "d41d8cd98f00b204e9800998ecf8427e"},
+		{ObjectID: "111111-1111111-1111111-111111", Filename: "b.cram", Filesize: 2048},
+	}
+	manifests := map[string]string{
+		gen3.ManifestFormatJSON: `[
+			{"object_id": "000000-0000000-0000000-000000", "file_name": "a.cram", "file_size": 1024, "md5": "d41d8cd98f00b204e9800998ecf8427e"},
+			{"object_id": "111111-1111111-1111111-111111", "file_name": "b.cram", "file_size": 2048}
+		]`,
+		gen3.ManifestFormatTSV: "guid\tfile_name\tfile_size\tmd5\n" +
+			"000000-0000000-0000000-000000\ta.cram\t1024\td41d8cd98f00b204e9800998ecf8427e\n" +
+			"111111-1111111-1111111-111111\tb.cram\t2048\t\n",
+		gen3.ManifestFormatCSV: "Object_ID,File_Name,Size,md5sum\n" +
+			"000000-0000000-0000000-000000,a.cram,1024,d41d8cd98f00b204e9800998ecf8427e\n" +
+			"111111-1111111-1111111-111111,b.cram,2048,\n",
+	}
+	for format, manifest := range manifests {
+		for _, givenFormat := range []string{format, ""} {
+			objects := readAllManifestObjects(t, manifest, givenFormat, nil)
+			if !reflect.DeepEqual(objects, expected) {
+				t.Errorf("Wanted %v from %s manifest (given format %q), got %v", expected, format, givenFormat, objects)
+			}
+		}
+	}
+
+	guidList := "guid\n000000-0000000-0000000-000000\n\n# comment\n111111-1111111-1111111-111111\n"
+	objects := readAllManifestObjects(t, guidList, "", nil)
+	if len(objects) != 2 || objects[0].ObjectID != expected[0].ObjectID || objects[1].ObjectID != expected[1].ObjectID {
+		t.Errorf("Wanted 2 GUIDs from GUID list manifest, got %v", objects)
+	}
+}
+
+// Expect custom column mappings to override the default column names of TSV/CSV manifests.
+func TestManifestReader_columns(t *testing.T) {
+	columns, err := gen3.ParseManifestColumns("object_id=uuid, file_size=bytes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := "uuid,guid,bytes\n000000-0000000-0000000-000000,not-this-one,1024\n"
+	objects := readAllManifestObjects(t, manifest, gen3.ManifestFormatCSV, columns)
+	if len(objects) != 1 || objects[0].ObjectID != "000000-0000000-0000000-000000" || objects[0].Filesize != 1024 {
+		t.Errorf("Wanted object to be read from the mapped columns, got %v", objects)
+	}
+
+	_, err = gen3.NewManifestReader(strings.NewReader("name,size\na.cram,1024\n"), gen3.ManifestFormatCSV, nil)
+	if err == nil {
+		t.Error("Wanted an error for a manifest without an object_id column")
+	}
+	_, err = gen3.ParseManifestColumns("checksum=md5")
+	if err == nil {
+		t.Error("Wanted an error for an unknown manifest field in column mapping")
+	}
+}
@@ -0,0 +1,42 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+)
+
+// Expect the unfinished multipart uploads of the local state and of the failed log to be listed
+func TestUnfinishedMultipartUploads(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gen3-client-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	logs.MainLogPath = tempDir + commonUtils.PathSeparator
+
+	logs.InitMultipartStateLog("test-profile")
+	logs.SaveMultipartState(commonUtils.MultipartStateObject{FilePath: "/data/large.bam", Filename: "large.bam", GUID: "000000-0000000-0000000-000000", UploadID: "test-upload-id", Key: "000000-0000000-0000000-000000/large.bam", FileSize: 12 * gen3.MB, ChunkSize: 5 * gen3.MB})
+	logs.AddPartToMultipartState("/data/large.bam", 1, "etag-1")
+	failedLogMap := map[string]commonUtils.RetryObject{
+		"/data/large.bam": {FilePath: "/data/large.bam", Filename: "large.bam", GUID: "000000-0000000-0000000-000000", Error: "upload failed", Multipart: true},
+		"/data/lost.bam":  {FilePath: "/data/lost.bam", Filename: "lost.bam", GUID: "111111-1111111-1111111-111111", Multipart: true},
+		"/data/small.txt": {FilePath: "/data/small.txt", Filename: "small.txt", GUID: "222222-2222222-2222222-222222"},
+	}
+
+	uploads := gen3.ListMultipartUploads(failedLogMap)
+	if len(uploads) != 2 {
+		t.Fatalf("Wanted 2 unfinished multipart uploads, got %v", uploads)
+	}
+	large, lost := uploads[0], uploads[1]
+	if large.UploadID != "test-upload-id" || large.UploadedParts != 1 || large.TotalParts != 3 || !large.Failed || large.Error != "upload failed" {
+		t.Errorf("Wanted the upload of the local state to be merged with its failed log entry, got %+v", large)
+	}
+	if lost.UploadID != "" || lost.GUID != "111111-1111111-1111111-111111" || !lost.Failed {
+		t.Errorf("Wanted the upload only found in the failed log to have no upload ID, got %+v", lost)
+	}
+}
@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/g3cmd"
+)
+
+// Expect the project config to be found from a subdirectory, with its download path relative to its directory.
+func TestFindProjectConfig(t *testing.T) {
+	projectDir, err := ioutil.TempDir("", "gen3-client-project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(projectDir)
+	subDir := filepath.Join(projectDir, "data", "raw")
+	err = os.MkdirAll(subDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configPath := g3cmd.FindProjectConfig(subDir); configPath != "" {
+		t.Fatalf("Wanted no project config, found %v", configPath)
+	}
+
+	content := "profile: mycommons\nbucket: mybucket\ndownload-path: downloads\nfilename-format: guid\nnumparallel: 4\n"
+	err = ioutil.WriteFile(filepath.Join(projectDir, g3cmd.ProjectConfigFileName), []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configPath := g3cmd.FindProjectConfig(subDir)
+	if configPath != filepath.Join(projectDir, g3cmd.ProjectConfigFileName) {
+		t.Fatalf("Wanted the project config of %v, found %v", projectDir, configPath)
+	}
+	projectConfig, err := g3cmd.LoadProjectConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := g3cmd.ProjectConfig{Profile: "mycommons", Bucket: "mybucket", DownloadPath: filepath.Join(projectDir, "downloads"), FilenameFormat: "guid", NumParallel: 4}
+	if projectConfig != expected {
+		t.Errorf("Wanted %v, got %v", expected, projectConfig)
+	}
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
+)
+
+func TestParseRate(t *testing.T) {
+	testCases := []struct {
+		rate string
+		want int64
+	}{
+		{"", 0},
+		{"0", 0},
+		{"200MB/s", 200 * gen3.MB},
+		{"512kb", 512 * gen3.KB},
+		{"1.5G", 3 * gen3.GB / 2},
+		{"1000", 1000},
+		{"64B/s", 64},
+	}
+	for _, testCase := range testCases {
+		got, err := gen3.ParseRate(testCase.rate)
+		if err != nil {
+			t.Errorf("Unexpected error for rate %q: %v", testCase.rate, err)
+		} else if got != testCase.want {
+			t.Errorf("Wanted %d bytes per second for rate %q, got %d", testCase.want, testCase.rate, got)
+		}
+	}
+	for _, rate := range []string{"fast", "-1MB/s", "MB/s"} {
+		if _, err := gen3.ParseRate(rate); err == nil {
+			t.Errorf("Wanted an error for rate %q", rate)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	testCases := []struct {
+		size string
+		want int64
+	}{
+		{"", 0},
+		{"64MB", 64 * gen3.MB},
+		{"5gb", 5 * gen3.GB},
+		{"1.5M", 3 * gen3.MB / 2},
+	}
+	for _, testCase := range testCases {
+		got, err := gen3.ParseSize(testCase.size)
+		if err != nil {
+			t.Errorf("Unexpected error for size %q: %v", testCase.size, err)
+		} else if got != testCase.want {
+			t.Errorf("Wanted %d bytes for size %q, got %d", testCase.want, testCase.size, got)
+		}
+	}
+	for _, size := range []string{"big", "-5MB", "64MB/s"} {
+		if _, err := gen3.ParseSize(size); err == nil {
+			t.Errorf("Wanted an error for size %q", size)
+		}
+	}
+}
+
+// Expect the schedule to override the rate limit during its windows, including the ones wrapping around midnight
+func TestRateSchedule(t *testing.T) {
+	schedule, err := gen3.ParseRateSchedule("19:00-07:00=1GB/s, 12:00-13:00=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := gen3.NewRateLimiter(200*gen3.MB, schedule)
+	testCases := []struct {
+		timeOfDay string
+		want      int64
+	}{
+		{"09:00", 200 * gen3.MB},
+		{"12:30", 0},
+		{"13:00", 200 * gen3.MB},
+		{"19:00", gen3.GB},
+		{"23:59", gen3.GB},
+		{"06:59", gen3.GB},
+		{"07:00", 200 * gen3.MB},
+	}
+	for _, testCase := range testCases {
+		timeOfDay, _ := time.Parse("15:04", testCase.timeOfDay)
+		if got := limiter.Rate(timeOfDay); got != testCase.want {
+			t.Errorf("Wanted a rate limit of %d at %s, got %d", testCase.want, testCase.timeOfDay, got)
+		}
+	}
+
+	for _, invalidSchedule := range []string{"19:00=1GB/s", "19:00-07:00", "25:00-07:00=1GB/s", "19:00-07:00=fast"} {
+		if _, err := gen3.ParseRateSchedule(invalidSchedule); err == nil {
+			t.Errorf("Wanted an error for schedule %q", invalidSchedule)
+		}
+	}
+}
+
+// Expect the transfers sharing a RateLimiter to be held to its rate together
+func TestRateLimiterWaitN(t *testing.T) {
+	limiter := gen3.NewRateLimiter(100*gen3.KB, nil)
+	start := time.Now()
+	// the bucket starts empty, so that a burst of transfers is throttled from the first byte
+	done := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			done <- limiter.WaitN(context.Background(), int(10*gen3.KB))
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Wanted 30KB at 100KB/s to take about 300ms, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.WaitN(ctx, int(gen3.MB)); err != context.Canceled {
+		t.Errorf("Wanted a cancelled wait to return context.Canceled, got %v", err)
+	}
+}
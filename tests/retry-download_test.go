@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
+	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+	"github.com/uc-cdis/gen3-client/gen3-client/mocks"
+)
+
+// retryDownloadTestObject is an object of a failed download log, served by the storage as content
+type retryDownloadTestObject struct {
+	guid     string
+	filename string
+	content  []byte
+	// recordMD5 is the md5 of the object according to its Indexd record
+	recordMD5 string
+	// local is what is already on disk before the retry
+	local []byte
+	// missing makes the storage respond with 404
+	missing bool
+}
+
+func md5Hex(content []byte) string {
+	sum := md5.Sum(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Expect retry-download to download the files of a failed download log again to the same paths and filenames:
+// complete files are skipped, partial files are resumed, and the files that still fail are kept in a new failed download log
+func TestRetryDownload(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gen3-client-retry-download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	logs.MainLogPath = tempDir + commonUtils.PathSeparator
+	logs.ResetFileResults()
+	logs.InitFailedDownloadLog("test-profile")
+	downloadPath := tempDir + commonUtils.PathSeparator
+
+	content := []byte(strings.Repeat("retry-download test content\n", 100))
+	objects := []retryDownloadTestObject{
+		{guid: "retry-guid-done", filename: "done.txt", content: content, recordMD5: md5Hex(content), local: content},
+		{guid: "retry-guid-partial", filename: filepath.Join("nested", "partial.txt"), content: content, recordMD5: md5Hex(content), local: content[:1000]},
+		{guid: "retry-guid-corrupt", filename: "corrupt.txt", content: []byte("not the content of the record"), recordMD5: md5Hex(content)},
+		{guid: "retry-guid-gone", filename: "gone.txt", content: content, recordMD5: md5Hex(content), missing: true},
+	}
+
+	// the failed download log of the earlier run
+	failedDownloads := make([]commonUtils.DownloadRetryObject, 0, len(objects))
+	for _, object := range objects {
+		failedDownloads = append(failedDownloads, commonUtils.DownloadRetryObject{GUID: object.guid, DownloadPath: downloadPath, Filename: object.filename, Error: "connection reset by peer"})
+		if object.local != nil {
+			if err := os.MkdirAll(filepath.Dir(downloadPath+object.filename), 0766); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(downloadPath+object.filename, object.local, 0666); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	failedLogPath := filepath.Join(tempDir, "earlier_failed_download_log.json")
+	failedLog, _ := json.Marshal(failedDownloads)
+	if err := ioutil.WriteFile(failedLogPath, failedLog, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := logs.LoadFailedDownloadLogFile(failedLogPath); err != nil {
+		t.Fatal(err)
+	}
+
+	var lock sync.Mutex
+	requests := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		guid := strings.TrimPrefix(r.URL.Path, "/")
+		lock.Lock()
+		requests[guid] = r.Header.Get("Range")
+		lock.Unlock()
+		for _, object := range objects {
+			if object.guid == guid && !object.missing {
+				http.ServeContent(w, r, object.filename, time.Time{}, bytes.NewReader(object.content))
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+	mockGen3Interface.
+		EXPECT().
+		CheckForShepherdAPI(gomock.Any(), gomock.Any()).
+		Return(false, nil).
+		AnyTimes()
+	// the records of the failed downloads are looked up together, like the ones of a manifest
+	mockGen3Interface.
+		EXPECT().
+		GetResponse(gomock.Any(), gomock.Any(), commonUtils.IndexdBulkDocumentsEndpoint, "POST", "application/json", gomock.Any()).
+		DoAndReturn(func(ctx context.Context, profileConfig *jwt.Credential, endpointPostPrefix string, method string, contentType string, bodyBytes []byte) (string, *http.Response, error) {
+			var guids []string
+			if err := json.Unmarshal(bodyBytes, &guids); err != nil || len(guids) != len(objects) {
+				t.Errorf("Wanted the %d failed downloads to be looked up in one bulk request, got %s", len(objects), bodyBytes)
+			}
+			records := make([]map[string]interface{}, 0, len(objects))
+			for _, object := range objects {
+				records = append(records, map[string]interface{}{"did": object.guid, "size": len(content), "hashes": map[string]string{"md5": object.recordMD5}})
+			}
+			recordsBytes, _ := json.Marshal(records)
+			return "", &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewReader(recordsBytes))}, nil
+		})
+	for _, object := range objects {
+		mockGen3Interface.
+			EXPECT().
+			DoRequestWithSignedHeader(gomock.Any(), gomock.Any(), commonUtils.FenceDataDownloadEndpoint+"/"+object.guid, "", nil).
+			Return(jwt.JsonMessage{URL: server.URL + "/" + object.guid}, nil).
+			AnyTimes()
+	}
+	mockGen3Interface.
+		EXPECT().
+		MakeARequest(gomock.Any(), gomock.Any(), gomock.Any(), "", "", gomock.Any(), nil, gomock.Any()).
+		DoAndReturn((&jwt.Request{}).MakeARequest).
+		AnyTimes()
+
+	client := gen3.NewClient(jwt.Credential{}, mockGen3Interface)
+	options := gen3.DownloadOptions{NumParallel: 2, Segments: 1, SkipCompleted: true, VerifyChecksum: true, DeleteCorrupted: true}
+	err = client.RetryDownload(context.Background(), logs.GetFailedDownloadLogMap(), options)
+	var partialFailureError *gen3.PartialFailureError
+	if !errors.As(err, &partialFailureError) || partialFailureError.Failed != 2 {
+		t.Fatalf("Wanted the corrupted and the missing files to fail again, got %v", err)
+	}
+
+	if _, requested := requests["retry-guid-done"]; requested {
+		t.Error("Wanted the complete file to be skipped without downloading it")
+	}
+	if requests["retry-guid-partial"] != "bytes=1000-" {
+		t.Errorf("Wanted the partial file to be resumed from its last byte, got range %q", requests["retry-guid-partial"])
+	}
+	for _, filename := range []string{"done.txt", filepath.Join("nested", "partial.txt")} {
+		downloaded, err := ioutil.ReadFile(downloadPath + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(downloaded, content) {
+			t.Errorf("Wanted \"%s\" to have the content of its object", filename)
+		}
+	}
+	if _, err := os.Stat(downloadPath + "corrupt.txt"); !os.IsNotExist(err) {
+		t.Errorf("Wanted the file that fails the checksum verification to be deleted, got %v", err)
+	}
+
+	failedDownloadLogMap := logs.GetFailedDownloadLogMap()
+	if len(failedDownloadLogMap) != 2 {
+		t.Errorf("Wanted only the files that failed again in the failed download log, got %+v", failedDownloadLogMap)
+	}
+	for _, filename := range []string{"corrupt.txt", "gone.txt"} {
+		if ro, present := failedDownloadLogMap[downloadPath+filename]; !present || ro.Error == "connection reset by peer" {
+			t.Errorf("Wanted \"%s\" to be kept in the failed download log with its new error, got %+v", filename, ro)
+		}
+	}
+	newFailedLog, err := ioutil.ReadFile(logs.GetFailedDownloadLogFilename())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var newFailedDownloads []commonUtils.DownloadRetryObject
+	if err := json.Unmarshal(newFailedLog, &newFailedDownloads); err != nil {
+		t.Fatal(err)
+	}
+	if len(newFailedDownloads) != 2 {
+		t.Errorf("Wanted the new failed download log file to list the 2 files that failed again, got %+v", newFailedDownloads)
+	}
+}
+
+// Expect a retry of an empty failed download log to do nothing
+func TestRetryDownload_empty(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+
+	err := gen3.NewClient(jwt.Credential{}, mockGen3Interface).RetryDownload(context.Background(), map[string]commonUtils.DownloadRetryObject{}, gen3.DownloadOptions{NumParallel: 1, Segments: 1})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
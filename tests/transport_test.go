@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
+	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
+)
+
+// Expect invalid network settings to be rejected before any transport is built
+func TestHTTPConfigValidate(t *testing.T) {
+	invalidConfigs := []jwt.HTTPConfig{
+		{Proxy: "proxy.example.org"},
+		{ClientCertFile: "client.pem"},
+		{ClientKeyFile: "client.key"},
+		{ConnectTimeout: -time.Second},
+		{ResponseHeaderTimeout: -time.Second},
+		{MaxIdleConnsPerHost: -1},
+	}
+	for _, config := range invalidConfigs {
+		if err := config.Validate(); err == nil {
+			t.Errorf("Wanted an error for invalid network settings %+v", config)
+		}
+		if _, err := config.Transport(); err == nil {
+			t.Errorf("Wanted no transport to be built for invalid network settings %+v", config)
+		}
+	}
+	if err := (jwt.HTTPConfig{Proxy: "http://proxy.example.org:3128", ConnectTimeout: time.Second}).Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+// Expect the same network settings to share one transport, and a CA bundle to be trusted by it
+func TestHTTPConfigTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	caCertFile := filepath.Join(t.TempDir(), "ca.pem")
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caCertFile, caCert, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	request := &jwt.Request{}
+	if resp, err := request.MakeARequest(context.Background(), http.MethodGet, server.URL, "", "", nil, nil, false); err == nil {
+		resp.Body.Close()
+		t.Error("Wanted the certificate of the test server not to be trusted without the CA bundle")
+	}
+
+	config := jwt.HTTPConfig{CACertFile: caCertFile, MaxIdleConnsPerHost: 4}
+	transport, err := config.Transport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sameTransport, _ := config.Transport(); sameTransport != transport {
+		t.Error("Wanted the same network settings to share one transport")
+	}
+	if transport.MaxIdleConnsPerHost != 4 {
+		t.Errorf("Wanted 4 idle connections per host, got %d", transport.MaxIdleConnsPerHost)
+	}
+
+	request = &jwt.Request{Transport: transport}
+	resp, err := request.MakeARequest(context.Background(), http.MethodGet, server.URL, "", "", nil, nil, false)
+	if err != nil {
+		t.Fatalf("Wanted the CA bundle to be trusted, got %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := (jwt.HTTPConfig{CACertFile: filepath.Join(t.TempDir(), "missing.pem")}).Transport(); err == nil {
+		t.Error("Wanted an error for a missing CA bundle")
+	}
+}
+
+// Expect a request without timeout, like the transfers to the object storage, to fail when the response headers
+// don't arrive within the response header timeout of the transport
+func TestHTTPConfigResponseHeaderTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	transport, err := (jwt.HTTPConfig{ResponseHeaderTimeout: 100 * time.Millisecond}).Transport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaultTransport, _ := (jwt.HTTPConfig{}).Transport(); defaultTransport.ResponseHeaderTimeout != commonUtils.DefaultResponseHeaderTimeout {
+		t.Errorf("Wanted the default response header timeout, got %v", defaultTransport.ResponseHeaderTimeout)
+	}
+	request := &jwt.Request{Transport: transport}
+	start := time.Now()
+	resp, err := request.MakeARequest(context.Background(), http.MethodGet, server.URL, "", "", nil, nil, true)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("Wanted an error for a response that doesn't start")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Wanted the request to give up after the response header timeout, it took %v", elapsed)
+	}
+}
+
+// Expect the network settings of a profile to be kept in the config file
+func TestHTTPConfigProfile(t *testing.T) {
+	useTempHomeDir(t)
+	conf := jwt.Configure{}
+	if err := conf.InitConfigFile(); err != nil {
+		t.Fatal(err)
+	}
+	httpConfig := jwt.HTTPConfig{Proxy: "http://proxy.example.org:3128", CACertFile: "/etc/ssl/site.pem", ConnectTimeout: 10 * time.Second, ResponseHeaderTimeout: time.Minute, MaxIdleConnsPerHost: 8}
+	conf.UpdateConfigFile(jwt.Credential{Profile: "test", KeyId: "key_id", AccessToken: "access_token", APIEndpoint: "https://data.mycommons.org", HTTP: httpConfig})
+
+	profileConfig, err := conf.ReadProfile("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profileConfig.HTTP != httpConfig {
+		t.Errorf("Wanted network settings %+v, got %+v", httpConfig, profileConfig.HTTP)
+	}
+	profileConfig, err = conf.ParseConfig("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profileConfig.HTTP != httpConfig {
+		t.Errorf("Wanted network settings %+v, got %+v", httpConfig, profileConfig.HTTP)
+	}
+}
+
+// Expect record deletions to be made with the transport of the client, like every other request
+func TestDeleteRecordTransport(t *testing.T) {
+	var requests []string
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req.Method+" "+req.URL.String())
+		return &http.Response{StatusCode: 204, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}, nil
+	})
+	credential := jwt.Credential{Profile: "test", AccessToken: "access_token", APIEndpoint: "https://data.mycommons.org", UseShepherd: "false"}
+	client := gen3.NewClient(credential, gen3.NewGen3InterfaceWithTransport(transport))
+
+	msg, err := client.DeleteRecord(context.Background(), "000000-0000000-0000000-000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg != "Record with GUID 000000-0000000-0000000-000000 has been deleted" {
+		t.Errorf("Unexpected message: %v", msg)
+	}
+	if len(requests) != 1 || requests[0] != "DELETE https://data.mycommons.org/user/data/000000-0000000-0000000-000000" {
+		t.Errorf("Wanted the deletion to be made with the transport of the client, got %v", requests)
+	}
+}
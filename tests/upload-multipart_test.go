@@ -0,0 +1,234 @@
+package tests
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
+	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
+	"github.com/uc-cdis/gen3-client/gen3-client/logs"
+	"github.com/uc-cdis/gen3-client/gen3-client/mocks"
+)
+
+// multipartTestStorage is a storage server that takes the part uploads of multipart uploads, on "/part/<part number>"
+type multipartTestStorage struct {
+	*httptest.Server
+	lock     sync.Mutex
+	uploaded []int
+	inFlight int
+	// maxInFlight is the largest number of part uploads that have been in progress at the same time
+	maxInFlight int
+	// handler, if it isn't nil, is called while a part upload is in progress
+	handler func(partNumber int)
+}
+
+func newMultipartTestStorage(t *testing.T, handler func(partNumber int)) *multipartTestStorage {
+	storage := &multipartTestStorage{handler: handler}
+	storage.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		partNumber, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/part/"))
+		if r.Method != http.MethodPut || err != nil {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		storage.lock.Lock()
+		storage.uploaded = append(storage.uploaded, partNumber)
+		storage.inFlight++
+		if storage.inFlight > storage.maxInFlight {
+			storage.maxInFlight = storage.inFlight
+		}
+		storage.lock.Unlock()
+		defer func() {
+			storage.lock.Lock()
+			storage.inFlight--
+			storage.lock.Unlock()
+		}()
+		ioutil.ReadAll(r.Body) // nolint: errcheck
+		if storage.handler != nil {
+			storage.handler(partNumber)
+		}
+		w.Header().Set("ETag", fmt.Sprintf("etag-%d", partNumber))
+	}))
+	return storage
+}
+
+// expectMultipartRequests makes the mock hand out the presigned URLs of the parts on storage, and records the parts
+// a multipart upload is completed with into completed
+func expectMultipartRequests(t *testing.T, mockGen3Interface *mocks.MockGen3Interface, storage *multipartTestStorage, completed *[]gen3.MultipartPartObject) {
+	mockGen3Interface.
+		EXPECT().
+		DoRequestWithSignedHeader(gomock.Any(), gomock.Any(), commonUtils.FenceDataMultipartUploadEndpoint, "application/json", gomock.Any()).
+		DoAndReturn(func(ctx context.Context, profileConfig *jwt.Credential, endpointPostPrefix string, contentType string, bodyBytes []byte) (jwt.JsonMessage, error) {
+			var request gen3.MultipartUploadRequestObject
+			if err := json.Unmarshal(bodyBytes, &request); err != nil {
+				t.Error(err)
+				return jwt.JsonMessage{}, err
+			}
+			return jwt.JsonMessage{PresignedURL: fmt.Sprintf("%s/part/%d", storage.URL, request.PartNumber)}, nil
+		}).
+		AnyTimes()
+	mockGen3Interface.
+		EXPECT().
+		DoRequestWithSignedHeader(gomock.Any(), gomock.Any(), commonUtils.FenceDataMultipartCompleteEndpoint, "application/json", gomock.Any()).
+		DoAndReturn(func(ctx context.Context, profileConfig *jwt.Credential, endpointPostPrefix string, contentType string, bodyBytes []byte) (jwt.JsonMessage, error) {
+			var request gen3.MultipartCompleteRequestObject
+			if err := json.Unmarshal(bodyBytes, &request); err != nil {
+				t.Error(err)
+				return jwt.JsonMessage{}, err
+			}
+			*completed = request.Parts
+			return jwt.JsonMessage{}, nil
+		})
+}
+
+// multipartTestSetup initializes the logs of an upload in a temporary directory, and creates a sparse file of size bytes in it
+func multipartTestSetup(t *testing.T, size int64) (string, string) {
+	tempDir, err := ioutil.TempDir("", "gen3-client-upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs.MainLogPath = tempDir + commonUtils.PathSeparator
+	logs.ResetFileResults()
+	logs.InitScoreBoard(gen3.MaxRetryCount)
+	if err := logs.InitFailedLog("test-profile"); err != nil {
+		t.Fatal(err)
+	}
+	if err := logs.InitSucceededLog("test-profile"); err != nil {
+		t.Fatal(err)
+	}
+	if err := logs.InitMultipartStateLog("test-profile"); err != nil {
+		t.Fatal(err)
+	}
+
+	filePath := tempDir + commonUtils.PathSeparator + "large.bam"
+	file, err := os.Create(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := file.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	return tempDir, filePath
+}
+
+// Expect an interrupted multipart upload to be resumed by Upload and RetryUpload: only the parts that haven't been
+// uploaded are uploaded, the upload is completed with the ETags of all of its parts, and its GUID is kept
+func TestMultipartUpload_resume(t *testing.T) {
+	for _, retry := range []bool{false, true} {
+		tempDir, filePath := multipartTestSetup(t, 12*gen3.MB)
+		defer os.RemoveAll(tempDir)
+		storage := newMultipartTestStorage(t, nil)
+		defer storage.Close()
+
+		// the first two parts of the 3 parts of 5MB have been uploaded before the upload was interrupted
+		fi, err := os.Stat(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		guid := "000000-0000000-0000000-000000"
+		logs.SaveMultipartState(commonUtils.MultipartStateObject{FilePath: filePath, Filename: "large.bam", GUID: guid, UploadID: "test-upload-id", Key: guid + "/large.bam", FileSize: fi.Size(), ModTime: fi.ModTime(), ChunkSize: 5 * gen3.MB})
+		logs.AddPartToMultipartState(filePath, 1, "etag-1")
+		logs.AddPartToMultipartState(filePath, 2, "etag-2")
+
+		// the multipart upload isn't initialized again, and the record of the GUID isn't deleted
+		mockCtrl := gomock.NewController(t)
+		mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+		var completed []gen3.MultipartPartObject
+		expectMultipartRequests(t, mockGen3Interface, storage, &completed)
+
+		client := gen3.NewClient(jwt.Credential{}, mockGen3Interface)
+		client.MultipartChunkSize = 5 * gen3.MB
+		if retry {
+			failedLogMap := map[string]commonUtils.RetryObject{filePath: {FilePath: filePath, Filename: "large.bam", GUID: guid, Multipart: true}}
+			err = client.RetryUpload(context.Background(), failedLogMap)
+		} else {
+			err = client.Upload(context.Background(), []string{filePath}, gen3.UploadOptions{ForceMultipart: true})
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		mockCtrl.Finish()
+
+		if !reflect.DeepEqual(storage.uploaded, []int{3}) {
+			t.Errorf("Wanted only the last part to be uploaded, got parts %v", storage.uploaded)
+		}
+		wantedParts := []gen3.MultipartPartObject{{PartNumber: 1, ETag: "etag-1"}, {PartNumber: 2, ETag: "etag-2"}, {PartNumber: 3, ETag: "etag-3"}}
+		if !reflect.DeepEqual(completed, wantedParts) {
+			t.Errorf("Wanted the upload to be completed with the ETags of all parts %v, got %v", wantedParts, completed)
+		}
+		entry, ok := logs.GetSucceededLogEntry(filePath)
+		contentMD5 := md5.Sum(make([]byte, 12*gen3.MB))
+		if !ok || entry.GUID != guid || entry.Hashes["md5"] != hex.EncodeToString(contentMD5[:]) {
+			t.Errorf("Wanted the file to be recorded as uploaded to GUID %s with the md5 of the whole file, got %v", guid, entry)
+		}
+		if logs.ExistsInMultipartState(filePath) {
+			t.Error("Wanted the completed upload to be removed from the multipart state")
+		}
+	}
+}
+
+// Expect the parts of a file of more than 525MB, from which the parts used to be uploaded one at a time, to be uploaded
+// concurrently, with no more parts in flight than buffers
+func TestMultipartUpload_concurrentParts(t *testing.T) {
+	tempDir, filePath := multipartTestSetup(t, 530*gen3.MB)
+	defer os.RemoveAll(tempDir)
+	// a part upload waits for another one to be in progress, until a deadline shared by all parts
+	deadline := time.Now().Add(5 * time.Second)
+	concurrent := make(chan struct{})
+	var once sync.Once
+	var storage *multipartTestStorage
+	storage = newMultipartTestStorage(t, func(partNumber int) {
+		storage.lock.Lock()
+		if storage.inFlight > 1 {
+			once.Do(func() { close(concurrent) })
+		}
+		storage.lock.Unlock()
+		select {
+		case <-concurrent:
+		case <-time.After(time.Until(deadline)):
+		}
+	})
+	defer storage.Close()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+	mockGen3Interface.
+		EXPECT().
+		DoRequestWithSignedHeader(gomock.Any(), gomock.Any(), commonUtils.FenceDataMultipartInitEndpoint, "application/json", gomock.Any()).
+		Return(jwt.JsonMessage{UploadID: "test-upload-id", GUID: "000000-0000000-0000000-000000"}, nil)
+	var completed []gen3.MultipartPartObject
+	expectMultipartRequests(t, mockGen3Interface, storage, &completed)
+
+	client := gen3.NewClient(jwt.Credential{}, mockGen3Interface)
+	if err := client.Upload(context.Background(), []string{filePath}, gen3.UploadOptions{ForceMultipart: true}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	chunkSize, numOfChunks := gen3.CalculateChunkSize(530*gen3.MB, 0)
+	if len(completed) != numOfChunks {
+		t.Errorf("Wanted the upload to be completed with %d parts, got %d", numOfChunks, len(completed))
+	}
+	if storage.maxInFlight < 2 {
+		t.Errorf("Wanted the parts to be uploaded concurrently, got at most %d part upload in progress", storage.maxInFlight)
+	}
+	if numOfBuffers := gen3.MultipartBufferCount(chunkSize, 10); storage.maxInFlight > numOfBuffers {
+		t.Errorf("Wanted at most %d parts in flight, one per buffer, got %d", numOfBuffers, storage.maxInFlight)
+	}
+}
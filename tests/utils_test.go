@@ -1,16 +1,18 @@
 package tests
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/uc-cdis/gen3-client/gen3-client/commonUtils"
-	g3cmd "github.com/uc-cdis/gen3-client/gen3-client/g3cmd"
+	"github.com/uc-cdis/gen3-client/gen3-client/gen3"
 	"github.com/uc-cdis/gen3-client/gen3-client/jwt"
 	"github.com/uc-cdis/gen3-client/gen3-client/mocks"
 )
@@ -33,7 +35,7 @@ func TestGetDownloadResponse_withShepherd(t *testing.T) {
 	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
 	mockGen3Interface.
 		EXPECT().
-		CheckForShepherdAPI(gomock.AssignableToTypeOf(testProfileConfig)).
+		CheckForShepherdAPI(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig)).
 		Return(true, nil)
 
 	// Mock the request to Shepherd for the download URL of this file.
@@ -47,7 +49,7 @@ func TestGetDownloadResponse_withShepherd(t *testing.T) {
 	}
 	mockGen3Interface.
 		EXPECT().
-		GetResponse(gomock.AssignableToTypeOf(testProfileConfig), commonUtils.ShepherdEndpoint+"/objects/"+testGUID+"/download", "GET", "", nil).
+		GetResponse(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig), commonUtils.ShepherdEndpoint+"/objects/"+testGUID+"/download", "GET", "", nil).
 		Return("", &mockDownloadURLResponse, nil)
 
 	// Mock the request for the file at mockDownloadURL.
@@ -57,7 +59,7 @@ func TestGetDownloadResponse_withShepherd(t *testing.T) {
 	}
 	mockGen3Interface.
 		EXPECT().
-		MakeARequest(http.MethodGet, mockDownloadURL, "", "", map[string]string{}, nil, true).
+		MakeARequest(gomock.Any(), http.MethodGet, mockDownloadURL, "", "", map[string]string{}, nil, true).
 		Return(&mockFileResponse, nil)
 	// ----------
 
@@ -66,7 +68,7 @@ func TestGetDownloadResponse_withShepherd(t *testing.T) {
 		GUID:     testGUID,
 		Range:    0,
 	}
-	err := g3cmd.GetDownloadResponse(mockGen3Interface, &mockFDRObj, "")
+	err := gen3.NewClient(jwt.Credential{}, mockGen3Interface).GetDownloadResponse(context.Background(), &mockFDRObj, "")
 	if err != nil {
 		t.Error(err)
 	}
@@ -96,7 +98,7 @@ func TestGetDownloadResponse_noShepherd(t *testing.T) {
 	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
 	mockGen3Interface.
 		EXPECT().
-		CheckForShepherdAPI(gomock.AssignableToTypeOf(testProfileConfig)).
+		CheckForShepherdAPI(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig)).
 		Return(false, nil)
 
 	// Mock the request to Fence for the download URL of this file.
@@ -106,7 +108,7 @@ func TestGetDownloadResponse_noShepherd(t *testing.T) {
 	}
 	mockGen3Interface.
 		EXPECT().
-		DoRequestWithSignedHeader(gomock.AssignableToTypeOf(testProfileConfig), commonUtils.FenceDataDownloadEndpoint+"/"+testGUID, "", nil).
+		DoRequestWithSignedHeader(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig), commonUtils.FenceDataDownloadEndpoint+"/"+testGUID, "", nil).
 		Return(mockDownloadURLResponse, nil)
 
 	// Mock the request for the file at mockDownloadURL.
@@ -116,7 +118,7 @@ func TestGetDownloadResponse_noShepherd(t *testing.T) {
 	}
 	mockGen3Interface.
 		EXPECT().
-		MakeARequest(http.MethodGet, mockDownloadURL, "", "", map[string]string{}, nil, true).
+		MakeARequest(gomock.Any(), http.MethodGet, mockDownloadURL, "", "", map[string]string{}, nil, true).
 		Return(&mockFileResponse, nil)
 	// ----------
 
@@ -125,7 +127,7 @@ func TestGetDownloadResponse_noShepherd(t *testing.T) {
 		GUID:     testGUID,
 		Range:    0,
 	}
-	err := g3cmd.GetDownloadResponse(mockGen3Interface, &mockFDRObj, "")
+	err := gen3.NewClient(jwt.Credential{}, mockGen3Interface).GetDownloadResponse(context.Background(), &mockFDRObj, "")
 	if err != nil {
 		t.Error(err)
 	}
@@ -137,6 +139,47 @@ func TestGetDownloadResponse_noShepherd(t *testing.T) {
 	}
 }
 
+// GetDownloadURL should only resolve the presigned URL, without requesting the file itself,
+// so that segmented downloads can issue their own ranged requests against it.
+func TestGetDownloadURL_noShepherd(t *testing.T) {
+	// -- SETUP --
+	testGUID := "000000-0000000-0000000-000000"
+	testProfileConfig := &jwt.Credential{
+		Profile: "test-profile",
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Mock the request that checks if Shepherd is deployed.
+	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+	mockGen3Interface.
+		EXPECT().
+		CheckForShepherdAPI(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig)).
+		Return(false, nil)
+
+	// Mock the request to Fence for the download URL of this file.
+	mockDownloadURL := "https://example.com/example.pfb"
+	mockGen3Interface.
+		EXPECT().
+		DoRequestWithSignedHeader(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig), commonUtils.FenceDataDownloadEndpoint+"/"+testGUID+"?protocol=s3", "", nil).
+		Return(jwt.JsonMessage{URL: mockDownloadURL}, nil)
+	// ----------
+
+	mockFDRObj := commonUtils.FileDownloadResponseObject{
+		GUID: testGUID,
+	}
+	err := gen3.NewClient(jwt.Credential{}, mockGen3Interface).GetDownloadURL(context.Background(), &mockFDRObj, "?protocol=s3")
+	if err != nil {
+		t.Error(err)
+	}
+	if mockFDRObj.URL != mockDownloadURL {
+		t.Errorf("Wanted the URL to be set to %v, got %v", mockDownloadURL, mockFDRObj.URL)
+	}
+	if mockFDRObj.Response != nil {
+		t.Errorf("Wanted no download response, got %v", mockFDRObj.Response)
+	}
+}
+
 // If Shepherd is not deployed, expect GeneratePresignedURL to hit fence's data upload
 // endpoint and return the presigned URL and guid.
 func TestGeneratePresignedURL_noShepherd(t *testing.T) {
@@ -153,7 +196,7 @@ func TestGeneratePresignedURL_noShepherd(t *testing.T) {
 	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
 	mockGen3Interface.
 		EXPECT().
-		CheckForShepherdAPI(gomock.AssignableToTypeOf(testProfileConfig)).
+		CheckForShepherdAPI(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig)).
 		Return(false, nil)
 
 	// Mock the request to Fence's data upload endpoint to create a presigned url for this file name.
@@ -166,11 +209,11 @@ func TestGeneratePresignedURL_noShepherd(t *testing.T) {
 	}
 	mockGen3Interface.
 		EXPECT().
-		DoRequestWithSignedHeader(gomock.AssignableToTypeOf(testProfileConfig), commonUtils.FenceDataUploadEndpoint, "application/json", expectedReqBody).
+		DoRequestWithSignedHeader(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig), commonUtils.FenceDataUploadEndpoint, "application/json", expectedReqBody).
 		Return(mockUploadURLResponse, nil)
 	// ----------
 
-	url, guid, err := g3cmd.GeneratePresignedURL(mockGen3Interface, testFilename, commonUtils.FileMetadata{}, testBucketname)
+	url, guid, err := gen3.NewClient(jwt.Credential{}, mockGen3Interface).GeneratePresignedURL(context.Background(), testFilename, commonUtils.FileMetadata{}, testBucketname)
 	if err != nil {
 		t.Error(err)
 	}
@@ -204,11 +247,11 @@ func TestGeneratePresignedURL_withShepherd(t *testing.T) {
 	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
 	mockGen3Interface.
 		EXPECT().
-		CheckForShepherdAPI(gomock.AssignableToTypeOf(testProfileConfig)).
+		CheckForShepherdAPI(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig)).
 		Return(true, nil)
 
 	// Mock the request to Fence's data upload endpoint to create a presigned url for this file name.
-	expectedReq := g3cmd.ShepherdInitRequestObject{
+	expectedReq := gen3.ShepherdInitRequestObject{
 		Filename: testFilename,
 		Authz: struct {
 			Version       string   `json:"version"`
@@ -236,11 +279,11 @@ func TestGeneratePresignedURL_withShepherd(t *testing.T) {
 	}
 	mockGen3Interface.
 		EXPECT().
-		GetResponse(gomock.AssignableToTypeOf(testProfileConfig), commonUtils.ShepherdEndpoint+"/objects", "POST", "", expectedReqBody).
+		GetResponse(gomock.Any(), gomock.AssignableToTypeOf(testProfileConfig), commonUtils.ShepherdEndpoint+"/objects", "POST", "", expectedReqBody).
 		Return("", &mockUploadURLResponse, nil)
 	// ----------
 
-	url, guid, err := g3cmd.GeneratePresignedURL(mockGen3Interface, testFilename, testMetadata, testBucketname)
+	url, guid, err := gen3.NewClient(jwt.Credential{}, mockGen3Interface).GeneratePresignedURL(context.Background(), testFilename, testMetadata, testBucketname)
 	if err != nil {
 		t.Error(err)
 	}
@@ -251,3 +294,73 @@ func TestGeneratePresignedURL_withShepherd(t *testing.T) {
 		t.Errorf("Wanted generated GUID to be %v, got %v", mockGUID, guid)
 	}
 }
+
+// Expect RetryUpload to stop waiting for its exponential backoff as soon as the upload is cancelled,
+// without sending any request
+func TestRetryUpload_cancelledDuringBackoff(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockGen3Interface := mocks.NewMockGen3Interface(mockCtrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	failedLogMap := map[string]commonUtils.RetryObject{
+		"/tmp/test.txt": {FilePath: "/tmp/test.txt", RetryCount: 3},
+	}
+	start := time.Now()
+	err := gen3.NewClient(jwt.Credential{}, mockGen3Interface).RetryUpload(ctx, failedLogMap)
+	if err != context.Canceled {
+		t.Errorf("Wanted the retry to be cancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Wanted the backoff of %v to be cut short by the cancellation, waited %v", gen3.GetWaitTime(4), elapsed)
+	}
+}
+
+// maxMultipartBufferMemory is the most memory the buffers of the parts of a multipart upload may take together
+const maxMultipartBufferMemory = 2 * gen3.GB
+
+// Expect the chunk size of a multipart upload to be kept within the 5MB to 5GB part sizes of S3, and to be raised so
+// that a file doesn't have more than 10,000 parts
+func TestCalculateChunkSize(t *testing.T) {
+	testCases := []struct {
+		name        string
+		fileSize    int64
+		chunkSize   int64
+		wantedSize  int64
+		wantedParts int
+	}{
+		{"default chunk size", 100 * gen3.MB, 0, gen3.DefaultMultipartChunkSize, 7},
+		{"empty file", 0, 0, gen3.DefaultMultipartChunkSize, 1},
+		{"exact number of parts", 10 * gen3.MB, 5 * gen3.MB, 5 * gen3.MB, 2},
+		{"below the 5MB minimum", 100 * gen3.MB, gen3.MB, 5 * gen3.MB, 20},
+		{"above the 5GB maximum", 100 * gen3.GB, 10 * gen3.GB, 5 * gen3.GB, 20},
+		{"more than 10,000 parts", gen3.TB, 5 * gen3.MB, 109951163, 10000},
+		{"largest file", gen3.MultipartFileSizeLimit, 0, 549755814, 10000},
+	}
+	for _, testCase := range testCases {
+		chunkSize, numOfChunks := gen3.CalculateChunkSize(testCase.fileSize, testCase.chunkSize)
+		if chunkSize != testCase.wantedSize || numOfChunks != testCase.wantedParts {
+			t.Errorf("%s: wanted %d parts of %d bytes, got %d parts of %d bytes", testCase.name, testCase.wantedParts, testCase.wantedSize, numOfChunks, chunkSize)
+		}
+		if chunkSize < 5*gen3.MB || chunkSize > 5*gen3.GB || numOfChunks > 10000 || chunkSize*int64(numOfChunks) < testCase.fileSize {
+			t.Errorf("%s: wanted %d parts of %d bytes to cover the file within the limits of S3", testCase.name, numOfChunks, chunkSize)
+		}
+	}
+}
+
+// Expect the buffers of a multipart upload not to take more than 2GB together, with at least one buffer and
+// no more buffers than workers
+func TestMultipartBufferCount(t *testing.T) {
+	for _, chunkSize := range []int64{5 * gen3.MB, gen3.DefaultMultipartChunkSize, 525 * gen3.MB, 2 * gen3.GB, 3 * gen3.GB, 5 * gen3.GB} {
+		for _, numOfWorkers := range []int{1, 10, 1000} {
+			numOfBuffers := gen3.MultipartBufferCount(chunkSize, numOfWorkers)
+			if numOfBuffers < 1 || numOfBuffers > numOfWorkers {
+				t.Errorf("Wanted 1 to %d buffers of %d bytes, got %d", numOfWorkers, chunkSize, numOfBuffers)
+			}
+			if numOfBuffers > 1 && int64(numOfBuffers)*chunkSize > maxMultipartBufferMemory {
+				t.Errorf("Wanted %d buffers of %d bytes to take at most %d bytes", numOfBuffers, chunkSize, maxMultipartBufferMemory)
+			}
+		}
+	}
+}